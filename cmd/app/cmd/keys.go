@@ -0,0 +1,40 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"be20250107/internal/modules/keyring"
+
+	"github.com/spf13/cobra"
+)
+
+var keysCmd = &cobra.Command{
+	Use:   "keys",
+	Short: "Manage the keys used to sign access tokens and storage URLs",
+}
+
+var keysGenerateCmd = &cobra.Command{
+	Use:   "generate",
+	Short: "Generate a new RSA signing key in JWK format",
+	Long: "Generate a new RSA signing key in JWK format. Add the output to " +
+		"private.signing_keys, publish it for a while without marking it active, " +
+		"then mark it active and set retired_at on the previous key.",
+	Run: func(cmd *cobra.Command, args []string) {
+		bits, err := cmd.Flags().GetInt("bits")
+		if err != nil {
+			panic(err.Error())
+		}
+
+		key, err := keyring.Generate(bits)
+		if err != nil {
+			panic(err.Error())
+		}
+
+		out, err := json.Marshal(key)
+		if err != nil {
+			panic(err.Error())
+		}
+		fmt.Println(string(out))
+	},
+}
@@ -16,6 +16,10 @@ func init() {
 	rootCmd.AddCommand(serveCmd)
 	serveCmd.Flags().String("env", "", "Which environment this server will run on")
 
+	rootCmd.AddCommand(keysCmd)
+	keysCmd.AddCommand(keysGenerateCmd)
+	keysGenerateCmd.Flags().Int("bits", 2048, "Size of the generated RSA key in bits")
+
 }
 
 func Execute() {
@@ -5,12 +5,17 @@ import (
 	"be20250107/internal/modules/authentication"
 	"be20250107/internal/modules/cache"
 	"be20250107/internal/modules/filestore"
+	"be20250107/internal/modules/keyring"
+	"be20250107/internal/modules/lockout"
 	"be20250107/internal/modules/logger"
+	"be20250107/internal/modules/mq"
+	"be20250107/internal/modules/otp"
+	"be20250107/internal/modules/scheduler"
+	"be20250107/internal/modules/secretbox"
 
 	"github.com/jmoiron/sqlx"
 	"github.com/lestrrat-go/jwx/v2/jwk"
 	"github.com/lestrrat-go/jwx/v2/jwt"
-	"github.com/nsqio/go-nsq"
 )
 
 type Registry struct {
@@ -19,15 +24,30 @@ type Registry struct {
 	config *config.Config
 	Config *config.PublicConfig
 
-	Localizer       *Localizer
-	DB              *sqlx.DB
-	Cache           cache.Cache
-	Disks           map[string]filestore.Disk
-	Auth            authentication.Auth
-	Log             *logger.Logger
-	MessageProducer *nsq.Producer
-	SigningKey      jwk.RSAPrivateKey
-	VerifyKey       jwk.RSAPublicKey
+	Localizer  *Localizer
+	DB         *sqlx.DB
+	Cache      cache.Cache
+	Disks      map[string]filestore.Disk
+	Auth       authentication.Auth
+	Log        *logger.Logger
+	MessageBus mq.Bus
+	SigningKey jwk.RSAPrivateKey
+	KeyRing    *keyring.KeyRing
+	OTP        *otp.Store
+	OTPSender  otp.Sender
+	// OTPTestAccounts enables the test accounts logging in with a fixed code.
+	OTPTestAccounts bool
+	// IPLockout and CredentialLockout lock out clients after repeated failed
+	// logins.
+	IPLockout         *lockout.Guard
+	CredentialLockout *lockout.Guard
+	// Scheduler runs the maintenance jobs registered by the server.
+	Scheduler *scheduler.Scheduler
+	// Consumers handles the messages of the bus once the server has started.
+	Consumers *mq.Consumers
+	// SecretBox encrypts secrets stored in the database. It is nil when no
+	// encryption key is configured.
+	SecretBox *secretbox.Box
 }
 
 func NewRegistry(config *config.Config, appName string) *Registry {
@@ -36,10 +56,11 @@ func NewRegistry(config *config.Config, appName string) *Registry {
 		panic(err.Error())
 	}
 
-	secretKey, publicKey, err := NewSigningKey(config.Private)
+	keyRing, err := NewKeyRing(config.Private)
 	if err != nil {
 		panic(err.Error())
 	}
+	secretKey := keyRing.SigningKey()
 
 	c, err := NewCache(config.Private.Cache)
 	if err != nil {
@@ -51,8 +72,21 @@ func NewRegistry(config *config.Config, appName string) *Registry {
 		panic(err.Error())
 	}
 
+	secretBox, err := NewSecretBox(config.Private.EncryptionKey)
+	if err != nil {
+		panic(err.Error())
+	}
+
 	authModule := NewAuthModule(config.Private.Auth)
-	authModule.Init(db, c, publicKey)
+	authModule.SecretBox = secretBox
+	authModule.Init(db, c, keyRing)
+
+	otpSender, err := otp.NewSender(config.Private.Auth.OTP.Sender)
+	if err != nil {
+		panic(err.Error())
+	}
+
+	ipLockout, credentialLockout := NewLockouts(config.Private.Auth.Lockout, c)
 
 	loggerModule, err := NewLogger(config.Private.Log, config.Public.Debug, appName)
 	if err != nil {
@@ -61,12 +95,32 @@ func NewRegistry(config *config.Config, appName string) *Registry {
 
 	jwt.Settings(jwt.WithFlattenAudience(true))
 
-	nsqConfig := nsq.NewConfig()
-	nsqProducer, err := nsq.NewProducer(config.Public.NsqdHost, nsqConfig)
+	bus, err := NewMessageBus(config.Public)
 	if err != nil {
 		panic(err.Error())
 	}
 
+	authModule.PublishRevocation = NewRevocationPublisher(bus)
+	if channel, ok := bus.(*mq.ChannelBus); ok {
+		channel.Log = func(message string) {
+			loggerModule.Error(message)
+		}
+	}
+	if tiered, ok := c.(*cache.TieredCache); ok {
+		tiered.Broadcast = NewCacheInvalidationPublisher(bus)
+		tiered.Log = func(message string) {
+			loggerModule.Error(message)
+		}
+	}
+
+	consumers, err := NewConsumers(bus, config.Public.NsqConfig)
+	if err != nil {
+		panic(err.Error())
+	}
+	consumers.Log = func(message string) {
+		loggerModule.Error(message)
+	}
+
 	localizerModule := NewLocalizer(config.Private.Localizer)
 
 	return &Registry{
@@ -75,14 +129,24 @@ func NewRegistry(config *config.Config, appName string) *Registry {
 		config: config,
 		Config: config.Public,
 
-		DB:              db,
-		Cache:           c,
-		Disks:           disks,
-		Auth:            authModule,
-		Log:             loggerModule,
-		Localizer:       localizerModule,
-		MessageProducer: nsqProducer,
-		SigningKey:      secretKey,
-		VerifyKey:       publicKey,
+		DB:         db,
+		Cache:      c,
+		Disks:      disks,
+		Auth:       authModule,
+		Log:        loggerModule,
+		Localizer:  localizerModule,
+		MessageBus: bus,
+		SigningKey: secretKey,
+		KeyRing:    keyRing,
+		OTP:        NewOTPStore(config.Private.Auth.OTP, c),
+		OTPSender:  otpSender,
+
+		OTPTestAccounts: config.Private.Auth.OTP.TestAccounts,
+
+		IPLockout:         ipLockout,
+		CredentialLockout: credentialLockout,
+		Scheduler:         scheduler.New(scheduler.NewMySQLStatusStore(db.DB), scheduler.NewMySQLLocker(db.DB)),
+		Consumers:         consumers,
+		SecretBox:         secretBox,
 	}
 }
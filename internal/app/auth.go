@@ -4,16 +4,52 @@ import (
 	"be20250107/internal/config"
 	"be20250107/internal/modules/authentication"
 	mobilebe "be20250107/internal/modules/authentication/mobile_be"
+	"be20250107/internal/modules/authentication/oidc"
 	"be20250107/internal/modules/authentication/xinchuanauth"
+	"be20250107/internal/modules/cache"
+	"be20250107/internal/modules/lockout"
+	"be20250107/internal/modules/mq"
+	"be20250107/internal/modules/otp"
+
+	"gopkg.in/guregu/null.v4"
 )
 
 func NewAuthModule(config config.AuthConfig) authentication.Auth {
 	return authentication.Auth{
-		XinchuanAuth: NewXinchuanAuthClient(config.XinchuanAuth),
-		MobileBEAuth: NewMobileBEClient(config.MobileBEAuth),
+		Providers:     NewIdentityProviders(config),
+		MobileBEAuth:  NewMobileBEClient(config.MobileBEAuth),
+		SyncInterval:  config.SyncInterval,
+		SignatureSkew: config.SignatureSkew,
+		TokenBinding:  config.TokenBinding,
+
+		RevocationResyncInterval: config.RevocationResyncInterval,
 	}
 }
 
+func NewIdentityProviders(config config.AuthConfig) *authentication.IdentityProviders {
+	providers := authentication.NewIdentityProviders()
+	providers.Register(authentication.XinchuanProvider{
+		Client:      NewXinchuanAuthClient(config.XinchuanAuth),
+		RoleMapping: config.XinchuanAuth.RoleMapping,
+	})
+
+	for _, c := range config.OIDC {
+		if c.Name == "" {
+			panic("[NewIdentityProviders] oidc provider name is required")
+		}
+		providers.Register(authentication.OIDCProvider{
+			ProviderName:  c.Name,
+			Client:        NewOIDCClient(c),
+			IDClaim:       c.IDClaim,
+			UsernameClaim: c.UsernameClaim,
+			NameClaim:     c.NameClaim,
+			EmailClaim:    c.EmailClaim,
+			RoleClaim:     c.RoleClaim,
+		})
+	}
+	return providers
+}
+
 func NewXinchuanAuthClient(config config.XinchuanAuthConfig) *xinchuanauth.Client {
 	baseUrl := config.BaseURL
 	if baseUrl == "" {
@@ -28,6 +64,17 @@ func NewXinchuanAuthClient(config config.XinchuanAuthConfig) *xinchuanauth.Clien
 	})
 }
 
+func NewOIDCClient(config config.OIDCProviderConfig) *oidc.Client {
+	return oidc.NewClient(oidc.Options{
+		Issuer:       config.Issuer,
+		ClientID:     config.ClientID,
+		ClientSecret: config.ClientSecret,
+		RedirectURI:  config.Callback,
+		Scopes:       config.Scopes,
+		Timeout:      config.Timeout,
+	})
+}
+
 func NewMobileBEClient(config config.MobileBEAuthConfig) *mobilebe.Client {
 	baseUrl := config.BaseURL
 	if baseUrl == "" {
@@ -39,3 +86,40 @@ func NewMobileBEClient(config config.MobileBEAuthConfig) *mobilebe.Client {
 		Secret:  config.Secret,
 	})
 }
+
+func NewOTPStore(config config.OTPConfig, c cache.Cache) *otp.Store {
+	return otp.New(c, otp.Options{
+		Length:      config.Length,
+		TTL:         config.TTL,
+		Cooldown:    config.Cooldown,
+		MaxAttempts: config.MaxAttempts,
+	})
+}
+
+// NewLockouts returns the lockout guards of client IPs and credentials.
+func NewLockouts(config config.LockoutConfig, c cache.Cache) (*lockout.Guard, *lockout.Guard) {
+	options := lockout.Options{
+		Window:      config.Window,
+		Duration:    config.Duration,
+		MaxDuration: config.MaxDuration,
+	}
+
+	ipOptions := options
+	ipOptions.MaxAttempts = config.IPMaxAttempts
+	credentialOptions := options
+	credentialOptions.MaxAttempts = config.CredentialMaxAttempts
+
+	return lockout.New(c, "ip", ipOptions), lockout.New(c, "credential", credentialOptions)
+}
+
+// NewRevocationPublisher broadcasts revoked tokens on mq.TokenRevokedTopic.
+func NewRevocationPublisher(publisher mq.Publisher) authentication.RevocationPublisher {
+	return func(tokenID string, expiredAt null.Time) error {
+		msg := mq.TokenRevokedMsg{TokenID: tokenID}
+		if expiredAt.Valid {
+			exp := expiredAt.Time.Unix()
+			msg.ExpiredAt = &exp
+		}
+		return mq.PublishMessage(publisher, mq.TokenRevokedTopic, msg)
+	}
+}
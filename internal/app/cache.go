@@ -6,12 +6,18 @@ import (
 
 	"be20250107/internal/config"
 	"be20250107/internal/modules/cache"
+	"be20250107/internal/modules/mq"
 
 	"github.com/dgraph-io/badger/v3"
 	"github.com/go-redis/redis/v8"
 )
 
 func NewCache(cc config.CacheConfig) (cache.Cache, error) {
+	codec, err := cache.CodecByName(cc.Codec)
+	if err != nil {
+		return nil, err
+	}
+
 	switch cc.Engine {
 	case "badger":
 		opt := badger.DefaultOptions(cc.Badger.Path)
@@ -25,7 +31,9 @@ func NewCache(cc config.CacheConfig) (cache.Cache, error) {
 		if err != nil {
 			panic(err.Error())
 		}
-		return cache.NewBadgerCache(bDB), nil
+		return cache.NewBadgerCache(bDB, codec), nil
+	case "memory":
+		return cache.NewInMemoryCache(codec), nil
 	case "redis":
 		addr := fmt.Sprintf("%s:%d", cc.Redis.Host, cc.Redis.Port)
 		opt := redis.Options{
@@ -36,7 +44,34 @@ func NewCache(cc config.CacheConfig) (cache.Cache, error) {
 		}
 		r := redis.NewClient(&opt)
 		ctx := context.Background()
-		return cache.NewRedisCache(r, ctx), nil
+		return cache.NewRedisCache(r, ctx, codec), nil
+	case "tiered":
+		if cc.Tiered == nil || cc.Tiered.Engine == "tiered" {
+			return nil, fmt.Errorf("tiered cache needs another engine behind it")
+		}
+		l2Config := cc
+		l2Config.Engine = cc.Tiered.Engine
+		l2, err := NewCache(l2Config)
+		if err != nil {
+			return nil, err
+		}
+		return cache.NewTieredCache(l2, cache.TieredOptions{
+			Size:   cc.Tiered.Size,
+			TTL:    cc.Tiered.TTL,
+			Bypass: cc.Tiered.Bypass,
+		}), nil
 	}
 	return nil, fmt.Errorf("unsupported cache engine: %s", cc.Engine)
 }
+
+// NewCacheInvalidationPublisher broadcasts invalidations of the local tier of a
+// tiered cache on mq.CacheInvalidatedTopic.
+func NewCacheInvalidationPublisher(publisher mq.Publisher) func(cache.Invalidation) error {
+	return func(inv cache.Invalidation) error {
+		return mq.PublishMessage(publisher, mq.CacheInvalidatedTopic, mq.CacheInvalidatedMsg{
+			Origin: inv.Origin,
+			Keys:   inv.Keys,
+			Flush:  inv.Flush,
+		})
+	}
+}
@@ -2,26 +2,64 @@ package app
 
 import (
 	"fmt"
+	"time"
 
 	"be20250107/internal/config"
-
-	"github.com/lestrrat-go/jwx/v2/jwk"
+	"be20250107/internal/modules/keyring"
+	"be20250107/internal/modules/secretbox"
 )
 
-func NewSigningKey(config *config.PrivateConfig) (jwk.RSAPrivateKey, jwk.RSAPublicKey, error) {
-	rawKey := config.SigningKey
-	private, err := jwk.ParseKey([]byte(rawKey))
-	if err != nil {
-		return nil, nil, err
+// NewKeyRing loads the signing keys from the configuration. The legacy
+// signing_key entry is still accepted and becomes the active key unless one
+// of the signing_keys entries is marked active.
+func NewKeyRing(cfg *config.PrivateConfig) (*keyring.KeyRing, error) {
+	var keys []*keyring.Key
+	hasActive := false
+
+	for i, kc := range cfg.SigningKeys {
+		var retiredAt time.Time
+		if kc.RetiredAt != "" {
+			t, err := time.Parse(time.RFC3339, kc.RetiredAt)
+			if err != nil {
+				return nil, fmt.Errorf("signing key %d has invalid retired_at: %w", i, err)
+			}
+			retiredAt = t
+		}
+
+		key, err := keyring.ParseKey(kc.Key, kc.Active, retiredAt)
+		if err != nil {
+			return nil, fmt.Errorf("signing key %d: %w", i, err)
+		}
+		hasActive = hasActive || kc.Active
+		keys = append(keys, key)
 	}
-	if _, ok := private.(jwk.RSAPrivateKey); !ok {
-		return nil, nil, fmt.Errorf("signing key must be an RSA private key")
+
+	if cfg.SigningKey != "" {
+		key, err := keyring.ParseKey(cfg.SigningKey, !hasActive, time.Time{})
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
 	}
 
-	public, err := private.PublicKey()
-	if err != nil {
-		return nil, nil, err
+	retention := cfg.SigningKeyRetention
+	if retention <= 0 {
+		retention = config.DefaultSigningKeyRetention
 	}
+	retention = max(retention, config.MaxTokenLifetime)
 
-	return private.(jwk.RSAPrivateKey), public.(jwk.RSAPublicKey), nil
+	return keyring.New(keys, retention)
+}
+
+// NewSecretBox returns the box encrypting stored secrets, or nil when no
+// encryption key is configured. Features that need it are then unavailable.
+func NewSecretBox(encryptionKey string) (*secretbox.Box, error) {
+	if encryptionKey == "" {
+		return nil, nil
+	}
+	box, err := secretbox.New(encryptionKey)
+	if err != nil {
+		return nil, fmt.Errorf("encryption_key: %w", err)
+	}
+	return box, nil
 }
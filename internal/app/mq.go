@@ -0,0 +1,37 @@
+package app
+
+import (
+	"fmt"
+	"math"
+
+	"be20250107/internal/config"
+	"be20250107/internal/modules/mq"
+)
+
+func NewMessageBus(config *config.PublicConfig) (mq.Bus, error) {
+	return mq.NewBus(mq.BusOptions{
+		Driver:      config.MessageBus.Driver,
+		NsqdHost:    config.NsqdHost,
+		LookupdHost: config.NSQLookupdHost,
+	})
+}
+
+// NewConsumers returns the consumers of the bus, configured per topic by the
+// consumers of the nsq config. A max_attempt out of the range of the drivers
+// is rejected rather than wrapped around.
+func NewConsumers(bus mq.Bus, config config.NsqConfig) (*mq.Consumers, error) {
+	defaults := mq.ConsumerOptions{}
+	defaults.Concurrency = config.MaxConcurrent
+
+	options := map[string]mq.ConsumerOptions{}
+	for topic, consumer := range config.Consumers {
+		if consumer.MaxAttempt < 0 || consumer.MaxAttempt > math.MaxUint16 {
+			return nil, fmt.Errorf("max_attempt of consumer %s must be between 0 and %d, got %d", topic, math.MaxUint16, consumer.MaxAttempt)
+		}
+		o := mq.ConsumerOptions{}
+		o.MaxInFlight = consumer.MaxInFlight
+		o.MaxAttempts = uint16(consumer.MaxAttempt)
+		options[topic] = o
+	}
+	return mq.NewConsumers(bus, defaults, options), nil
+}
@@ -1,10 +1,14 @@
 package config
 
+import "time"
+
 type XinchuanAuthConfig struct {
 	ID       int
 	Secret   string
 	BaseURL  string `mapstructure:"base_url"`
 	Callback string
+	// RoleMapping maps Xinchuan role IDs to local role names.
+	RoleMapping map[string]string `mapstructure:"role_mapping"`
 }
 
 type MobileBEAuthConfig struct {
@@ -12,7 +16,66 @@ type MobileBEAuthConfig struct {
 	BaseURL string `mapstructure:"base_url"`
 }
 
+// OIDCProviderConfig configures a generic OpenID Connect identity provider.
+// Name is stored in admins.provider, so it must not change once admins have
+// logged in with it.
+type OIDCProviderConfig struct {
+	Name          string
+	Issuer        string
+	ClientID      string `mapstructure:"client_id"`
+	ClientSecret  string `mapstructure:"client_secret"`
+	Callback      string
+	Scopes        []string
+	IDClaim       string `mapstructure:"id_claim"`
+	UsernameClaim string `mapstructure:"username_claim"`
+	NameClaim     string `mapstructure:"name_claim"`
+	EmailClaim    string `mapstructure:"email_claim"`
+	RoleClaim     string `mapstructure:"role_claim"`
+	// Timeout bounds each request to the provider. Zero uses the default of
+	// 10 seconds.
+	Timeout time.Duration
+}
+
+type OTPConfig struct {
+	// Sender is the name of the sender used to deliver codes. Only "log" is
+	// built in.
+	Sender      string
+	Length      int
+	TTL         time.Duration
+	Cooldown    time.Duration
+	MaxAttempts int `mapstructure:"max_attempts"`
+	// TestAccounts lets the built-in test phone numbers log in with a fixed
+	// code. It must never be enabled in production.
+	TestAccounts bool `mapstructure:"test_accounts"`
+}
+
+// LockoutConfig limits failed login attempts per client IP and per credential.
+type LockoutConfig struct {
+	IPMaxAttempts         int           `mapstructure:"ip_max_attempts"`
+	CredentialMaxAttempts int           `mapstructure:"credential_max_attempts"`
+	Window                time.Duration `mapstructure:"window"`
+	// Duration is the length of the first lockout, doubled on every further
+	// lockout up to MaxDuration.
+	Duration    time.Duration `mapstructure:"duration"`
+	MaxDuration time.Duration `mapstructure:"max_duration"`
+}
+
 type AuthConfig struct {
-	XinchuanAuth XinchuanAuthConfig `mapstructure:"xinchuan_auth"`
-	MobileBEAuth MobileBEAuthConfig `mapstructure:"mobile_be_auth"`
+	XinchuanAuth XinchuanAuthConfig   `mapstructure:"xinchuan_auth"`
+	MobileBEAuth MobileBEAuthConfig   `mapstructure:"mobile_be_auth"`
+	OIDC         []OIDCProviderConfig `mapstructure:"oidc"`
+	// SyncInterval is how often admin accounts are synced with their identity
+	// provider by the "sync_admins" scheduler job, unless the job has a cron
+	// expression of its own. Zero disables the sync.
+	SyncInterval time.Duration `mapstructure:"sync_interval"`
+	// SignatureSkew is the allowed clock skew of HMAC signed system requests.
+	SignatureSkew time.Duration `mapstructure:"signature_skew"`
+	// TokenBinding binds new tokens to the device fingerprint of the client:
+	// "off", "flag" to record mismatches or "enforce" to also reject them.
+	TokenBinding string `mapstructure:"token_binding"`
+	// RevocationResyncInterval is how often revoked tokens are reloaded from
+	// the database in case a broadcast was missed. Zero disables the resync.
+	RevocationResyncInterval time.Duration `mapstructure:"revocation_resync_interval"`
+	OTP                      OTPConfig     `mapstructure:"otp"`
+	Lockout                  LockoutConfig `mapstructure:"lockout"`
 }
@@ -1,9 +1,17 @@
 package config
 
+import "time"
+
 type CacheConfig struct {
 	Engine string
+	// Codec serializes the values written by the engines: gob (default),
+	// json or msgpack. The local tier of the tiered engine always uses gob.
+	// Entries written by any codec are read, so it can be changed without
+	// flushing the cache.
+	Codec  string
 	Badger *BadgerConfig
 	Redis  *RedisConfig
+	Tiered *TieredCacheConfig
 }
 
 type BadgerConfig struct {
@@ -19,3 +27,14 @@ type RedisConfig struct {
 	Password string
 	DBIndex  int `mapstructure:"db_index"`
 }
+
+// TieredCacheConfig puts an in-process LRU in front of Engine, which must be
+// one of the other engines.
+type TieredCacheConfig struct {
+	Engine string
+	Size   int
+	TTL    time.Duration
+	// Bypass are the key prefixes that are never kept in process. It defaults
+	// to the rate limit, lockout and auth keys.
+	Bypass []string
+}
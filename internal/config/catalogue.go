@@ -0,0 +1,11 @@
+package config
+
+import "time"
+
+// CatalogueCacheConfig configures the read-through cache of catalogue reads.
+type CatalogueCacheConfig struct {
+	Enabled bool
+	// TTL bounds how stale an entry can be when a write is made outside of
+	// the API and thus does not invalidate it.
+	TTL time.Duration
+}
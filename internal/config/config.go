@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"net/url"
 	"strings"
+	"time"
 
 	"github.com/spf13/viper"
 )
@@ -22,6 +23,9 @@ type ListenConfig struct {
 	Host      string
 	Port      int
 	EnableTLS bool `mapstructure:"enable_tls"`
+	// TrustedProxies are the CIDRs or addresses of the reverse proxies whose
+	// X-Forwarded-For and X-Real-IP headers are believed.
+	TrustedProxies []string `mapstructure:"trusted_proxies"`
 }
 
 type MigrationConfig struct {
@@ -51,6 +55,13 @@ type NsqConfig struct {
 	Consumers      map[string]NsqConsumerConfig
 }
 
+// MessageBusConfig chooses the driver of the message bus: nsq (default), which
+// connects to the hosts of NsqConfig, channel, which delivers messages in
+// process for single-node setups, or nop, which discards them.
+type MessageBusConfig struct {
+	Driver string
+}
+
 type NsqConsumerConfig struct {
 	MaxInFlight int `mapstructure:"max_in_flight"`
 	MaxAttempt  int `mapstructure:"max_attempt"`
@@ -72,10 +83,20 @@ type PublicConfig struct {
 	MaxBalanceMutation                float64                   `mapstructure:"max_balance_mutation"`
 	MaxOnlineDriverInactiveTimeSecond int                       `mapstructure:"max_online_driver_inactive_time_second"`
 	NsqConfig                         `mapstructure:"nsq"`
+	Scheduler                         SchedulerConfig      `mapstructure:"scheduler"`
+	RateLimit                         RateLimitConfig      `mapstructure:"rate_limit"`
+	CatalogueCache                    CatalogueCacheConfig `mapstructure:"catalogue_cache"`
+	MessageBus                        MessageBusConfig     `mapstructure:"message_bus"`
 }
 
 type PrivateConfig struct {
-	SigningKey string `mapstructure:"signing_key"`
+	SigningKey          string             `mapstructure:"signing_key"`
+	SigningKeys         []SigningKeyConfig `mapstructure:"signing_keys"`
+	SigningKeyRetention time.Duration      `mapstructure:"signing_key_retention"`
+	// EncryptionKey encrypts the secrets stored in the database that must be
+	// read back, such as system signing secrets, admin TOTP secrets and
+	// identity provider refresh tokens. It is 32 bytes encoded in base64.
+	EncryptionKey string `mapstructure:"encryption_key"`
 
 	Database  DatabaseConfig
 	Storage   StorageConfig
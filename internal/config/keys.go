@@ -0,0 +1,19 @@
+package config
+
+import "time"
+
+type SigningKeyConfig struct {
+	Key       string
+	Active    bool
+	RetiredAt string `mapstructure:"retired_at"`
+}
+
+// MaxTokenLifetime is the lifetime of the longest lived access tokens, those of
+// users. A retired key stays published at least this long, whatever the
+// configured retention, so that tokens signed before a rotation keep verifying
+// until they expire.
+const MaxTokenLifetime = 30 * 24 * time.Hour
+
+// DefaultSigningKeyRetention is how long a retired key stays published when
+// no retention is configured.
+const DefaultSigningKeyRetention = MaxTokenLifetime
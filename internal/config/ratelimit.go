@@ -0,0 +1,25 @@
+package config
+
+import "time"
+
+// RateLimitConfig configures the rate limits of route groups. Policies are
+// looked up by the name given to middlewares.RateLimitMiddleware, and route
+// groups without a configured policy are not limited.
+type RateLimitConfig struct {
+	Enabled  bool
+	Policies map[string]RateLimitPolicyConfig
+	// TrustedSystems are the IDs of internal systems that are never limited.
+	TrustedSystems []string `mapstructure:"trusted_systems"`
+}
+
+type RateLimitPolicyConfig struct {
+	// Key is what requests are counted by: "ip", "admin", "system",
+	// "api_key" or "account" for any authenticated account. Requests without
+	// such an account are counted by IP.
+	Key string
+	// Algorithm is "fixed_window", "sliding_window" or "token_bucket".
+	Algorithm string
+	Limit     int
+	Period    time.Duration
+	Burst     int
+}
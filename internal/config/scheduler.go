@@ -0,0 +1,15 @@
+package config
+
+import "time"
+
+// SchedulerConfig configures the built-in maintenance jobs. Jobs maps job
+// names to cron expressions; a job with an empty expression is not scheduled.
+type SchedulerConfig struct {
+	Enabled bool
+	Jobs    map[string]string
+	// TokenRetention is how long access tokens are kept after they expire.
+	TokenRetention time.Duration `mapstructure:"token_retention"`
+	// LoginLogRetention is how long login logs are kept. Zero keeps them
+	// forever.
+	LoginLogRetention time.Duration `mapstructure:"login_log_retention"`
+}
@@ -0,0 +1,132 @@
+package admin
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"be20250107/internal/app"
+	"be20250107/internal/controllers"
+	"be20250107/internal/models"
+	"be20250107/internal/responses"
+
+	"github.com/go-chi/chi/v5"
+	"gopkg.in/guregu/null.v4"
+)
+
+const apiKeyUsageDays = 30
+
+type APIKeyController struct {
+	controllers.Controller
+}
+
+func NewAPIKeyController(app *app.Registry) *APIKeyController {
+	return &APIKeyController{controllers.Controller{App: app}}
+}
+
+// apiKeyWithSecret is returned when a key is created. The plain key is not
+// stored and cannot be retrieved again.
+type apiKeyWithSecret struct {
+	*models.APIKey
+	Key string `json:"key"`
+}
+
+type apiKeyWithUsage struct {
+	*models.APIKey
+	Usages []models.APIKeyUsage `json:"usages"`
+}
+
+func (c *APIKeyController) apiKey(r *http.Request) *models.APIKey {
+	key, exist, err := models.GetAPIKeyByID(c.App.DB, chi.URLParam(r, "APIKeyID"))
+	if err != nil {
+		panic(err)
+	}
+	if !exist {
+		c.NotFound()
+	}
+	return key
+}
+
+func (c *APIKeyController) Index(w http.ResponseWriter, r *http.Request) {
+	keys, err := models.GetAPIKeys(c.App.DB)
+	if err != nil {
+		panic(err)
+	}
+
+	err = responses.JSON(w, 200, struct {
+		Data []models.APIKey `json:"data"`
+	}{
+		Data: keys,
+	})
+	if err != nil {
+		panic(err)
+	}
+}
+
+// Show returns the key together with its daily usage of the last 30 days.
+func (c *APIKeyController) Show(w http.ResponseWriter, r *http.Request) {
+	key := c.apiKey(r)
+
+	usages, err := models.GetAPIKeyUsages(c.App.DB, key.ID, time.Now().AddDate(0, 0, -apiKeyUsageDays))
+	if err != nil {
+		panic(err)
+	}
+
+	err = responses.JSON(w, 200, apiKeyWithUsage{APIKey: key, Usages: usages})
+	if err != nil {
+		panic(err)
+	}
+}
+
+func (c *APIKeyController) Create(w http.ResponseWriter, r *http.Request) {
+	auth := c.AssertAuthenticated(r)
+
+	var req CreateAPIKeyRequest
+	err := c.Validate(&req, r)
+	if err != nil {
+		panic(err)
+	}
+
+	prefix, plainKey := models.GenerateAPIKey()
+	allowedIPs := strings.Join(req.AllowedIPs, " ")
+	key := models.APIKey{
+		Name:       req.Name,
+		Prefix:     prefix,
+		Scopes:     strings.Join(req.Scopes, " "),
+		AllowedIPs: null.NewString(allowedIPs, allowedIPs != ""),
+		DailyQuota: null.IntFromPtr(req.DailyQuota),
+		CreatedBy:  null.StringFrom(auth.UserID()),
+		ExpiredAt:  null.TimeFromPtr(req.ExpiredAt),
+		PlainKey:   plainKey,
+	}
+	err = key.Insert(c.App.DB)
+	if err != nil {
+		panic(err)
+	}
+
+	err = responses.JSON(w, 201, apiKeyWithSecret{APIKey: &key, Key: plainKey})
+	if err != nil {
+		panic(err)
+	}
+}
+
+func (c *APIKeyController) Revoke(w http.ResponseWriter, r *http.Request) {
+	key := c.apiKey(r)
+
+	if !key.RevokedAt.Valid {
+		key.RevokedAt = null.TimeFrom(time.Now())
+		err := key.Update(c.App.DB)
+		if err != nil {
+			panic(err)
+		}
+	}
+
+	err := responses.JSON(w, 200, struct {
+		OK bool `json:"ok"`
+	}{
+		OK: true,
+	})
+	if err != nil {
+		panic(err)
+	}
+}
@@ -0,0 +1,29 @@
+package admin
+
+import (
+	"net/http"
+
+	"be20250107/internal/app"
+	"be20250107/internal/controllers"
+	"be20250107/internal/responses"
+)
+
+type JobController struct {
+	controllers.Controller
+}
+
+func NewJobController(app *app.Registry) *JobController {
+	return &JobController{controllers.Controller{App: app}}
+}
+
+// Index lists the scheduled maintenance jobs with the status of their last run.
+func (c *JobController) Index(w http.ResponseWriter, r *http.Request) {
+	statuses, err := c.App.Scheduler.Statuses()
+	if err != nil {
+		panic(err)
+	}
+
+	if err := responses.JSON(w, 200, statuses); err != nil {
+		panic(err)
+	}
+}
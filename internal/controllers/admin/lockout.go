@@ -0,0 +1,87 @@
+package admin
+
+import (
+	"net/http"
+
+	"be20250107/internal/app"
+	"be20250107/internal/controllers"
+	"be20250107/internal/modules/lockout"
+	"be20250107/internal/responses"
+)
+
+type LockoutController struct {
+	controllers.Controller
+}
+
+func NewLockoutController(app *app.Registry) *LockoutController {
+	return &LockoutController{controllers.Controller{App: app}}
+}
+
+// guard returns the lockout guard and key addressed by the request.
+func (c *LockoutController) guard(req LockoutRequest) (*lockout.Guard, string) {
+	if req.IP != "" {
+		return c.App.IPLockout, req.IP
+	}
+	return c.App.CredentialLockout, req.credentialKey()
+}
+
+// Show reports whether the IP or credential in the query is locked out.
+func (c *LockoutController) Show(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	req := LockoutRequest{
+		IP:         query.Get("ip"),
+		Medium:     query.Get("medium"),
+		Credential: query.Get("credential"),
+	}
+	if err := req.Validate(c.RequestContext(r)); err != nil {
+		panic(err)
+	}
+
+	guard, key := c.guard(req)
+	resp := struct {
+		Key         string `json:"key"`
+		Locked      bool   `json:"locked"`
+		LockedUntil *int64 `json:"locked_until"`
+	}{
+		Key: key,
+	}
+
+	err := guard.Check(key)
+	if locked, ok := err.(*lockout.LockedError); ok {
+		until := locked.Until.Unix()
+		resp.Locked = true
+		resp.LockedUntil = &until
+	} else if err != nil {
+		panic(err)
+	}
+
+	if err := responses.JSON(w, 200, resp); err != nil {
+		panic(err)
+	}
+}
+
+// Clear lifts the lockout of the IP or credential in the request and forgets
+// its failed attempts.
+func (c *LockoutController) Clear(w http.ResponseWriter, r *http.Request) {
+	var req LockoutRequest
+	if err := c.Validate(&req, r); err != nil {
+		panic(err)
+	}
+
+	guard, key := c.guard(req)
+	if err := guard.Clear(key); err != nil {
+		panic(err)
+	}
+
+	auth := c.AssertAuthenticated(r)
+	c.App.Log.Info("[Lockout] " + key + " cleared by " + auth.UserID())
+
+	err := responses.JSON(w, 200, struct {
+		OK bool `json:"ok"`
+	}{
+		OK: true,
+	})
+	if err != nil {
+		panic(err)
+	}
+}
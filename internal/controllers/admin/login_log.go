@@ -0,0 +1,88 @@
+package admin
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"be20250107/internal/app"
+	"be20250107/internal/controllers"
+	"be20250107/internal/models"
+	"be20250107/internal/responses"
+
+	validation "github.com/go-ozzo/ozzo-validation/v4"
+)
+
+const maxLoginLogLimit = 100
+
+type LoginLogController struct {
+	controllers.Controller
+}
+
+func NewLoginLogController(app *app.Registry) *LoginLogController {
+	return &LoginLogController{controllers.Controller{App: app}}
+}
+
+// Index lists login logs, newest first. They can be filtered by account_type,
+// account_id, ip, successful and a from/to range of RFC 3339 times.
+func (c *LoginLogController) Index(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	limit, err := strconv.Atoi(query.Get("limit"))
+	if err != nil || limit <= 0 {
+		limit = 20
+	}
+	limit = min(limit, maxLoginLogLimit)
+
+	offset, err := strconv.Atoi(query.Get("offset"))
+	if err != nil || offset < 0 {
+		offset = 0
+	}
+
+	filter := models.LoginLogFilter{
+		AccountType: query.Get("account_type"),
+		AccountID:   query.Get("account_id"),
+		IPAddress:   query.Get("ip"),
+	}
+
+	errs := validation.Errors{}
+	if s := query.Get("successful"); s != "" {
+		if successful, err := strconv.ParseBool(s); err == nil {
+			filter.Successful = &successful
+		} else {
+			errs["successful"] = validation.NewError("validation_is_bool", "must be a boolean")
+		}
+	}
+	for name, target := range map[string]**time.Time{"from": &filter.From, "to": &filter.To} {
+		if s := query.Get(name); s != "" {
+			if t, err := time.Parse(time.RFC3339, s); err == nil {
+				*target = &t
+			} else {
+				errs[name] = validation.NewError("validation_is_time", "must be an RFC 3339 time")
+			}
+		}
+	}
+	if len(errs) > 0 {
+		panic(errs)
+	}
+
+	logs, total, err := models.GetLoginLogs(c.App.DB, filter, limit, offset)
+	if err != nil {
+		panic(err)
+	}
+
+	err = responses.JSON(w, 200, struct {
+		Data       []models.LoginLog            `json:"data"`
+		Pagination controllers.PaginationDetail `json:"pagination"`
+	}{
+		Data: logs,
+		Pagination: controllers.PaginationDetail{
+			NextPageCursor: strconv.Itoa(offset + limit),
+			PerPage:        limit,
+			HasNext:        total > offset+limit,
+		},
+	})
+	if err != nil {
+		panic(err)
+	}
+}
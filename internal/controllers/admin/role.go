@@ -0,0 +1,113 @@
+package admin
+
+import (
+	"net/http"
+
+	"be20250107/internal/app"
+	"be20250107/internal/controllers"
+	"be20250107/internal/models"
+	"be20250107/internal/reqdata"
+	"be20250107/internal/responses"
+
+	"github.com/go-chi/chi/v5"
+	"gopkg.in/guregu/null.v4"
+)
+
+type RoleController struct {
+	controllers.Controller
+}
+
+func NewRoleController(app *app.Registry) *RoleController {
+	return &RoleController{controllers.Controller{App: app}}
+}
+
+func (c *RoleController) role(r *http.Request) *models.Role {
+	role, exist, err := models.GetRoleByID(c.App.DB, chi.URLParam(r, "RoleID"))
+	if err != nil {
+		panic(err)
+	}
+	if !exist {
+		c.NotFound()
+	}
+	return role
+}
+
+// Index lists the roles, optionally filtered by the name in the query.
+func (c *RoleController) Index(w http.ResponseWriter, r *http.Request) {
+	roles, _, err := models.GetRoles(c.App.DB, "name", true, models.Role{Name: r.URL.Query().Get("name")})
+	if err != nil {
+		panic(err)
+	}
+	if roles == nil {
+		roles = []models.Role{}
+	}
+
+	err = responses.JSON(w, 200, struct {
+		Data []models.Role `json:"data"`
+	}{
+		Data: roles,
+	})
+	if err != nil {
+		panic(err)
+	}
+}
+
+func (c *RoleController) Show(w http.ResponseWriter, r *http.Request) {
+	err := responses.JSON(w, 200, c.role(r))
+	if err != nil {
+		panic(err)
+	}
+}
+
+// UpdateTwoFactor sets whether admins holding the role must use two-factor
+// authentication. Admins of the role who have not enrolled are asked to after
+// their next login. The change is recorded in the audit log.
+func (c *RoleController) UpdateTwoFactor(w http.ResponseWriter, r *http.Request) {
+	auth := c.AssertAuthorized(func(ctx *reqdata.Context) bool {
+		admin, err := controllers.GetAdminFromAuth(ctx.Auth)
+		return err == nil && admin.IsAdminAuthorized(c.App.DB, models.PermissionRoleTwoFactor)
+	}, r)
+
+	var req UpdateRoleTwoFactorRequest
+	if err := c.Validate(&req, r); err != nil {
+		panic(err)
+	}
+
+	role := c.role(r)
+	previous := role.RequireTwoFactor
+	role.RequireTwoFactor = *req.RequireTwoFactor
+
+	ip := reqdata.ClientIP(r)
+	log := models.AuditLog{
+		Event:       models.AuditRoleTwoFactorChanged,
+		AccountType: null.StringFrom(auth.AccountType()),
+		AccountID:   null.StringFrom(auth.UserID()),
+		IPAddress:   null.NewString(ip, ip != ""),
+		UserAgent:   null.NewString(r.UserAgent(), r.UserAgent() != ""),
+	}
+	err := log.SetData(map[string]any{
+		"role_id":  role.ID,
+		"role":     role.Name,
+		"previous": previous,
+		"current":  role.RequireTwoFactor,
+	})
+	if err != nil {
+		panic(err)
+	}
+
+	tx := c.App.DB.MustBegin()
+	defer tx.Rollback()
+	if err := role.Update(tx); err != nil {
+		panic(err)
+	}
+	if err := log.Insert(tx); err != nil {
+		panic(err)
+	}
+	if err := tx.Commit(); err != nil {
+		panic(err)
+	}
+
+	if err := responses.JSON(w, 200, role); err != nil {
+		panic(err)
+	}
+}
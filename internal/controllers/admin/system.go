@@ -0,0 +1,147 @@
+package admin
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"be20250107/internal/app"
+	"be20250107/internal/controllers"
+	"be20250107/internal/models"
+	"be20250107/internal/responses"
+
+	"github.com/go-chi/chi/v5"
+	"gopkg.in/guregu/null.v4"
+)
+
+type SystemController struct {
+	controllers.Controller
+}
+
+func NewSystemController(app *app.Registry) *SystemController {
+	return &SystemController{controllers.Controller{App: app}}
+}
+
+// systemWithSecret is returned when a secret is generated. The plain secret is
+// not stored and cannot be retrieved again.
+type systemWithSecret struct {
+	*models.System
+	Secret string `json:"secret"`
+}
+
+func (c *SystemController) system(r *http.Request) *models.System {
+	system, exist, err := models.GetSystemByID(c.App.DB, chi.URLParam(r, "SystemID"))
+	if err != nil {
+		panic(err)
+	}
+	if !exist {
+		c.NotFound()
+	}
+	return system
+}
+
+func (c *SystemController) Index(w http.ResponseWriter, r *http.Request) {
+	systems, err := models.GetSystems(c.App.DB)
+	if err != nil {
+		panic(err)
+	}
+
+	err = responses.JSON(w, 200, struct {
+		Data []models.System `json:"data"`
+	}{
+		Data: systems,
+	})
+	if err != nil {
+		panic(err)
+	}
+}
+
+func (c *SystemController) Show(w http.ResponseWriter, r *http.Request) {
+	err := responses.JSON(w, 200, c.system(r))
+	if err != nil {
+		panic(err)
+	}
+}
+
+func (c *SystemController) Create(w http.ResponseWriter, r *http.Request) {
+	var req CreateSystemRequest
+	err := c.Validate(&req, r)
+	if err != nil {
+		panic(err)
+	}
+
+	secret := models.GenerateSecretKey()
+	system := models.System{
+		Name:           req.Name,
+		URL:            req.URL,
+		Scopes:         strings.Join(req.Scopes, " "),
+		PlainSecretKey: null.StringFrom(secret),
+	}
+	err = system.SealSigningSecret(c.App.SecretBox)
+	if err != nil {
+		panic(err)
+	}
+	err = system.Insert(c.App.DB)
+	if err != nil {
+		panic(err)
+	}
+
+	err = responses.JSON(w, 201, systemWithSecret{System: &system, Secret: secret})
+	if err != nil {
+		panic(err)
+	}
+}
+
+// RotateSecret replaces the secret of the system. Tokens issued with the old
+// secret stay valid until they expire.
+func (c *SystemController) RotateSecret(w http.ResponseWriter, r *http.Request) {
+	system := c.system(r)
+	if system.RevokedAt.Valid {
+		c.NotFound()
+	}
+
+	secret := models.GenerateSecretKey()
+	system.PlainSecretKey = null.StringFrom(secret)
+	err := system.SealSigningSecret(c.App.SecretBox)
+	if err != nil {
+		panic(err)
+	}
+	err = system.Update(c.App.DB)
+	if err != nil {
+		panic(err)
+	}
+
+	err = responses.JSON(w, 200, systemWithSecret{System: system, Secret: secret})
+	if err != nil {
+		panic(err)
+	}
+}
+
+// Revoke disables the system and revokes every active token issued to it.
+func (c *SystemController) Revoke(w http.ResponseWriter, r *http.Request) {
+	system := c.system(r)
+
+	if !system.RevokedAt.Valid {
+		system.RevokedAt = null.TimeFrom(time.Now())
+		err := system.Update(c.App.DB)
+		if err != nil {
+			panic(err)
+		}
+	}
+
+	count, err := c.App.Auth.RevokeSystemTokens(system.ID)
+	if err != nil {
+		panic(err)
+	}
+
+	err = responses.JSON(w, 200, struct {
+		OK      bool `json:"ok"`
+		Revoked int  `json:"revoked"`
+	}{
+		OK:      true,
+		Revoked: count,
+	})
+	if err != nil {
+		panic(err)
+	}
+}
@@ -0,0 +1,142 @@
+package admin
+
+import (
+	"errors"
+	"net"
+	"time"
+
+	"be20250107/internal/models"
+	"be20250107/internal/modules/lockout"
+	"be20250107/internal/reqdata"
+
+	validation "github.com/go-ozzo/ozzo-validation/v4"
+)
+
+type CreateSystemRequest struct {
+	Name   string   `json:"name"`
+	URL    string   `json:"url"`
+	Scopes []string `json:"scopes"`
+}
+
+func (r CreateSystemRequest) Authorized(_ *reqdata.Context) bool {
+	return true
+}
+
+func (r CreateSystemRequest) Validate(_ *reqdata.Context) error {
+	return validation.ValidateStruct(&r,
+		validation.Field(&r.Name, validation.Required, validation.Length(1, 255)),
+		validation.Field(&r.URL, validation.Length(0, 255)),
+		validation.Field(&r.Scopes, validation.Required, validation.Each(validation.In(availableScopes()...))),
+	)
+}
+
+func availableScopes() []any {
+	scopes := make([]any, len(models.AvailableScopes))
+	for i, s := range models.AvailableScopes {
+		scopes[i] = s
+	}
+	return scopes
+}
+
+type CreateAPIKeyRequest struct {
+	Name       string     `json:"name"`
+	Scopes     []string   `json:"scopes"`
+	AllowedIPs []string   `json:"allowed_ips"`
+	DailyQuota *int64     `json:"daily_quota"`
+	ExpiredAt  *time.Time `json:"expired_at"`
+}
+
+func (r CreateAPIKeyRequest) Authorized(_ *reqdata.Context) bool {
+	return true
+}
+
+func (r CreateAPIKeyRequest) Validate(_ *reqdata.Context) error {
+	return validation.ValidateStruct(&r,
+		validation.Field(&r.Name, validation.Required, validation.Length(1, 255)),
+		validation.Field(&r.Scopes, validation.Required, validation.Each(validation.In(availableScopes()...))),
+		validation.Field(&r.AllowedIPs, validation.Each(validation.By(validateIPOrCIDR))),
+		validation.Field(&r.DailyQuota, validation.NilOrNotEmpty, validation.Min(int64(1))),
+		validation.Field(&r.ExpiredAt, validation.By(func(value interface{}) error {
+			if t, ok := value.(*time.Time); ok && t != nil && !t.After(time.Now()) {
+				return errors.New("must be in the future")
+			}
+			return nil
+		})),
+	)
+}
+
+func validateIPOrCIDR(value interface{}) error {
+	s, _ := value.(string)
+	if _, _, err := net.ParseCIDR(s); err == nil {
+		return nil
+	}
+	if net.ParseIP(s) != nil {
+		return nil
+	}
+	return errors.New("must be an IP address or CIDR range")
+}
+
+// LockoutRequest addresses the lockout of either a client IP or a user
+// credential.
+type LockoutRequest struct {
+	IP         string `json:"ip"`
+	Medium     string `json:"medium"`
+	Credential string `json:"credential"`
+}
+
+func (r LockoutRequest) Authorized(_ *reqdata.Context) bool {
+	return true
+}
+
+func (r LockoutRequest) Validate(_ *reqdata.Context) error {
+	return validation.ValidateStruct(&r,
+		validation.Field(&r.IP, validation.When(r.Medium == "" && r.Credential == "", validation.Required), validation.By(validateIP)),
+		validation.Field(&r.Medium, validation.When(r.IP == "", validation.Required), validation.By(func(value interface{}) error {
+			if _, ok := models.NormalizeContactMedium(value.(string)); !ok && value != "" {
+				return errors.New("must be a valid contact medium")
+			}
+			return nil
+		})),
+		validation.Field(&r.Credential, validation.When(r.IP == "", validation.Required), validation.By(func(value interface{}) error {
+			if _, ok := r.credential(); !ok && value != "" && r.Medium != "" {
+				return errors.New("does not match the format of the medium")
+			}
+			return nil
+		})),
+	)
+}
+
+func (r LockoutRequest) credential() (string, bool) {
+	medium, _ := models.NormalizeContactMedium(r.Medium)
+	credential, ok := models.NormalizeContactValue(medium, r.Credential)
+	return lockout.CredentialKey(medium, credential), ok
+}
+
+func (r LockoutRequest) credentialKey() string {
+	key, _ := r.credential()
+	return key
+}
+
+func validateIP(value interface{}) error {
+	s, _ := value.(string)
+	if s != "" && net.ParseIP(s) == nil {
+		return errors.New("must be an IP address")
+	}
+	return nil
+}
+
+// UpdateRoleTwoFactorRequest sets whether admins holding a role must use
+// two-factor authentication.
+type UpdateRoleTwoFactorRequest struct {
+	RequireTwoFactor *bool `json:"require_two_factor"`
+}
+
+func (r UpdateRoleTwoFactorRequest) Authorized(_ *reqdata.Context) bool {
+	return true
+}
+
+func (r UpdateRoleTwoFactorRequest) Validate(_ *reqdata.Context) error {
+	return validation.ValidateStruct(&r,
+		validation.Field(&r.RequireTwoFactor, validation.NotNil),
+	)
+}
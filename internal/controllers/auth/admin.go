@@ -5,16 +5,16 @@ import (
 	"fmt"
 	"log"
 	"net/http"
-	"time"
 
 	"be20250107/internal/app"
 	"be20250107/internal/controllers"
 	"be20250107/internal/middlewares"
 	"be20250107/internal/models"
-	"be20250107/internal/modules/cache"
+	"be20250107/internal/modules/authentication"
 	"be20250107/internal/modules/mq"
 	"be20250107/internal/responses"
 
+	"github.com/go-chi/chi/v5"
 	"github.com/oklog/ulid/v2"
 	"gopkg.in/guregu/null.v4"
 )
@@ -27,33 +27,96 @@ func NewAuthAdminController(app *app.Registry) *AuthAdminController {
 	return &AuthAdminController{controllers.Controller{App: app}}
 }
 
-func (c *AuthAdminController) LoginByXinchuanAuth(w http.ResponseWriter, r *http.Request) {
-	var req controllers.LoginByXinchuanAuthRequest
+// provider resolves the identity provider named in the URL, defaulting to
+// Xinchuan SSO for the legacy login route.
+func (c *AuthAdminController) provider(r *http.Request) authentication.IdentityProvider {
+	name := chi.URLParam(r, "Provider")
+	if name == "" {
+		name = authentication.ProviderXinchuanAuth
+	}
+
+	provider, err := c.App.Auth.Providers.Get(name)
+	if err != nil {
+		c.NotFound()
+	}
+	return provider
+}
+
+func (c *AuthAdminController) Providers(w http.ResponseWriter, r *http.Request) {
+	err := responses.JSON(w, 200, struct {
+		Data []string `json:"data"`
+	}{
+		Data: c.App.Auth.Providers.Names(),
+	})
+	if err != nil {
+		panic(err)
+	}
+}
+
+func (c *AuthAdminController) AuthorizeURL(w http.ResponseWriter, r *http.Request) {
+	provider := c.provider(r)
+
+	u, err := provider.AuthorizeURL(r.URL.Query().Get("state"))
+	if err != nil {
+		panic(err)
+	}
+
+	err = responses.JSON(w, 200, struct {
+		Provider string `json:"provider"`
+		URL      string `json:"url"`
+	}{
+		Provider: provider.Name(),
+		URL:      u,
+	})
+	if err != nil {
+		panic(err)
+	}
+}
+
+func (c *AuthAdminController) Login(w http.ResponseWriter, r *http.Request) {
+	var req controllers.LoginByProviderRequest
 	err := c.Validate(&req, r)
 	if err != nil {
 		panic(err)
 	}
 
-	auth := c.App.Auth.XinchuanAuth
-	oToken, err := auth.Authenticate(req.Code)
+	provider := c.provider(r)
+	attempt := newLoginAttempt(c.App, r, models.AccountTypeAdmin, "")
+	attempt.log.AuthProvider = provider.Name()
+	attempt.log.DeviceID = req.DeviceID
+	attempt.Guard()
+
+	oToken, err := provider.Exchange(req.Code)
 	if err != nil {
+		attempt.Fail(models.LoginFailureInvalidCredential)
 		panic(err)
 	}
 
-	ssoUser, err := auth.FetchAccount(oToken)
+	account, err := provider.FetchAccount(oToken)
 	if err != nil {
 		panic(err)
 	}
 
-	admin, exist, err := models.GetAdminByProviderAndProviderID(c.App.DB, ssoUser.ID, "xinchuan-auth")
+	admin, exist, err := models.GetAdminByProviderAndProviderID(c.App.DB, account.ID, provider.Name())
 	if err != nil {
 		panic(err)
 	}
 
+	changed := false
 	if !exist {
+		if account.IsDeactivated() {
+			attempt.log.Credential = account.ID
+			attempt.Fail(models.LoginFailureDeactivated)
+			c.Forbidden()
+		}
+
 		loop := 0
 		maxLoop := 5
-		username := ssoUser.Username
+		baseUsername := account.Username
+		if baseUsername == "" {
+			baseUsername = account.ID
+		}
+		username := baseUsername
 
 		for {
 			if loop >= maxLoop {
@@ -70,37 +133,78 @@ func (c *AuthAdminController) LoginByXinchuanAuth(w http.ResponseWriter, r *http
 				break
 			} else {
 				uid := ulid.Make()
-				username = ssoUser.Username + "-" + uid.String()[:4]
+				username = baseUsername + "-" + uid.String()[:4]
 			}
 		}
 
 		admin = &models.Admin{
-			Name:          ssoUser.Name,
+			Name:          account.Name,
 			Username:      username,
-			Provider:      "xinchuan-auth",
-			ProviderID:    fmt.Sprintf("%d", ssoUser.ID),
+			Provider:      provider.Name(),
+			ProviderID:    account.ID,
 			DeactivatedAt: null.Time{},
 		}
 		err = admin.Insert(c.App.DB)
 		if err != nil {
 			panic(err)
 		}
+		changed = true
 	}
 
-	err = mq.PublishMessage(c.App.MessageProducer, mq.AdminUpdatedTopic, mq.AdminUpdatedMsg{
-		AdminID: admin.ID,
-	})
+	synced, err := c.App.Auth.SyncAdmin(admin, provider, account)
 	if err != nil {
-		log.Println("[Admin.LoginByXinchuanAuth] publish updated:", err)
+		panic(err)
+	}
+	changed = changed || synced
+
+	if oToken.RefreshToken != "" {
+		sealed, err := models.SealProviderRefreshToken(c.App.SecretBox, oToken.RefreshToken)
+		if err != nil {
+			panic(err)
+		}
+		err = admin.UpdateProviderRefreshToken(c.App.DB, sealed)
+		if err != nil {
+			panic(err)
+		}
+	}
+
+	if changed {
+		err = mq.PublishMessage(c.App.MessageBus, mq.AdminUpdatedTopic, mq.AdminUpdatedMsg{
+			AdminID: admin.ID,
+		})
+		if err != nil {
+			log.Println("[Admin.Login] publish updated:", err)
+		}
+	}
+
+	if admin.DeactivatedAt.Valid {
+		attempt.log.AccountID = admin.ID
+		attempt.log.Credential = account.ID
+		attempt.Fail(models.LoginFailureDeactivated)
+		c.Forbidden()
 	}
 
-	resp := controllers.GenerateAccessToken(c.App, admin, 24*time.Hour, controllers.AuthTokenContext{
-		AuthProvider:       "xinchuan-auth",
+	//	Admins that need a second factor get a token that is only accepted by
+	//	the two-factor routes until they pass the challenge.
+	twoFactor, err := admin.RequiresTwoFactor(c.App.DB)
+	if err != nil {
+		panic(err)
+	}
+	claims := map[string]any{}
+	if twoFactor {
+		claims[authentication.ClaimTwoFactorPending] = true
+	}
+
+	resp := controllers.GenerateAccessToken(c.App, admin, adminTokenDuration, controllers.AuthTokenContext{
+		AuthProvider:       provider.Name(),
+		DeviceID:           req.DeviceID,
 		RequestFingerprint: controllers.GetRequestFingerprint(r),
+		Claims:             claims,
 	}, map[string]any{
-		"via": "xinchuan-auth",
+		"via": provider.Name(),
 		"as":  "admin",
 	})
+	resp.TwoFactorPending = twoFactor
 	err = responses.JSON(w, 200, resp)
 	if err != nil {
 		panic(err)
@@ -127,13 +231,7 @@ func (c *AuthAdminController) Me(w http.ResponseWriter, r *http.Request) {
 func (c *AuthAdminController) Logout(w http.ResponseWriter, r *http.Request) {
 	auth := r.Context().Value(middlewares.ContextAuth).(*middlewares.AdminAuthInformation)
 
-	id := auth.TokenID()
-	var expiredAt time.Time
-	if val, exists := auth.Expiration(); exists {
-		expiredAt = val
-	}
-
-	token, exist, err := models.GetAdminAccessTokenByID(c.App.DB, id)
+	token, exist, err := models.GetAdminAccessTokenByID(c.App.DB, auth.TokenID())
 	if err != nil {
 		panic(err)
 	}
@@ -142,27 +240,74 @@ func (c *AuthAdminController) Logout(w http.ResponseWriter, r *http.Request) {
 		panic(fmt.Errorf("access token not found"))
 	}
 
-	token.RevokedAt = null.TimeFrom(time.Now())
-	err = token.Update(c.App.DB)
+	err = c.App.Auth.Revoke(token)
+	if err != nil {
+		panic(err)
+	}
+
+	err = responses.JSON(w, 200, struct {
+		OK bool `json:"ok"`
+	}{
+		OK: true,
+	})
+	if err != nil {
+		panic(err)
+	}
+
+}
+
+// Sessions lists the active access tokens of the logged in admin together with
+// the device and network information recorded when each one was issued.
+func (c *AuthAdminController) Sessions(w http.ResponseWriter, r *http.Request) {
+	auth := c.AssertAuthenticated(r)
+
+	tokens, err := models.GetActiveAdminAccessTokens(c.App.DB, auth.UserID())
 	if err != nil {
 		panic(err)
 	}
-	id = token.ID
-	if token.ExpiredAt.Valid {
-		expiredAt = token.ExpiredAt.Time
+
+	sessions := make([]responses.Session, 0, len(tokens))
+	for _, t := range tokens {
+		sessions = append(sessions, responses.Session{
+			ID:           t.ID,
+			AuthProvider: t.AuthProvider,
+			DeviceID:     t.DeviceID,
+			IPAddress:    t.IPAddress,
+			UserAgent:    t.UserAgent,
+			Current:      t.ID == auth.TokenID(),
+			CreatedAt:    t.CreatedAt,
+			ExpiredAt:    t.ExpiredAt,
+		})
 	}
 
-	opt := cache.Options{}
-	if !expiredAt.IsZero() {
-		duration := time.Until(expiredAt)
-		if duration > 0 {
-			opt.Expiration = duration + time.Hour
-		}
+	err = responses.JSON(w, 200, struct {
+		Data []responses.Session `json:"data"`
+	}{
+		Data: sessions,
+	})
+	if err != nil {
+		panic(err)
 	}
-	err = c.App.Cache.PutValue(fmt.Sprintf("auth:revoked_%s", id), true, &opt)
+}
+
+// RevokeSession revokes a single session of the logged in admin. Sessions of
+// other admins are reported as not found.
+func (c *AuthAdminController) RevokeSession(w http.ResponseWriter, r *http.Request) {
+	auth := c.AssertAuthenticated(r)
+
+	token, exist, err := models.GetAdminAccessTokenByID(c.App.DB, chi.URLParam(r, "SessionID"))
 	if err != nil {
 		panic(err)
 	}
+	if !exist || token.AdminID != auth.UserID() {
+		c.NotFound()
+	}
+
+	if token.IsActive() {
+		if err := c.App.Auth.Revoke(token); err != nil {
+			panic(err)
+		}
+	}
 
 	err = responses.JSON(w, 200, struct {
 		OK bool `json:"ok"`
@@ -172,5 +317,26 @@ func (c *AuthAdminController) Logout(w http.ResponseWriter, r *http.Request) {
 	if err != nil {
 		panic(err)
 	}
+}
+
+// RevokeAllSessions logs the admin out everywhere by revoking every active
+// session, including the one used to make this request.
+func (c *AuthAdminController) RevokeAllSessions(w http.ResponseWriter, r *http.Request) {
+	auth := c.AssertAuthenticated(r)
+
+	count, err := c.App.Auth.RevokeAdminTokens(auth.UserID())
+	if err != nil {
+		panic(err)
+	}
 
+	err = responses.JSON(w, 200, struct {
+		OK      bool `json:"ok"`
+		Revoked int  `json:"revoked"`
+	}{
+		OK:      true,
+		Revoked: count,
+	})
+	if err != nil {
+		panic(err)
+	}
 }
@@ -0,0 +1,259 @@
+package auth
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"be20250107/internal/constants"
+	"be20250107/internal/controllers"
+	httperr "be20250107/internal/errors"
+	"be20250107/internal/models"
+	"be20250107/internal/modules/authentication"
+	"be20250107/internal/modules/totp"
+	"be20250107/internal/responses"
+
+	validation "github.com/go-ozzo/ozzo-validation/v4"
+	"gopkg.in/guregu/null.v4"
+)
+
+const adminTokenDuration = 24 * time.Hour
+
+func (c *AuthAdminController) admin(r *http.Request) *models.Admin {
+	admin, err := controllers.GetAdminFromAuth(c.AssertAuthenticated(r))
+	if err != nil {
+		panic(err)
+	}
+	return admin
+}
+
+// verifyTwoFactor checks the TOTP code, or the recovery code if allowed, in
+// the request against the lockout of the admin. It panics with a validation
+// error if the code is wrong.
+func (c *AuthAdminController) verifyTwoFactor(r *http.Request, admin *models.Admin, req TwoFactorCodeRequest, allowRecovery bool) {
+	attempt := newLoginAttempt(c.App, r, models.AccountTypeAdmin, models.AccountTypeAdmin+":"+admin.ID)
+	attempt.log.AccountID = admin.ID
+	attempt.log.AuthProvider = "totp"
+	attempt.Guard()
+
+	if req.Code == "" && allowRecovery {
+		used, err := models.UseRecoveryCode(c.App.DB, admin.ID, req.RecoveryCode)
+		if err != nil {
+			panic(err)
+		}
+		if !used {
+			attempt.Fail(models.LoginFailureInvalidCredential)
+			panic(validation.Errors{"recovery_code": validation.NewError("invalid_code", "invalid code")})
+		}
+		attempt.Succeed()
+		return
+	}
+
+	err := c.App.Auth.VerifyTOTP(admin, req.Code)
+	if errors.Is(err, authentication.ErrInvalidTOTP) || errors.Is(err, authentication.ErrTOTPReused) {
+		attempt.Fail(models.LoginFailureInvalidCredential)
+		panic(validation.Errors{"code": validation.NewError("invalid_code", "invalid code")})
+	} else if err != nil {
+		panic(err)
+	}
+	attempt.Succeed()
+
+	if err := admin.ResealTOTPSecret(c.App.DB, c.App.SecretBox); err != nil {
+		panic(err)
+	}
+}
+
+// completeTwoFactor replaces a token issued before the challenge was passed
+// with one that is not. It returns nil if the current token is not pending.
+func (c *AuthAdminController) completeTwoFactor(r *http.Request, admin *models.Admin) *responses.AuthToken {
+	auth := c.AssertAuthenticated(r)
+	if !authentication.IsTwoFactorPending(auth.Token()) {
+		return nil
+	}
+
+	token, exist, err := models.GetAdminAccessTokenByID(c.App.DB, auth.TokenID())
+	if err != nil {
+		panic(err)
+	}
+	if !exist {
+		panic(httperr.ErrUnauthenticated)
+	}
+	if err := c.App.Auth.Revoke(token); err != nil {
+		panic(err)
+	}
+
+	resp := controllers.GenerateAccessToken(c.App, admin, adminTokenDuration, controllers.AuthTokenContext{
+		AuthProvider:       "totp",
+		DeviceID:           token.DeviceID.ValueOrZero(),
+		RequestFingerprint: controllers.GetRequestFingerprint(r),
+	}, map[string]any{
+		"via": "totp",
+		"as":  "admin",
+	})
+	return &resp
+}
+
+// TwoFactorEnroll starts TOTP enrollment by generating a new secret, which is
+// stored encrypted. The secret is used once the admin activates it with a
+// valid code.
+func (c *AuthAdminController) TwoFactorEnroll(w http.ResponseWriter, r *http.Request) {
+	admin := c.admin(r)
+	if admin.HasTwoFactor() {
+		panic(httperr.NewErrUnprocessableEntity("two_factor_enabled", "two-factor authentication is already enabled", nil))
+	}
+
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		panic(err)
+	}
+	sealed, err := models.SealTOTPSecret(c.App.SecretBox, secret)
+	if err != nil {
+		panic(err)
+	}
+	if err := admin.UpdateTOTP(c.App.DB, sealed, null.Time{}); err != nil {
+		panic(err)
+	}
+
+	err = responses.JSON(w, 200, struct {
+		Secret string `json:"secret"`
+		URI    string `json:"uri"`
+	}{
+		Secret: secret,
+		URI:    totp.ProvisioningURI(constants.TokenIssuer, admin.Username, secret),
+	})
+	if err != nil {
+		panic(err)
+	}
+}
+
+// TwoFactorActivate completes enrollment with a code from the new secret and
+// returns the recovery codes. A token issued before the challenge is replaced.
+func (c *AuthAdminController) TwoFactorActivate(w http.ResponseWriter, r *http.Request) {
+	var req TwoFactorCodeRequest
+	if err := c.Validate(&req, r); err != nil {
+		panic(err)
+	}
+
+	admin := c.admin(r)
+	if admin.HasTwoFactor() {
+		panic(httperr.NewErrUnprocessableEntity("two_factor_enabled", "two-factor authentication is already enabled", nil))
+	}
+	if !admin.TOTPSecret.Valid {
+		panic(httperr.NewErrUnprocessableEntity("two_factor_not_enrolled", "start two-factor enrollment first", nil))
+	}
+	c.verifyTwoFactor(r, admin, req, false)
+
+	tx, err := c.App.DB.Beginx()
+	if err != nil {
+		panic(err)
+	}
+	defer tx.Rollback()
+
+	if err := admin.UpdateTOTP(tx, admin.TOTPSecret, null.TimeFrom(time.Now())); err != nil {
+		panic(err)
+	}
+	codes, err := models.GenerateRecoveryCodes(tx, admin.ID)
+	if err != nil {
+		panic(err)
+	}
+	if err := tx.Commit(); err != nil {
+		panic(err)
+	}
+
+	err = responses.JSON(w, 200, struct {
+		RecoveryCodes []string             `json:"recovery_codes"`
+		Token         *responses.AuthToken `json:"token,omitempty"`
+	}{
+		RecoveryCodes: codes,
+		Token:         c.completeTwoFactor(r, admin),
+	})
+	if err != nil {
+		panic(err)
+	}
+}
+
+// TwoFactorChallenge exchanges a token issued at login for one that can access
+// privileged routes, given a TOTP or recovery code.
+func (c *AuthAdminController) TwoFactorChallenge(w http.ResponseWriter, r *http.Request) {
+	var req TwoFactorCodeRequest
+	if err := c.Validate(&req, r); err != nil {
+		panic(err)
+	}
+
+	admin := c.admin(r)
+	if !admin.HasTwoFactor() {
+		panic(httperr.NewErrUnprocessableEntity("two_factor_not_enrolled", "two-factor authentication is not enabled", nil))
+	}
+	if !authentication.IsTwoFactorPending(c.AssertAuthenticated(r).Token()) {
+		panic(httperr.NewErrUnprocessableEntity("two_factor_passed", "the two-factor challenge has already been passed", nil))
+	}
+	c.verifyTwoFactor(r, admin, req, true)
+
+	if err := responses.JSON(w, 200, c.completeTwoFactor(r, admin)); err != nil {
+		panic(err)
+	}
+}
+
+// TwoFactorRecoveryCodes replaces the recovery codes of the admin.
+func (c *AuthAdminController) TwoFactorRecoveryCodes(w http.ResponseWriter, r *http.Request) {
+	var req TwoFactorCodeRequest
+	if err := c.Validate(&req, r); err != nil {
+		panic(err)
+	}
+
+	admin := c.admin(r)
+	if !admin.HasTwoFactor() {
+		panic(httperr.NewErrUnprocessableEntity("two_factor_not_enrolled", "two-factor authentication is not enabled", nil))
+	}
+	c.verifyTwoFactor(r, admin, req, false)
+
+	codes, err := models.GenerateRecoveryCodes(c.App.DB, admin.ID)
+	if err != nil {
+		panic(err)
+	}
+
+	err = responses.JSON(w, 200, struct {
+		RecoveryCodes []string `json:"recovery_codes"`
+	}{
+		RecoveryCodes: codes,
+	})
+	if err != nil {
+		panic(err)
+	}
+}
+
+// TwoFactorDisable turns TOTP off unless the role of the admin enforces it.
+func (c *AuthAdminController) TwoFactorDisable(w http.ResponseWriter, r *http.Request) {
+	var req TwoFactorCodeRequest
+	if err := c.Validate(&req, r); err != nil {
+		panic(err)
+	}
+
+	admin := c.admin(r)
+	if !admin.HasTwoFactor() {
+		panic(httperr.NewErrUnprocessableEntity("two_factor_not_enrolled", "two-factor authentication is not enabled", nil))
+	}
+	if err := admin.LoadRole(c.App.DB, false); err != nil {
+		panic(err)
+	}
+	if admin.Role != nil && admin.Role.RequireTwoFactor {
+		panic(httperr.NewErrUnprocessableEntity("two_factor_required_by_role", "the role of the admin requires two-factor authentication", nil))
+	}
+	c.verifyTwoFactor(r, admin, req, false)
+
+	if err := admin.UpdateTOTP(c.App.DB, null.String{}, null.Time{}); err != nil {
+		panic(err)
+	}
+	if err := models.DeleteRecoveryCodes(c.App.DB, admin.ID); err != nil {
+		panic(err)
+	}
+
+	err := responses.JSON(w, 200, struct {
+		OK bool `json:"ok"`
+	}{
+		OK: true,
+	})
+	if err != nil {
+		panic(err)
+	}
+}
@@ -0,0 +1,98 @@
+package auth
+
+import (
+	"fmt"
+	"net/http"
+
+	"be20250107/internal/app"
+	"be20250107/internal/controllers"
+	"be20250107/internal/models"
+	"be20250107/internal/modules/lockout"
+	"be20250107/internal/reqdata"
+)
+
+// loginAttempt tracks a login from a client IP and, when known, for a
+// credential against the lockout guards. Failed attempts and lockouts are
+// recorded in the login log.
+type loginAttempt struct {
+	app        *app.Registry
+	ip         string
+	lockoutKey string
+	log        controllers.LoginLog
+}
+
+// newLoginAttempt starts tracking a login of the account type. lockoutKey is
+// the key of the credential in app.Registry.CredentialLockout, empty if the
+// credential is not known before the login succeeds.
+func newLoginAttempt(app *app.Registry, r *http.Request, accountType string, lockoutKey string) *loginAttempt {
+	return &loginAttempt{
+		app:        app,
+		ip:         reqdata.ClientIP(r),
+		lockoutKey: lockoutKey,
+		log: controllers.LoginLog{
+			LoginContext: controllers.LoginContext{
+				RequestFingerprint: controllers.GetRequestFingerprint(r),
+			},
+			AccountType: accountType,
+		},
+	}
+}
+
+// Guard panics with a *lockout.LockedError if the IP or the credential is
+// locked out.
+func (a *loginAttempt) Guard() {
+	err := a.app.IPLockout.Check(a.ip)
+	if err == nil && a.lockoutKey != "" {
+		err = a.app.CredentialLockout.Check(a.lockoutKey)
+	}
+	if locked, ok := err.(*lockout.LockedError); ok {
+		a.record(models.LoginFailureLockedOut, map[string]any{
+			"locked_key":   locked.Key,
+			"locked_until": locked.Until.Unix(),
+		})
+		panic(locked)
+	} else if err != nil {
+		panic(err)
+	}
+}
+
+// Fail records a failed attempt and any lockout it causes.
+func (a *loginAttempt) Fail(reason string) {
+	info := map[string]any{}
+	a.fail(a.app.IPLockout, "ip", a.ip, info)
+	if a.lockoutKey != "" {
+		a.fail(a.app.CredentialLockout, "credential", a.lockoutKey, info)
+	}
+	a.record(reason, info)
+}
+
+func (a *loginAttempt) fail(guard *lockout.Guard, scope string, key string, info map[string]any) {
+	locked, err := guard.Fail(key)
+	if err != nil {
+		panic(err)
+	}
+	if locked != nil {
+		info[scope+"_locked_until"] = locked.Until.Unix()
+		a.app.Log.Warning(fmt.Sprintf("[Lockout] %s %s locked out until %s", scope, key, locked.Until))
+	}
+}
+
+func (a *loginAttempt) record(reason string, info map[string]any) {
+	l := a.log
+	l.FailureReason = reason
+	l.AdditionalInfo = info
+	if _, err := controllers.RecordLogin(a.app, l); err != nil {
+		a.app.Log.Error(fmt.Sprintf("[loginAttempt.record] %v", err))
+	}
+}
+
+// Succeed forgets the failed attempts of the credential. Failures of the IP
+// expire on their own since a single IP may serve many clients.
+func (a *loginAttempt) Succeed() {
+	if a.lockoutKey == "" {
+		return
+	}
+	if err := a.app.CredentialLockout.Reset(a.lockoutKey); err != nil {
+		panic(err)
+	}
+}
@@ -0,0 +1,74 @@
+package auth
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"be20250107/internal/app"
+	"be20250107/internal/controllers"
+	"be20250107/internal/models"
+	"be20250107/internal/responses"
+)
+
+const systemTokenDuration = time.Hour
+
+type AuthSystemController struct {
+	controllers.Controller
+}
+
+func NewAuthSystemController(app *app.Registry) *AuthSystemController {
+	return &AuthSystemController{controllers.Controller{App: app}}
+}
+
+// Token issues an access token to a system using the OAuth 2.0 client
+// credentials grant. The credentials can be sent in the body or with HTTP
+// Basic authentication.
+func (c *AuthSystemController) Token(w http.ResponseWriter, r *http.Request) {
+	var req SystemTokenRequest
+	if id, secret, ok := r.BasicAuth(); ok {
+		req.ClientID = id
+		req.ClientSecret = secret
+	}
+	err := c.Validate(&req, r)
+	if err != nil {
+		panic(err)
+	}
+
+	attempt := newLoginAttempt(c.App, r, models.AccountTypeSystem, "")
+	attempt.log.AuthProvider = "client_credentials"
+	attempt.log.Credential = req.ClientID
+	attempt.Guard()
+
+	system, exist, err := models.GetSystemByID(c.App.DB, req.ClientID)
+	if err != nil {
+		panic(err)
+	}
+	if !exist {
+		//	Compare against an empty system anyway so unknown client IDs take
+		//	about as long to reject as wrong secrets.
+		system = &models.System{}
+	}
+	if !system.VerifySecretKey(req.ClientSecret) || !exist || system.RevokedAt.Valid {
+		attempt.Fail(models.LoginFailureInvalidCredential)
+		c.Unauthenticated()
+	}
+
+	scopes := system.GrantScopes(strings.Fields(req.Scope))
+	if len(scopes) == 0 {
+		c.Forbidden()
+	}
+
+	resp := controllers.GenerateAccessToken(c.App, system, systemTokenDuration, controllers.AuthTokenContext{
+		AuthProvider:       "client_credentials",
+		RequestFingerprint: controllers.GetRequestFingerprint(r),
+		Scopes:             scopes,
+	}, map[string]any{
+		"via": "client_credentials",
+		"as":  "system",
+	})
+	err = responses.JSON(w, 200, resp)
+	if err != nil {
+		panic(err)
+	}
+}
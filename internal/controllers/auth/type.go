@@ -21,25 +21,15 @@ type RequestLoginByCodeRequest struct {
 }
 
 func (r *RequestLoginByCodeRequest) Authorized(ctx *reqdata.Context) bool {
-	_, err := models.GetUserClient(ctx.App.DB, r.ClientID, r.ClientSecret)
-	if err != nil {
-		if !errors.Is(sql.ErrNoRows, err) {
-			panic(err)
-		}
-		return false
-	}
-	return true
+	return isUserClient(ctx, r.ClientID, r.ClientSecret)
 }
 
 func (r *RequestLoginByCodeRequest) Validate(_ *reqdata.Context) error {
 	return validation.ValidateStruct(r,
 		validation.Field(&r.ClientID, validation.Required),
 		validation.Field(&r.ClientSecret, validation.Required),
-
-		validation.Field(&r.Credential, validation.Required, validation.By(func(value interface{}) error {
-
-			return nil
-		})),
+		validation.Field(&r.Medium, validation.Required, validation.By(validateLoginMedium)),
+		validation.Field(&r.Credential, validation.Required, validation.By(validateCredential(r.Medium))),
 	)
 }
 
@@ -47,6 +37,7 @@ type LoginByCodeRequest struct {
 	ClientID     string `json:"client_id"`
 	ClientSecret string `json:"client_secret"`
 	Fingerprint  string `json:"fingerprint"`
+	DeviceID     string `json:"device_id"`
 	Info         string `json:"info"`
 	Medium       string `json:"medium"`
 	Credential   string `json:"credential"`
@@ -54,9 +45,23 @@ type LoginByCodeRequest struct {
 }
 
 func (r LoginByCodeRequest) Authorized(ctx *reqdata.Context) bool {
-	_, err := models.GetUserClient(ctx.App.DB, r.ClientID, r.ClientSecret)
+	return isUserClient(ctx, r.ClientID, r.ClientSecret)
+}
+
+func (r LoginByCodeRequest) Validate(_ *reqdata.Context) error {
+	return validation.ValidateStruct(&r,
+		validation.Field(&r.ClientID, validation.Required),
+		validation.Field(&r.ClientSecret, validation.Required),
+		validation.Field(&r.Medium, validation.Required, validation.By(validateLoginMedium)),
+		validation.Field(&r.Credential, validation.Required, validation.By(validateCredential(r.Medium))),
+		validation.Field(&r.Code, validation.Required),
+	)
+}
+
+func isUserClient(ctx *reqdata.Context, id string, secret string) bool {
+	_, err := models.GetUserClient(ctx.App.DB, id, secret)
 	if err != nil {
-		if !errors.Is(sql.ErrNoRows, err) {
+		if !errors.Is(err, sql.ErrNoRows) {
 			panic(err)
 		}
 		return false
@@ -64,23 +69,66 @@ func (r LoginByCodeRequest) Authorized(ctx *reqdata.Context) bool {
 	return true
 }
 
-func (r LoginByCodeRequest) Validate(ctx *reqdata.Context) error {
+func validateLoginMedium(value interface{}) error {
+	medium, _ := models.NormalizeContactMedium(value.(string))
+	if medium != models.ContactMediumEmail && medium != models.ContactMediumCatalogue {
+		return fmt.Errorf("must be either email or phone")
+	}
+	return nil
+}
+
+func validateCredential(medium string) validation.RuleFunc {
+	return func(value interface{}) error {
+		if _, _, ok := normalizeCredential(medium, value.(string)); !ok {
+			return fmt.Errorf("must be a valid email address or Taiwan mobile number")
+		}
+		return nil
+	}
+}
+
+// normalizeCredential returns the contact medium and the credential in the
+// form it is stored in: lowercased email or E.164 phone number.
+func normalizeCredential(medium string, credential string) (string, string, bool) {
+	medium, _ = models.NormalizeContactMedium(medium)
+	if medium != models.ContactMediumEmail && medium != models.ContactMediumCatalogue {
+		return "", "", false
+	}
+	credential, ok := models.NormalizeContactValue(medium, credential)
+	return medium, credential, ok
+}
+
+type SystemTokenRequest struct {
+	GrantType    string `json:"grant_type"`
+	ClientID     string `json:"client_id"`
+	ClientSecret string `json:"client_secret"`
+	Scope        string `json:"scope"`
+}
+
+func (r SystemTokenRequest) Authorized(_ *reqdata.Context) bool {
+	return true
+}
+
+func (r SystemTokenRequest) Validate(_ *reqdata.Context) error {
 	return validation.ValidateStruct(&r,
+		validation.Field(&r.GrantType, validation.Required, validation.In("client_credentials")),
 		validation.Field(&r.ClientID, validation.Required),
 		validation.Field(&r.ClientSecret, validation.Required),
+	)
+}
+
+// TwoFactorCodeRequest carries either a TOTP code or, where accepted, a
+// recovery code.
+type TwoFactorCodeRequest struct {
+	Code         string `json:"code"`
+	RecoveryCode string `json:"recovery_code"`
+}
 
-		validation.Field(&r.Credential, validation.Required),
-		validation.Field(&r.Code, validation.Required, validation.By(func(value interface{}) error {
-			if whitelistedAccounts[r.Credential] != nil && whitelistedAccounts[r.Credential].OTP == r.Code {
-				return nil
-			}
-			key := fmt.Sprintf("auth:code_%s_%s:%s", r.Medium, r.Credential, value.(string))
-			if exist, err := ctx.App.Cache.Has(key); err != nil {
-				return err
-			} else if !exist {
-				return validation.Errors{"code": fmt.Errorf("invalid code")}
-			}
-			return nil
-		})),
+func (r TwoFactorCodeRequest) Authorized(_ *reqdata.Context) bool {
+	return true
+}
+
+func (r TwoFactorCodeRequest) Validate(_ *reqdata.Context) error {
+	return validation.ValidateStruct(&r,
+		validation.Field(&r.Code, validation.When(r.RecoveryCode == "", validation.Required)),
 	)
 }
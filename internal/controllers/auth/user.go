@@ -0,0 +1,183 @@
+package auth
+
+import (
+	"crypto/subtle"
+	"errors"
+	"net/http"
+	"time"
+
+	"be20250107/internal/app"
+	"be20250107/internal/config"
+	"be20250107/internal/controllers"
+	"be20250107/internal/models"
+	"be20250107/internal/modules/lockout"
+	"be20250107/internal/modules/otp"
+	"be20250107/internal/responses"
+
+	validation "github.com/go-ozzo/ozzo-validation/v4"
+	"gopkg.in/guregu/null.v4"
+)
+
+const userTokenDuration = config.MaxTokenLifetime
+
+type AuthUserController struct {
+	controllers.Controller
+}
+
+func NewAuthUserController(app *app.Registry) *AuthUserController {
+	return &AuthUserController{controllers.Controller{App: app}}
+}
+
+// RequestCode sends a one-time login code to an email address or Taiwan
+// mobile number. The response is the same whether or not an account exists
+// for the credential.
+func (c *AuthUserController) RequestCode(w http.ResponseWriter, r *http.Request) {
+	var req RequestLoginByCodeRequest
+	err := c.Validate(&req, r)
+	if err != nil {
+		panic(err)
+	}
+
+	medium, credential, _ := normalizeCredential(req.Medium, req.Credential)
+	attempt := newLoginAttempt(c.App, r, models.AccountTypeUser, lockout.CredentialKey(medium, credential))
+	attempt.log.Credential = credential
+	attempt.log.ClientID = req.ClientID
+	attempt.Guard()
+
+	if c.testAccount(credential) == nil {
+		code, err := c.App.OTP.Generate(medium, credential)
+		if err != nil {
+			panic(err)
+		}
+		err = c.App.OTPSender.Send(medium, credential, code)
+		if err != nil {
+			panic(err)
+		}
+	}
+
+	err = responses.JSON(w, 200, struct {
+		OK        bool `json:"ok"`
+		ExpiresIn int  `json:"expires_in"`
+	}{
+		OK:        true,
+		ExpiresIn: int(c.App.OTP.TTL().Seconds()),
+	})
+	if err != nil {
+		panic(err)
+	}
+}
+
+// LoginByCode verifies a code sent by RequestCode and issues an access token.
+// A user is registered on the first login with a new credential.
+func (c *AuthUserController) LoginByCode(w http.ResponseWriter, r *http.Request) {
+	var req LoginByCodeRequest
+	err := c.Validate(&req, r)
+	if err != nil {
+		panic(err)
+	}
+
+	medium, credential, _ := normalizeCredential(req.Medium, req.Credential)
+	attempt := newLoginAttempt(c.App, r, models.AccountTypeUser, lockout.CredentialKey(medium, credential))
+	attempt.log.AuthProvider = "code"
+	attempt.log.Credential = credential
+	attempt.log.ClientID = req.ClientID
+	attempt.log.DeviceID = req.DeviceID
+	attempt.log.Fingerprint = req.Fingerprint
+	attempt.Guard()
+
+	if wl := c.testAccount(credential); wl == nil || subtle.ConstantTimeCompare([]byte(wl.OTP), []byte(req.Code)) != 1 {
+		err = c.App.OTP.Verify(medium, credential, req.Code)
+		if errors.Is(err, otp.ErrInvalidCode) {
+			attempt.Fail(models.LoginFailureInvalidCredential)
+			panic(validation.Errors{"code": validation.NewError("invalid_code", "invalid code")})
+		} else if errors.Is(err, otp.ErrTooManyAttempts) {
+			attempt.Fail(models.LoginFailureInvalidCredential)
+			panic(err)
+		} else if err != nil {
+			panic(err)
+		}
+	}
+	attempt.Succeed()
+
+	user := c.findOrCreateUser(medium, credential)
+	if user.DeactivatedAt.Valid {
+		attempt.log.AccountID = user.ID
+		attempt.Fail(models.LoginFailureDeactivated)
+		c.Forbidden()
+	}
+
+	resp := controllers.GenerateAccessToken(c.App, user, userTokenDuration, controllers.AuthTokenContext{
+		AuthProvider:       "code",
+		ClientID:           req.ClientID,
+		DeviceID:           req.DeviceID,
+		Fingerprint:        req.Fingerprint,
+		RequestFingerprint: controllers.GetRequestFingerprint(r),
+	}, map[string]any{
+		"via": "code",
+		"as":  "user",
+	})
+	err = responses.JSON(w, 200, resp)
+	if err != nil {
+		panic(err)
+	}
+}
+
+// testAccount returns the test account of the credential, or nil unless test
+// accounts are enabled.
+func (c *AuthUserController) testAccount(credential string) *WhitelistAccount {
+	if !c.App.OTPTestAccounts {
+		return nil
+	}
+	return whitelistedAccounts[credential]
+}
+
+// findOrCreateUser returns the user owning the verified credential, registering
+// a new user if the credential is unknown.
+func (c *AuthUserController) findOrCreateUser(medium string, credential string) *models.User {
+	contact, exist, err := models.GetUserContact(c.App.DB, medium, credential)
+	if err != nil {
+		panic(err)
+	}
+
+	if exist {
+		if !contact.VerifiedAt.Valid {
+			contact.VerifiedAt = null.TimeFrom(time.Now())
+			if err := contact.Update(c.App.DB); err != nil {
+				panic(err)
+			}
+		}
+
+		user, exist, err := models.GetUserByID(c.App.DB, contact.UserID)
+		if err != nil {
+			panic(err)
+		}
+		if !exist {
+			c.NotFound()
+		}
+		return user
+	}
+
+	tx, err := c.App.DB.Beginx()
+	if err != nil {
+		panic(err)
+	}
+	defer tx.Rollback()
+
+	user := models.User{}
+	if err := user.Insert(tx); err != nil {
+		panic(err)
+	}
+	contact = &models.UserContact{
+		UserID:     user.ID,
+		Medium:     medium,
+		Value:      credential,
+		VerifiedAt: null.TimeFrom(time.Now()),
+	}
+	if err := contact.Insert(tx); err != nil {
+		panic(err)
+	}
+	if err := tx.Commit(); err != nil {
+		panic(err)
+	}
+	return &user
+}
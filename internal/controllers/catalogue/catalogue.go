@@ -3,12 +3,16 @@ package controller
 import (
 	"be20250107/internal/app"
 	controllers "be20250107/internal/controllers"
+	"be20250107/internal/middlewares"
 	"be20250107/internal/responses"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"log"
 	"net/http"
 	"strconv"
 	"strings"
+	"time"
 
 	"be20250107/internal/models"
 
@@ -18,10 +22,63 @@ import (
 
 type CatalogueController struct {
 	controllers.Controller
+	cache *models.CatalogueCache
 }
 
 func NewCatalogueController(app *app.Registry) *CatalogueController {
-	return &CatalogueController{controllers.Controller{App: app}}
+	var catalogueCache *models.CatalogueCache
+	if cfg := app.Config.CatalogueCache; cfg.Enabled {
+		catalogueCache = models.NewCatalogueCache(app.Cache, cfg.TTL)
+		catalogueCache.Job = app.Config.PrometheusAPIJobName
+	}
+	return &CatalogueController{controllers.Controller{App: app}, catalogueCache}
+}
+
+// bypassCache reports whether the request asks to read the database, which
+// only admins may do.
+func (c *CatalogueController) bypassCache(r *http.Request) bool {
+	if !strings.Contains(r.Header.Get("Cache-Control"), "no-cache") {
+		return false
+	}
+	auth := c.RequestContext(r).Auth
+	return auth != nil && auth.AccountType() == models.AccountTypeAdmin
+}
+
+// catalogueView is a catalogue as returned by the reads. Price is nil, and left
+// out, for tokens without the prices:read scope.
+type catalogueView struct {
+	models.Catalogue
+	Price *float64 `json:"price,omitempty"`
+}
+
+// catalogueViews hides the prices of the catalogues unless the request may
+// read them.
+func (c *CatalogueController) catalogueViews(r *http.Request, catalogues ...models.Catalogue) []catalogueView {
+	canReadPrices := middlewares.GrantsScope(c.RequestContext(r).Auth, models.ScopePricesRead)
+	views := make([]catalogueView, len(catalogues))
+	for i, catalogue := range catalogues {
+		views[i].Catalogue = catalogue
+		if canReadPrices {
+			views[i].Price = &catalogue.Price
+		}
+	}
+	return views
+}
+
+// invalidateCache drops the cached reads carrying the tags once a write is
+// committed. Entries left over by a failure expire with the cache TTL.
+func (c *CatalogueController) invalidateCache(tags ...string) {
+	if err := c.cache.Invalidate(tags...); err != nil {
+		c.App.Log.Error(fmt.Sprintf("[CatalogueController] %v", err))
+	}
+}
+
+// publishEvents publishes the events of a committed write. A failure is only
+// logged, since the write cannot be undone.
+func (c *CatalogueController) publishEvents(events []models.Event) {
+	if err := models.PublishEvents(c.App.MessageBus, events...); err != nil {
+		c.App.Log.Error(fmt.Sprintf("[CatalogueController] %v", err))
+	}
 }
 func (c *CatalogueController) GetCatalogues(w http.ResponseWriter, r *http.Request) {
 	limitStr := r.URL.Query().Get("limit")
@@ -41,11 +98,23 @@ func (c *CatalogueController) GetCatalogues(w http.ResponseWriter, r *http.Reque
 		offset = 0 // Default offset
 	}
 
-	Catalogues, totalCount, err := models.GetCatalogues(c.App.DB, limit, offset, sortBy, order, filterBy, filterValue)
-	if err != nil {
+	page, result, err := c.cache.GetCatalogues(c.App.DB, models.CatalogueQuery{
+		Limit:       limit,
+		Offset:      offset,
+		SortBy:      sortBy,
+		Order:       order,
+		FilterBy:    filterBy,
+		FilterValue: filterValue,
+	}, c.bypassCache(r))
+	if errors.Is(err, models.ErrInvalidCatalogueQuery) {
+		http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+		return
+	} else if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
+	Catalogues, totalCount := page.Catalogues, page.TotalCount
+	w.Header().Set("X-Cache", string(result))
 
 	hasNext := false
 	if totalCount > offset+limit {
@@ -55,10 +124,10 @@ func (c *CatalogueController) GetCatalogues(w http.ResponseWriter, r *http.Reque
 	newOffset := offset + limit
 
 	if err = responses.JSON(w, 200, struct {
-		Data       []models.Catalogue           `json:"data"`
+		Data       []catalogueView              `json:"data"`
 		Pagination controllers.PaginationDetail `json:"pagination"`
 	}{
-		Data: Catalogues,
+		Data: c.catalogueViews(r, Catalogues...),
 		Pagination: controllers.PaginationDetail{
 			NextPageCursor: strconv.Itoa(newOffset),
 			PerPage:        limit,
@@ -78,13 +147,14 @@ func (c *CatalogueController) GetCatalogue(w http.ResponseWriter, r *http.Reques
 		return
 	}
 
-	Catalogue, err := models.GetCatalogue(c.App.DB, id)
+	Catalogue, result, err := c.cache.GetCatalogue(c.App.DB, id, c.bypassCache(r))
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
+	w.Header().Set("X-Cache", string(result))
 
-	render.JSON(w, r, Catalogue)
+	render.JSON(w, r, c.catalogueViews(r, Catalogue)[0])
 }
 
 // CreateCatalogue creates a new Catalogue record and inserts installment values
@@ -118,6 +188,14 @@ func (c *CatalogueController) CreateCatalogue(w http.ResponseWriter, r *http.Req
 		Catalogue.Price, _ = strconv.ParseFloat(r.FormValue("price"), 64)
 		Catalogue.CreatedBy = r.FormValue("created_by")
 		Catalogue.UpdatedBy = r.FormValue("updated_by")
+		if publishedAt := r.FormValue("published_at"); publishedAt != "" {
+			t, err := time.Parse(time.RFC3339, publishedAt)
+			if err != nil {
+				http.Error(w, "Invalid published_at format", http.StatusBadRequest)
+				return
+			}
+			Catalogue.PublishedAt = &t
+		}
 
 	} else if r.Header.Get("Content-Type") == "application/json" {
 		// Handle JSON payload directly
@@ -152,6 +230,8 @@ func (c *CatalogueController) CreateCatalogue(w http.ResponseWriter, r *http.Req
 				log.Printf("Failed to commit transaction: %v", err)
 				return
 			}
+			c.invalidateCache(models.CataloguesTag)
+			c.publishEvents(Catalogue.CreatedEvents())
 		}
 	}()
 
@@ -225,6 +305,9 @@ func (c *CatalogueController) UpdateCatalogue(w http.ResponseWriter, r *http.Req
 		http.Error(w, "Failed to commit transaction", http.StatusInternalServerError)
 		return
 	}
+	//	The catalogue tag also covers its installments.
+	c.invalidateCache(models.CataloguesTag, models.CatalogueTag(Catalogue.ID))
+	c.publishEvents(Catalogue.UpdatedEvents())
 
 	render.JSON(w, r, Catalogue)
 }
@@ -252,6 +335,8 @@ func (c *CatalogueController) DeleteCatalogue(w http.ResponseWriter, r *http.Req
 		http.Error(w, "Failed to commit transaction: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
+	c.invalidateCache(models.CataloguesTag, models.CatalogueTag(id))
+	c.publishEvents(Catalogue.DeletedEvents())
 
 	w.WriteHeader(http.StatusNoContent)
 }
@@ -0,0 +1,176 @@
+package controller
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"be20250107/internal/app"
+	"be20250107/internal/config"
+	"be20250107/internal/middlewares"
+	"be20250107/internal/models"
+	"be20250107/internal/modules/mq"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/jmoiron/sqlx"
+	"github.com/lestrrat-go/jwx/v2/jwt"
+)
+
+type testAuth struct {
+	accountType string
+	token       jwt.Token
+}
+
+func (a testAuth) IsLoggedIn() bool              { return true }
+func (a testAuth) TokenID() string               { return "token" }
+func (a testAuth) Token() jwt.Token              { return a.token }
+func (a testAuth) UserID() string                { return "account" }
+func (a testAuth) User() (any, error)            { return nil, nil }
+func (a testAuth) AccountType() string           { return a.accountType }
+func (a testAuth) Expiration() (time.Time, bool) { return time.Time{}, false }
+
+func TestBypassCache(t *testing.T) {
+	c := NewCatalogueController(&app.Registry{Config: &config.PublicConfig{}})
+
+	tests := []struct {
+		name         string
+		accountType  string
+		cacheControl string
+		expected     bool
+	}{
+		{"admin asking to bypass", models.AccountTypeAdmin, "no-cache", true},
+		{"admin reading", models.AccountTypeAdmin, "", false},
+		{"user asking to bypass", models.AccountTypeUser, "no-cache", false},
+		{"system asking to bypass", models.AccountTypeSystem, "max-age=0, no-cache", false},
+		{"anonymous asking to bypass", "", "no-cache", false},
+	}
+	for _, test := range tests {
+		r := httptest.NewRequest(http.MethodGet, "/catalogues", nil)
+		if test.cacheControl != "" {
+			r.Header.Set("Cache-Control", test.cacheControl)
+		}
+		if test.accountType != "" {
+			r = r.WithContext(context.WithValue(r.Context(), middlewares.ContextAuth, testAuth{accountType: test.accountType}))
+		}
+		if got := c.bypassCache(r); got != test.expected {
+			t.Errorf("%s: expected %v, got %v", test.name, test.expected, got)
+		}
+	}
+}
+
+func TestCatalogueViewsHidePrices(t *testing.T) {
+	c := NewCatalogueController(&app.Registry{Config: &config.PublicConfig{}})
+	withPrices := jwt.New()
+	withPrices.Set("scope", models.ScopeCatalogueRead+" "+models.ScopePricesRead)
+	withoutPrices := jwt.New()
+	withoutPrices.Set("scope", models.ScopeCatalogueRead)
+
+	tests := []struct {
+		name     string
+		auth     testAuth
+		expected bool
+	}{
+		{"admin", testAuth{models.AccountTypeAdmin, jwt.New()}, true},
+		{"user", testAuth{models.AccountTypeUser, jwt.New()}, true},
+		{"system with prices:read", testAuth{models.AccountTypeSystem, withPrices}, true},
+		{"system without prices:read", testAuth{models.AccountTypeSystem, withoutPrices}, false},
+		{"api key without prices:read", testAuth{models.AccountTypeAPIKey, withoutPrices}, false},
+	}
+	for _, test := range tests {
+		r := httptest.NewRequest(http.MethodGet, "/catalogues/1", nil)
+		r = r.WithContext(context.WithValue(r.Context(), middlewares.ContextAuth, test.auth))
+		body, err := json.Marshal(c.catalogueViews(r, models.Catalogue{ID: 1, Price: 100})[0])
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := strings.Contains(string(body), `"price":100`); got != test.expected {
+			t.Errorf("%s: expected the price to be shown %v, got %s", test.name, test.expected, body)
+		}
+	}
+}
+
+// txDB is a database/sql connector whose statements all succeed and whose
+// transactions fail to commit with commitErr.
+type txDB struct{ commitErr error }
+
+func (d *txDB) Connect(context.Context) (driver.Conn, error) { return txConn{d}, nil }
+func (d *txDB) Driver() driver.Driver                        { return nil }
+
+type txConn struct{ db *txDB }
+
+func (c txConn) Prepare(query string) (driver.Stmt, error) { return txStmt{}, nil }
+func (c txConn) Close() error                              { return nil }
+func (c txConn) Begin() (driver.Tx, error)                 { return c, nil }
+func (c txConn) Commit() error                             { return c.db.commitErr }
+func (c txConn) Rollback() error                           { return nil }
+
+type txStmt struct{}
+
+func (s txStmt) Close() error                                    { return nil }
+func (s txStmt) NumInput() int                                   { return -1 }
+func (s txStmt) Exec(args []driver.Value) (driver.Result, error) { return driver.RowsAffected(1), nil }
+func (s txStmt) Query(args []driver.Value) (driver.Rows, error) {
+	return nil, errors.New("not supported")
+}
+
+// recordingBus records the messages published on it.
+type recordingBus struct {
+	mq.Bus
+	topics []string
+	bodies [][]byte
+}
+
+func (b *recordingBus) Publish(topic string, body []byte) error {
+	b.topics = append(b.topics, topic)
+	b.bodies = append(b.bodies, body)
+	return nil
+}
+
+func deleteCatalogue(db *txDB, bus *recordingBus) int {
+	c := NewCatalogueController(&app.Registry{
+		Config:     &config.PublicConfig{},
+		DB:         sqlx.NewDb(sql.OpenDB(db), "mysql"),
+		MessageBus: bus,
+	})
+	r := httptest.NewRequest(http.MethodDelete, "/catalogues/7", nil)
+	rctx := chi.NewRouteContext()
+	rctx.URLParams.Add("CatalogueID", "7")
+	r = r.WithContext(context.WithValue(r.Context(), chi.RouteCtxKey, rctx))
+	w := httptest.NewRecorder()
+	c.DeleteCatalogue(w, r)
+	return w.Code
+}
+
+func TestDeleteCataloguePublishesAfterCommit(t *testing.T) {
+	bus := &recordingBus{}
+	if code := deleteCatalogue(&txDB{}, bus); code != http.StatusNoContent {
+		t.Fatalf("expected %d, got %d", http.StatusNoContent, code)
+	}
+	if len(bus.topics) != 1 || bus.topics[0] != mq.CatalogueDeletedTopic {
+		t.Fatalf("expected the deletion to be published, got %v", bus.topics)
+	}
+	var msg mq.CatalogueDeletedMsg
+	if err := json.Unmarshal(bus.bodies[0], &msg); err != nil {
+		t.Fatal(err)
+	}
+	if msg.CatalogueID != 7 {
+		t.Errorf("expected the deletion of catalogue 7, got %+v", msg)
+	}
+}
+
+func TestDeleteCatalogueDoesNotPublishWithoutCommit(t *testing.T) {
+	bus := &recordingBus{}
+	if code := deleteCatalogue(&txDB{commitErr: errors.New("connection lost")}, bus); code != http.StatusInternalServerError {
+		t.Fatalf("expected %d, got %d", http.StatusInternalServerError, code)
+	}
+	if len(bus.topics) != 0 {
+		t.Errorf("expected nothing to be published, got %v", bus.topics)
+	}
+}
@@ -1,13 +1,18 @@
 package controllers
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"errors"
 	"fmt"
 	"net/http"
+	"strings"
 	"time"
 
 	"be20250107/internal/app"
 	"be20250107/internal/models"
+	"be20250107/internal/modules/authentication"
+	"be20250107/internal/modules/mq"
 	"be20250107/internal/reqdata"
 	"be20250107/internal/responses"
 
@@ -17,15 +22,16 @@ import (
 	"gopkg.in/guregu/null.v4"
 )
 
-type LoginByXinchuanAuthRequest struct {
-	Code string `json:"code"`
+type LoginByProviderRequest struct {
+	Code     string `json:"code"`
+	DeviceID string `json:"device_id"`
 }
 
-func (r LoginByXinchuanAuthRequest) Authorized(_ *reqdata.Context) bool {
+func (r LoginByProviderRequest) Authorized(_ *reqdata.Context) bool {
 	return true
 }
 
-func (r LoginByXinchuanAuthRequest) Validate(_ *reqdata.Context) error {
+func (r LoginByProviderRequest) Validate(_ *reqdata.Context) error {
 	return validation.ValidateStruct(&r,
 		validation.Field(&r.Code, validation.Required))
 }
@@ -36,6 +42,19 @@ type AuthTokenContext struct {
 	DeviceID           string
 	Fingerprint        string
 	RequestFingerprint RequestFingerprint
+	// Scopes are added to the token as a space separated "scope" claim.
+	Scopes []string
+	// Claims are added to the token as they are.
+	Claims map[string]any
+}
+
+// BindingFingerprint returns the fingerprint the token is bound to, preferring
+// the one sent in the login request over the X-Device-Fingerprint header.
+func (c AuthTokenContext) BindingFingerprint() string {
+	if c.Fingerprint != "" {
+		return c.Fingerprint
+	}
+	return c.RequestFingerprint.DeviceFingerprint
 }
 
 type LoginContext struct {
@@ -49,6 +68,9 @@ type RequestFingerprint struct {
 	Forwarded    string `json:"forward"`
 	ForwardedFor string `json:"forwarded_for"`
 	RealIP       string `json:"real_ip"`
+	RemoteAddr   string `json:"remote_addr"`
+	// DeviceFingerprint is the X-Device-Fingerprint header.
+	DeviceFingerprint string `json:"device_fingerprint"`
 }
 
 func GetRequestFingerprint(r *http.Request) RequestFingerprint {
@@ -57,15 +79,106 @@ func GetRequestFingerprint(r *http.Request) RequestFingerprint {
 		Forwarded:    r.Header.Get("Forwarded"),
 		ForwardedFor: r.Header.Get("X-Forwarded-For"),
 		RealIP:       r.Header.Get("X-Real-IP"),
+		RemoteAddr:   r.RemoteAddr,
+
+		DeviceFingerprint: r.Header.Get(authentication.HeaderFingerprint),
 	}
 }
 
+// IP returns the client IP address, see reqdata.ResolveIP.
+func (f RequestFingerprint) IP() string {
+	return reqdata.ResolveIP(f.RealIP, f.ForwardedFor, f.RemoteAddr)
+}
+
+// LoginLog describes a successful or failed login stored by RecordLogin. A
+// login is successful when FailureReason is empty.
 type LoginLog struct {
 	LoginContext
-	ClientID          string         `json:"client_id"`
-	DeviceID          string         `json:"device_id"`
-	UserAccessTokenID string         `json:"user_access_token_id"`
-	AdditionalInfo    map[string]any `json:"additional_info"`
+	AccountType    string         `json:"account_type"`
+	AccountID      string         `json:"account_id"`
+	Credential     string         `json:"credential"`
+	ClientID       string         `json:"client_id"`
+	DeviceID       string         `json:"device_id"`
+	AccessTokenID  string         `json:"access_token_id"`
+	FailureReason  string         `json:"failure_reason"`
+	AdditionalInfo map[string]any `json:"additional_info"`
+}
+
+// LoginFingerprint identifies the device of a login by the fingerprint sent
+// by the client, its device ID or, failing both, its user agent.
+func (l LoginLog) LoginFingerprint() string {
+	if l.Fingerprint != "" {
+		return l.Fingerprint
+	}
+	if l.RequestFingerprint.DeviceFingerprint != "" {
+		return l.RequestFingerprint.DeviceFingerprint
+	}
+	if l.DeviceID != "" {
+		return "device:" + l.DeviceID
+	}
+	if ua := l.RequestFingerprint.UserAgent; ua != "" {
+		sum := sha256.Sum256([]byte(ua))
+		return "ua:" + hex.EncodeToString(sum[:])
+	}
+	return ""
+}
+
+// RecordLogin stores the login in login_logs, with the credential masked.
+func RecordLogin(app *app.Registry, l LoginLog) (*models.LoginLog, error) {
+	ip := l.RequestFingerprint.IP()
+	userAgent := l.RequestFingerprint.UserAgent
+	fingerprint := l.LoginFingerprint()
+	credential := models.MaskCredential(l.Credential)
+
+	log := models.LoginLog{
+		AccountType:   l.AccountType,
+		AccountID:     null.NewString(l.AccountID, l.AccountID != ""),
+		Credential:    null.NewString(credential, credential != ""),
+		AuthProvider:  null.NewString(l.AuthProvider, l.AuthProvider != ""),
+		Successful:    l.FailureReason == "",
+		FailureReason: null.NewString(l.FailureReason, l.FailureReason != ""),
+		IPAddress:     null.NewString(ip, ip != ""),
+		UserAgent:     null.NewString(userAgent, userAgent != ""),
+		ClientID:      null.NewString(l.ClientID, l.ClientID != ""),
+		DeviceID:      null.NewString(l.DeviceID, l.DeviceID != ""),
+		Fingerprint:   null.NewString(fingerprint, fingerprint != ""),
+		AccessTokenID: null.NewString(l.AccessTokenID, l.AccessTokenID != ""),
+	}
+	if err := log.SetAdditionalInfo(l.AdditionalInfo); err != nil {
+		return nil, err
+	}
+	if err := log.Insert(app.DB); err != nil {
+		return nil, err
+	}
+	return &log, nil
+}
+
+// recordLoginOrLog records the login and returns the ID of its log. A failure
+// is only logged, since it must not fail the login it describes.
+func recordLoginOrLog(app *app.Registry, l LoginLog) string {
+	loginLog, err := RecordLogin(app, l)
+	if err != nil {
+		app.Log.Error(fmt.Sprintf("[RecordLogin] %v", err))
+		return ""
+	}
+	return loginLog.ID
+}
+
+// isNewAdminDevice reports whether the admin has logged in before, but never
+// from the fingerprint.
+func isNewAdminDevice(app *app.Registry, adminID string, fingerprint string) (bool, error) {
+	if fingerprint == "" {
+		return false, nil
+	}
+	seen, err := models.HasLoginFingerprint(app.DB, models.AccountTypeAdmin, adminID, fingerprint)
+	if err != nil || seen {
+		return false, err
+	}
+	count, err := models.CountSuccessfulLogins(app.DB, models.AccountTypeAdmin, adminID)
+	if err != nil {
+		return false, err
+	}
+	return count > 0, nil
 }
 
 func GenerateAccessToken(app *app.Registry, user models.JWTAuthenticatable, d time.Duration, authCtx AuthTokenContext, info map[string]any) responses.AuthToken {
@@ -77,58 +190,119 @@ func GenerateAccessToken(app *app.Registry, user models.JWTAuthenticatable, d ti
 	if err != nil {
 		panic(err)
 	}
+	if fingerprint := authCtx.BindingFingerprint(); fingerprint != "" && app.Auth.BindsTokens() {
+		if err := token.Set(authentication.ClaimFingerprint, authentication.HashFingerprint(fingerprint)); err != nil {
+			panic(err)
+		}
+	}
+	for k, v := range authCtx.Claims {
+		if err := token.Set(k, v); err != nil {
+			panic(err)
+		}
+	}
+	if len(authCtx.Scopes) > 0 {
+		err = token.Set("scope", strings.Join(authCtx.Scopes, " "))
+		if err != nil {
+			panic(err)
+		}
+	}
 	sign, err := jwt.Sign(token, jwt.WithKey(jwa.RS256, app.SigningKey))
 	if err != nil {
 		panic(err)
 	}
 
-	tx := app.DB
+	expiredAt := null.TimeFrom(time.Now().Add(d))
+	ip := authCtx.RequestFingerprint.IP()
+	userAgent := authCtx.RequestFingerprint.UserAgent
+	login := LoginLog{
+		LoginContext: LoginContext{
+			AuthProvider:       authCtx.AuthProvider,
+			Fingerprint:        authCtx.Fingerprint,
+			RequestFingerprint: authCtx.RequestFingerprint,
+		},
+		AccountID:      token.Subject(),
+		ClientID:       authCtx.ClientID,
+		DeviceID:       authCtx.DeviceID,
+		AccessTokenID:  token.JwtID(),
+		AdditionalInfo: info,
+	}
+	newDevice := false
 
-	go func() {
-		expiredAt := null.TimeFrom(time.Now().Add(d))
-		additionalInfo := map[string]any{
-			"token_id":   token.JwtID(),
-			"expiration": expiredAt.Time,
+	switch u := user.(type) {
+	case *models.Admin:
+		t := models.AdminAccessToken{
+			Model: models.Model{
+				ID: token.JwtID(),
+			},
+			AdminID:      u.ID,
+			AuthProvider: null.NewString(authCtx.AuthProvider, authCtx.AuthProvider != ""),
+			DeviceID:     null.NewString(authCtx.DeviceID, authCtx.DeviceID != ""),
+			IPAddress:    null.NewString(ip, ip != ""),
+			UserAgent:    null.NewString(userAgent, userAgent != ""),
+			ExpiredAt:    expiredAt,
 		}
-		for k, v := range info {
-			additionalInfo[k] = v
+		err := t.Insert(app.DB)
+		if err != nil {
+			panic(fmt.Errorf("failed to save access token: %w", err))
 		}
+		login.AccountType = models.AccountTypeAdmin
 
-		switch u := user.(type) {
-		case *models.Admin:
-			t := models.AdminAccessToken{
-				Model: models.Model{
-					ID: token.JwtID(),
-				},
-				AdminID:   u.ID,
-				ExpiredAt: expiredAt,
-			}
-			err := t.Insert(tx)
-			if err != nil {
-				panic(fmt.Errorf("failed to save access token: %s", err.Error()))
-			}
-		case *models.System:
-			t := models.SystemAccessToken{
-				Model: models.Model{
-					ID: token.JwtID(),
-				},
-				SystemID:  u.ID,
-				ExpiredAt: expiredAt,
-			}
-			err := t.Insert(tx)
-			if err != nil {
-				panic(fmt.Errorf("failed to save access token: %s", err.Error()))
-			}
-
-		default:
-			panic(fmt.Errorf("GenerateAccessToken expects user to be*models.Admin or *models.System"))
+		newDevice, err = isNewAdminDevice(app, u.ID, login.LoginFingerprint())
+		if err != nil {
+			panic(err)
+		}
+	case *models.User:
+		t := models.UserAccessToken{
+			Model: models.Model{
+				ID: token.JwtID(),
+			},
+			UserID:    u.ID,
+			ClientID:  null.NewString(authCtx.ClientID, authCtx.ClientID != ""),
+			DeviceID:  null.NewString(authCtx.DeviceID, authCtx.DeviceID != ""),
+			IPAddress: null.NewString(ip, ip != ""),
+			UserAgent: null.NewString(userAgent, userAgent != ""),
+			ExpiredAt: expiredAt,
+		}
+		err := t.Insert(app.DB)
+		if err != nil {
+			panic(fmt.Errorf("failed to save access token: %w", err))
+		}
+		login.AccountType = models.AccountTypeUser
+	case *models.System:
+		t := models.SystemAccessToken{
+			Model: models.Model{
+				ID: token.JwtID(),
+			},
+			SystemID:  u.ID,
+			ExpiredAt: expiredAt,
+		}
+		err := t.Insert(app.DB)
+		if err != nil {
+			panic(fmt.Errorf("failed to save access token: %w", err))
 		}
-	}()
+		login.AccountType = models.AccountTypeSystem
+	default:
+		panic(fmt.Errorf("GenerateAccessToken expects user to be *models.Admin, *models.User or *models.System"))
+	}
+
+	loginLogID := recordLoginOrLog(app, login)
+
+	if newDevice {
+		err = mq.PublishMessage(app.MessageBus, mq.AdminNewDeviceTopic, mq.AdminNewDeviceMsg{
+			AdminID:    login.AccountID,
+			LoginLogID: loginLogID,
+		})
+		if err != nil {
+			app.Log.Error(fmt.Sprintf("[GenerateAccessToken] publish new device: %v", err))
+		}
+	}
 
 	return responses.AuthToken{
 		AccessToken: string(sign),
 		TokenType:   "Bearer",
 		ExpiresIn:   int(d.Seconds()),
+		Scope:       strings.Join(authCtx.Scopes, " "),
+		NewDevice:   newDevice,
 	}
 }
 
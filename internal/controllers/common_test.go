@@ -0,0 +1,118 @@
+package controllers
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+
+	"be20250107/internal/app"
+	"be20250107/internal/models"
+	"be20250107/internal/modules/logger"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// recordingDB is a database/sql connector that records the arguments of every
+// statement, or fails them all with err.
+type recordingDB struct {
+	mu   sync.Mutex
+	args [][]driver.Value
+	err  error
+}
+
+func (d *recordingDB) Connect(context.Context) (driver.Conn, error) { return recordingConn{d}, nil }
+func (d *recordingDB) Driver() driver.Driver                        { return nil }
+
+type recordingConn struct{ db *recordingDB }
+
+func (c recordingConn) Prepare(query string) (driver.Stmt, error) { return recordingStmt(c), nil }
+func (c recordingConn) Close() error                              { return nil }
+func (c recordingConn) Begin() (driver.Tx, error)                 { return nil, errors.New("not supported") }
+
+type recordingStmt struct{ db *recordingDB }
+
+func (s recordingStmt) Close() error  { return nil }
+func (s recordingStmt) NumInput() int { return -1 }
+func (s recordingStmt) Exec(args []driver.Value) (driver.Result, error) {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+	if s.db.err != nil {
+		return nil, s.db.err
+	}
+	s.db.args = append(s.db.args, args)
+	return driver.RowsAffected(1), nil
+}
+func (s recordingStmt) Query(args []driver.Value) (driver.Rows, error) {
+	return nil, errors.New("not supported")
+}
+
+type capturingWriter struct{ messages []string }
+
+func (w *capturingWriter) Write(message string, stackTrace []byte, payload map[string][]byte) {
+	w.messages = append(w.messages, message)
+}
+func (w *capturingWriter) Close() {}
+
+func newTestRegistry(db *recordingDB) (*app.Registry, *capturingWriter) {
+	w := &capturingWriter{}
+	return &app.Registry{
+		DB:  sqlx.NewDb(sql.OpenDB(db), "mysql"),
+		Log: &logger.Logger{Writers: map[logger.Level][]logger.Writer{logger.Error: {w}}},
+	}, w
+}
+
+func TestRecordLoginMasksCredential(t *testing.T) {
+	db := &recordingDB{}
+	registry, _ := newTestRegistry(db)
+
+	log, err := RecordLogin(registry, LoginLog{
+		AccountType: models.AccountTypeUser,
+		Credential:  "user@example.com",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if log.Credential.String != "u***@example.com" {
+		t.Errorf("expected a masked credential, got %q", log.Credential.String)
+	}
+	for _, stmt := range db.args {
+		for _, arg := range stmt {
+			if s, ok := arg.(string); ok && strings.Contains(s, "user@example.com") {
+				t.Errorf("expected the credential not to be stored, got %q", s)
+			}
+		}
+	}
+}
+
+func TestRecordLoginFailureIsLogged(t *testing.T) {
+	db := &recordingDB{err: errors.New("database is down")}
+	registry, w := newTestRegistry(db)
+
+	if id := recordLoginOrLog(registry, LoginLog{AccountType: models.AccountTypeUser}); id != "" {
+		t.Errorf("expected no login log ID, got %q", id)
+	}
+	if len(w.messages) != 1 || !strings.Contains(w.messages[0], "database is down") {
+		t.Errorf("expected the failure to be logged, got %v", w.messages)
+	}
+}
+
+func TestLoginFingerprint(t *testing.T) {
+	l := LoginLog{DeviceID: "device-1"}
+	l.RequestFingerprint.UserAgent = "agent"
+	if got := l.LoginFingerprint(); got != "device:device-1" {
+		t.Errorf("expected the device ID, got %q", got)
+	}
+	l.Fingerprint = "client"
+	if got := l.LoginFingerprint(); got != "client" {
+		t.Errorf("expected the client fingerprint, got %q", got)
+	}
+	l = LoginLog{}
+	l.RequestFingerprint.UserAgent = "agent"
+	if got := l.LoginFingerprint(); !strings.HasPrefix(got, "ua:") {
+		t.Errorf("expected a user agent hash, got %q", got)
+	}
+}
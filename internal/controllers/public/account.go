@@ -7,8 +7,8 @@ import (
 	"be20250107/internal/app"
 	"be20250107/internal/controllers"
 	httperr "be20250107/internal/errors"
+	"be20250107/internal/models"
 	"be20250107/internal/responses"
-	stringsutil "be20250107/utils/strings"
 
 	validation "github.com/go-ozzo/ozzo-validation/v4"
 )
@@ -25,47 +25,93 @@ func NewAccountController(app *app.Registry) *AccountController {
 	}
 }
 
+// CheckDuplicate reports whether a user account already exists for the
+// provider and foreign ID. Users are looked up by their email or phone
+// contact. Admin accounts are only looked up by CheckAdminDuplicate, which
+// requires an admin, so that admin identities cannot be enumerated.
 func (c *AccountController) CheckDuplicate(w http.ResponseWriter, r *http.Request) {
-	actor := r.URL.Query().Get("actor")
-	if actor == "" {
-		panic(validation.Errors{"actor": validation.NewError("invalid_actor", "actor is required")})
-	}
-	foreignID := r.URL.Query().Get("foreign_id")
-	if foreignID == "" {
-		panic(validation.Errors{"foreign_id": validation.NewError("invalid_foreign_id", "foreign_id is required")})
+	actor, foreignID, provider := accountLookupParams(r)
+
+	var isExist bool
+	switch strings.ToLower(actor) {
+	case models.AccountTypeUser:
+		medium, ok := models.NormalizeContactMedium(provider)
+		if !ok || (medium != models.ContactMediumCatalogue && medium != models.ContactMediumEmail) {
+			panic(httperr.NewErrUnprocessableEntity(
+				"invalid_provider",
+				"unsupported provider", nil,
+			))
+		}
+
+		value, ok := models.NormalizeContactValue(medium, foreignID)
+		if !ok {
+			panic(httperr.NewErrUnprocessableEntity(
+				"invalid_value",
+				"value does not match the required format for the specified type", nil,
+			))
+		}
+
+		_, exist, err := models.GetUserContact(c.App.DB, medium, value)
+		if err != nil {
+			panic(err)
+		}
+		isExist = exist
+	default:
+		panic(httperr.NewErrUnprocessableEntity(
+			"invalid_actor",
+			"unsupported actor", nil,
+		))
 	}
-	provider := r.URL.Query().Get("provider")
-	if provider == "" {
-		panic(validation.Errors{"provider": validation.NewError("invalid_provider", "provider is required")})
+
+	writeDuplicate(w, isExist)
+}
+
+// CheckAdminDuplicate reports whether an admin account already exists for the
+// identity provider and foreign ID. It is only served to admins.
+func (c *AccountController) CheckAdminDuplicate(w http.ResponseWriter, r *http.Request) {
+	actor, foreignID, provider := accountLookupParams(r)
+	if strings.ToLower(actor) != models.AccountTypeAdmin {
+		panic(httperr.NewErrUnprocessableEntity(
+			"invalid_actor",
+			"unsupported actor", nil,
+		))
 	}
 
-	// Validation based on type
-	var isValid bool
-	switch strings.ToLower(provider) {
-	case "Catalogue":
-		isValid = stringsutil.ValidateTaiwanCatalogue(foreignID)
-	case "email":
-		isValid = stringsutil.ValidateEmail(foreignID)
-	default:
+	if _, err := c.App.Auth.Providers.Get(provider); err != nil {
 		panic(httperr.NewErrUnprocessableEntity(
 			"invalid_provider",
 			"unsupported provider", nil,
 		))
 	}
 
-	if !isValid {
-		panic(httperr.NewErrUnprocessableEntity(
-			"invalid_value",
-			"value does not match the required format for the specified type", nil,
-		))
+	_, exist, err := models.GetAdminByProviderAndProviderID(c.App.DB, foreignID, provider)
+	if err != nil {
+		panic(err)
 	}
+	writeDuplicate(w, exist)
+}
 
-	isExist := true
+func accountLookupParams(r *http.Request) (actor string, foreignID string, provider string) {
+	actor = r.URL.Query().Get("actor")
+	if actor == "" {
+		panic(validation.Errors{"actor": validation.NewError("invalid_actor", "actor is required")})
+	}
+	foreignID = r.URL.Query().Get("foreign_id")
+	if foreignID == "" {
+		panic(validation.Errors{"foreign_id": validation.NewError("invalid_foreign_id", "foreign_id is required")})
+	}
+	provider = r.URL.Query().Get("provider")
+	if provider == "" {
+		panic(validation.Errors{"provider": validation.NewError("invalid_provider", "provider is required")})
+	}
+	return actor, foreignID, provider
+}
 
+func writeDuplicate(w http.ResponseWriter, exist bool) {
 	if err := responses.JSON(w, 200, struct {
 		Data bool `json:"data"`
 	}{
-		Data: isExist,
+		Data: exist,
 	}); err != nil {
 		panic(err)
 	}
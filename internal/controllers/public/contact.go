@@ -7,8 +7,8 @@ import (
 	"be20250107/internal/app"
 	"be20250107/internal/controllers"
 	httperr "be20250107/internal/errors"
+	"be20250107/internal/models"
 	"be20250107/internal/responses"
-	stringsutil "be20250107/utils/strings"
 
 	validation "github.com/go-ozzo/ozzo-validation/v4"
 )
@@ -25,6 +25,8 @@ func NewContactController(app *app.Registry) *ContactController {
 	}
 }
 
+// CheckDuplicate reports whether the contact is already used by an account of
+// the actor. Phone numbers are normalized to E.164 before the lookup.
 func (c *ContactController) CheckDuplicate(w http.ResponseWriter, r *http.Request) {
 	actor := r.URL.Query().Get("actor")
 	if actor == "" {
@@ -46,32 +48,42 @@ func (c *ContactController) CheckDuplicate(w http.ResponseWriter, r *http.Reques
 		panic(validation.Errors{"medium": validation.NewError("invalid_medium", "medium is required")})
 	}
 
-	// Validation based on type
-	var isValid bool
-	switch strings.ToLower(medium) {
-	case "Catalogue":
-		isValid = stringsutil.ValidateTaiwanCatalogue(value)
-	case "landline":
-		isValid = stringsutil.ValidateTaiwanLandline(value)
-	case "email":
-		isValid = stringsutil.ValidateEmail(value)
-	case "line_id":
-		isValid = stringsutil.ValidateLineID(value)
-	default:
+	if strings.ToLower(actor) != models.AccountTypeUser {
+		panic(httperr.NewErrUnprocessableEntity(
+			"invalid_actor",
+			"unsupported actor", nil,
+		))
+	}
+
+	if !isContactType(typeParam) {
+		panic(httperr.NewErrUnprocessableEntity(
+			"invalid_type",
+			"unsupported type", nil,
+		))
+	}
+
+	normalizedMedium, ok := models.NormalizeContactMedium(medium)
+	if !ok {
 		panic(httperr.NewErrUnprocessableEntity(
 			"invalid_medium",
 			"unsupported medium", nil,
 		))
 	}
 
-	if !isValid {
+	normalizedValue, ok := models.NormalizeContactValue(normalizedMedium, value)
+	if !ok {
 		panic(httperr.NewErrUnprocessableEntity(
 			"invalid_value",
 			"value does not match the required format for the specified type", nil,
 		))
 	}
 
-	isExist := true
+	//	A contact can only belong to one user, so the type is not part of the
+	//	lookup.
+	_, isExist, err := models.GetUserContact(c.App.DB, normalizedMedium, normalizedValue)
+	if err != nil {
+		panic(err)
+	}
 
 	if err := responses.JSON(w, 200, struct {
 		Data bool `json:"data"`
@@ -81,3 +93,17 @@ func (c *ContactController) CheckDuplicate(w http.ResponseWriter, r *http.Reques
 		panic(err)
 	}
 }
+
+func isContactType(t string) bool {
+	for _, contactType := range []string{
+		models.ContactTypePrimary,
+		models.ContactTypeAlternative,
+		models.ContactTypeAppleID,
+		models.ContactTypeAppleRelay,
+	} {
+		if strings.EqualFold(t, contactType) {
+			return true
+		}
+	}
+	return false
+}
@@ -5,8 +5,6 @@ import (
 
 	"be20250107/internal/responses"
 
-	"github.com/lestrrat-go/jwx/v2/jwk"
-
 	"be20250107/internal/app"
 	"be20250107/internal/controllers"
 )
@@ -20,12 +18,7 @@ func NewKeysController(app *app.Registry) *KeysController {
 }
 
 func (c *KeysController) Keys(w http.ResponseWriter, r *http.Request) {
-	publicKey := c.App.VerifyKey
-	err := responses.JSON(w, 200, struct {
-		Keys []jwk.Key `json:"keys"`
-	}{
-		Keys: []jwk.Key{publicKey},
-	})
+	err := responses.JSON(w, 200, c.App.KeyRing.PublicKeySet())
 	if err != nil {
 		panic(err)
 	}
@@ -9,6 +9,7 @@ import (
 
 var ErrUnauthenticated = errors.New("http: unauthenticated")
 var ErrForbidden = errors.New("http: forbidden")
+var ErrTwoFactorRequired = fmt.Errorf("%w (two-factor authentication required)", ErrForbidden)
 var ErrNotFound = errors.New("http: not found")
 var ErrTooManyRequests = errors.New("http: too many requests")
 var ErrMalformedRequest = errors.New("http: malformed request")
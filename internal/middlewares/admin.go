@@ -23,6 +23,9 @@ type AdminAuthInformation struct {
 	user        *models.Admin
 	db          database.Queryer
 	token       jwt.Token
+	// fingerprintMismatch is set when the token is used from another device
+	// than it is bound to and binding is not enforced.
+	fingerprintMismatch bool
 }
 
 func (auth *AdminAuthInformation) IsLoggedIn() bool {
@@ -39,6 +42,12 @@ func (auth *AdminAuthInformation) TokenID() string {
 	return auth.tokenID
 }
 
+// FingerprintMismatch reports whether the token is used from another device
+// than it is bound to.
+func (auth *AdminAuthInformation) FingerprintMismatch() bool {
+	return auth.fingerprintMismatch
+}
+
 func (auth *AdminAuthInformation) UserID() string {
 	return auth.userID
 }
@@ -81,7 +90,20 @@ func (auth *AdminAuthInformation) RefreshUser() error {
 	return nil
 }
 
+// AdminAuthMiddleware authenticates admins that have passed the two-factor
+// challenge, if they need one.
 func AdminAuthMiddleware(app *app.Registry) func(http.Handler) http.Handler {
+	return adminAuthMiddleware(app, false)
+}
+
+// AdminPartialAuthMiddleware also accepts admin tokens issued before the
+// two-factor challenge was passed. It is meant for the routes used to enroll
+// in and pass the challenge.
+func AdminPartialAuthMiddleware(app *app.Registry) func(http.Handler) http.Handler {
+	return adminAuthMiddleware(app, true)
+}
+
+func adminAuthMiddleware(app *app.Registry, allowTwoFactorPending bool) func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			t, err := app.Auth.VerifyRequest(r, models.AccountTypeAdmin)
@@ -93,12 +115,17 @@ func AdminAuthMiddleware(app *app.Registry) func(http.Handler) http.Handler {
 				panic(err)
 			}
 
+			if !allowTwoFactorPending && authentication.IsTwoFactorPending(t) {
+				panic(httperr.ErrTwoFactorRequired)
+			}
+
 			auth := AdminAuthInformation{
-				tokenID:     t.JwtID(),
-				userID:      t.Subject(),
-				accountType: models.AccountTypeAdmin,
-				db:          app.DB,
-				token:       t,
+				fingerprintMismatch: checkTokenBinding(app, r, t, models.AccountTypeAdmin),
+				tokenID:             t.JwtID(),
+				userID:              t.Subject(),
+				accountType:         models.AccountTypeAdmin,
+				db:                  app.DB,
+				token:               t,
 			}
 			ctx := context.WithValue(r.Context(), ContextAuth, &auth)
 
@@ -2,18 +2,22 @@ package middlewares
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"net/http"
+	"slices"
 	"strings"
 	"time"
 
 	"be20250107/internal/app"
 	httperr "be20250107/internal/errors"
 	"be20250107/internal/models"
+	"be20250107/internal/modules/authentication"
+	"be20250107/internal/reqdata"
 	"be20250107/utils/database"
 
-	"github.com/lestrrat-go/jwx/v2/jwa"
 	"github.com/lestrrat-go/jwx/v2/jwt"
+	"gopkg.in/guregu/null.v4"
 )
 
 type AuthInformation struct {
@@ -23,6 +27,9 @@ type AuthInformation struct {
 	user        models.JWTAuthenticatable
 	db          database.Queryer
 	token       jwt.Token
+	// fingerprintMismatch is set when the token is used from another device
+	// than it is bound to and binding is not enforced.
+	fingerprintMismatch bool
 }
 
 func (auth *AuthInformation) IsLoggedIn() bool {
@@ -39,6 +46,12 @@ func (auth *AuthInformation) TokenID() string {
 	return auth.tokenID
 }
 
+// FingerprintMismatch reports whether the token is used from another device
+// than it is bound to.
+func (auth *AuthInformation) FingerprintMismatch() bool {
+	return auth.fingerprintMismatch
+}
+
 func (auth *AuthInformation) UserID() string {
 	return auth.userID
 }
@@ -79,8 +92,22 @@ func (auth *AuthInformation) RefreshUser() error {
 			return err
 		}
 		auth.user = &user
+	} else if auth.accountType == models.AccountTypeSystem {
+		user := models.System{}
+		err := auth.db.Get(&user, "SELECT * FROM systems WHERE id=?", auth.userID)
+		if err != nil {
+			return err
+		}
+		auth.user = &user
+	} else if auth.accountType == models.AccountTypeUser {
+		user := models.User{}
+		err := auth.db.Get(&user, "SELECT * FROM users WHERE id=?", auth.userID)
+		if err != nil {
+			return err
+		}
+		auth.user = &user
 	} else {
-		return fmt.Errorf("account type must only be models.Admin, models.System")
+		return fmt.Errorf("account type must only be models.Admin, models.System, models.User")
 	}
 
 	return nil
@@ -89,10 +116,33 @@ func (auth *AuthInformation) RefreshUser() error {
 func AuthMiddleware(app *app.Registry) func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if authentication.IsSignedRequest(r) {
+				t, err := app.Auth.VerifySignedRequest(r)
+				if err != nil {
+					app.Log.Warning(fmt.Sprintf("[AuthMiddleware] signed request rejected: %v", err))
+					panic(httperr.ErrUnauthenticated)
+				}
+				serveWithToken(app, next, w, r, t, models.AccountTypeSystem)
+				return
+			}
+
+			if authentication.IsAPIKeyRequest(r) {
+				t, err := app.Auth.VerifyAPIKey(r, reqdata.ClientIP(r))
+				if errors.Is(err, authentication.ErrIPNotAllowed) {
+					panic(httperr.ErrForbidden)
+				} else if errors.Is(err, authentication.ErrInvalidAPIKey) {
+					panic(httperr.ErrUnauthenticated)
+				} else if err != nil {
+					panic(err)
+				}
+				serveWithToken(app, next, w, r, t, models.AccountTypeAPIKey)
+				return
+			}
+
 			opts := []jwt.ParseOption{
 				jwt.WithHeaderKey("Authorization"),
 				jwt.WithFormKey("x_access_token"),
-				jwt.WithKey(jwa.RS256, app.VerifyKey),
+				app.KeyRing.VerifyOption(),
 			}
 
 			t, err := jwt.ParseRequest(r, opts...)
@@ -113,16 +163,135 @@ func AuthMiddleware(app *app.Registry) func(http.Handler) http.Handler {
 				accountType = val
 			}
 
-			auth := AuthInformation{
-				tokenID:     t.JwtID(),
-				userID:      t.Subject(),
-				accountType: accountType,
-				db:          app.DB,
-				token:       t,
+			auth := newAuthInformation(app, t, accountType)
+			auth.fingerprintMismatch = checkTokenBinding(app, r, t, accountType)
+			serveWithAuth(next, w, r, auth)
+		})
+	}
+}
+
+func newAuthInformation(app *app.Registry, t jwt.Token, accountType string) *AuthInformation {
+	return &AuthInformation{
+		tokenID:     t.JwtID(),
+		userID:      t.Subject(),
+		accountType: accountType,
+		db:          app.DB,
+		token:       t,
+	}
+}
+
+func serveWithToken(app *app.Registry, next http.Handler, w http.ResponseWriter, r *http.Request, t jwt.Token, accountType string) {
+	serveWithAuth(next, w, r, newAuthInformation(app, t, accountType))
+}
+
+func serveWithAuth(next http.Handler, w http.ResponseWriter, r *http.Request, auth *AuthInformation) {
+	ctx := context.WithValue(r.Context(), ContextAuth, auth)
+	next.ServeHTTP(w, r.WithContext(ctx))
+}
+
+// HasScope reports whether the token carries the scope in its space separated
+// "scope" claim.
+func HasScope(t jwt.Token, scope string) bool {
+	claim, _ := t.Get("scope")
+	scopes, _ := claim.(string)
+	for _, s := range strings.Fields(scopes) {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// ScopeMiddleware lets admins that passed the two-factor challenge through,
+// lets users through for the scopes in models.UserScopes and requires system
+// and API key tokens to carry the scope. It must be used after AuthMiddleware.
+func ScopeMiddleware(scope string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			auth, ok := r.Context().Value(ContextAuth).(*AuthInformation)
+			if !ok {
+				panic(httperr.ErrUnauthenticated)
+			}
+			if err := scopeError(auth, scope); err != nil {
+				panic(err)
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// GrantsScope reports whether ScopeMiddleware would let the request through
+// for the scope, for handlers that only hide part of a response.
+func GrantsScope(auth reqdata.AuthInformation, scope string) bool {
+	return auth != nil && scopeError(auth, scope) == nil
+}
+
+func scopeError(auth reqdata.AuthInformation, scope string) error {
+	switch auth.AccountType() {
+	case models.AccountTypeAdmin:
+		if authentication.IsTwoFactorPending(auth.Token()) {
+			return httperr.ErrTwoFactorRequired
+		}
+	case models.AccountTypeSystem, models.AccountTypeAPIKey:
+		if !HasScope(auth.Token(), scope) {
+			return httperr.ErrForbidden
+		}
+	case models.AccountTypeUser:
+		if !slices.Contains(models.UserScopes, scope) {
+			return httperr.ErrForbidden
+		}
+	default:
+		return httperr.ErrForbidden
+	}
+	return nil
+}
+
+// AccountTypeMiddleware only lets the account types through, admins once they
+// passed the two-factor challenge. It must be used after AuthMiddleware.
+func AccountTypeMiddleware(accountTypes ...string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			auth, ok := r.Context().Value(ContextAuth).(*AuthInformation)
+			if !ok {
+				panic(httperr.ErrUnauthenticated)
+			}
+			if !slices.Contains(accountTypes, auth.AccountType()) {
+				panic(httperr.ErrForbidden)
+			}
+			if auth.AccountType() == models.AccountTypeAdmin && authentication.IsTwoFactorPending(auth.Token()) {
+				panic(httperr.ErrTwoFactorRequired)
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// StrictRevocationMiddleware checks the database for revocations that the cache
+// of this instance may not have seen yet. It is meant for high-security routes
+// and must be used after one of the auth middlewares.
+func StrictRevocationMiddleware(app *app.Registry) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			auth, ok := r.Context().Value(ContextAuth).(reqdata.AuthInformation)
+			if !ok {
+				panic(httperr.ErrUnauthenticated)
+			}
+
+			revoked, err := app.Auth.IsRevokedInDB(auth.TokenID())
+			if err != nil {
+				panic(err)
+			}
+			if revoked {
+				expiredAt, canExpire := auth.Expiration()
+				if err := app.Auth.ApplyRevocation(auth.TokenID(), null.NewTime(expiredAt, canExpire)); err != nil {
+					panic(err)
+				}
+				panic(httperr.ErrUnauthenticated)
 			}
-			ctx := context.WithValue(r.Context(), ContextAuth, &auth)
 
-			next.ServeHTTP(w, r.WithContext(ctx))
+			next.ServeHTTP(w, r)
 		})
 	}
 }
@@ -0,0 +1,48 @@
+package middlewares
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"be20250107/internal/app"
+	"be20250107/internal/models"
+	"be20250107/internal/modules/authentication"
+	"be20250107/internal/modules/logger"
+
+	"github.com/lestrrat-go/jwx/v2/jwt"
+)
+
+func withAuth(r *http.Request, auth *AuthInformation) *http.Request {
+	return r.WithContext(context.WithValue(r.Context(), ContextAuth, auth))
+}
+
+func TestAccountTypeMiddleware(t *testing.T) {
+	registry := &app.Registry{Log: &logger.Logger{}}
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	})
+	h := Recover(registry)(AccountTypeMiddleware(models.AccountTypeAdmin, models.AccountTypeUser)(ok))
+
+	pending := jwt.New()
+	pending.Set(authentication.ClaimTwoFactorPending, true)
+
+	tests := []struct {
+		name     string
+		auth     *AuthInformation
+		expected int
+	}{
+		{"user", &AuthInformation{userID: "user", accountType: models.AccountTypeUser, token: jwt.New()}, http.StatusNoContent},
+		{"admin", &AuthInformation{userID: "admin", accountType: models.AccountTypeAdmin, token: jwt.New()}, http.StatusNoContent},
+		{"admin pending two-factor", &AuthInformation{userID: "admin", accountType: models.AccountTypeAdmin, token: pending}, http.StatusForbidden},
+		{"system", &AuthInformation{userID: "system", accountType: models.AccountTypeSystem, token: jwt.New()}, http.StatusForbidden},
+		{"api key", &AuthInformation{userID: "key", accountType: models.AccountTypeAPIKey, token: jwt.New()}, http.StatusForbidden},
+	}
+	for _, test := range tests {
+		rec := serve(h, withAuth(httptest.NewRequest(http.MethodPost, "/catalogues", nil), test.auth))
+		if rec.Code != test.expected {
+			t.Errorf("%s: expected %d, got %d", test.name, test.expected, rec.Code)
+		}
+	}
+}
@@ -0,0 +1,64 @@
+package middlewares
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"be20250107/internal/app"
+	httperr "be20250107/internal/errors"
+	"be20250107/internal/models"
+	"be20250107/internal/modules/cache"
+	"be20250107/internal/reqdata"
+
+	"github.com/lestrrat-go/jwx/v2/jwt"
+	"gopkg.in/guregu/null.v4"
+)
+
+// mismatchAuditInterval limits how often mismatches of the same token are
+// written to the audit log.
+const mismatchAuditInterval = 10 * time.Minute
+
+// checkTokenBinding reports whether the request was made from another device
+// than the token is bound to. Mismatches are recorded in the audit log and,
+// when binding is enforced, rejected.
+func checkTokenBinding(app *app.Registry, r *http.Request, t jwt.Token, accountType string) bool {
+	if err := app.Auth.CheckTokenBinding(t, r); err == nil {
+		return false
+	}
+
+	if err := recordBindingMismatch(app, r, t, accountType); err != nil {
+		app.Log.Error(fmt.Sprintf("[checkTokenBinding] record mismatch: %v", err))
+	}
+	if app.Auth.EnforcesTokenBinding() {
+		panic(httperr.ErrUnauthenticated)
+	}
+	return true
+}
+
+func recordBindingMismatch(app *app.Registry, r *http.Request, t jwt.Token, accountType string) error {
+	key := fmt.Sprintf("auth:fpt_mismatch_%s", t.JwtID())
+	first, err := app.Cache.SetNX(key, true, &cache.Options{Expiration: mismatchAuditInterval})
+	if err != nil || !first {
+		return err
+	}
+
+	ip := reqdata.ClientIP(r)
+	log := models.AuditLog{
+		Event:       models.AuditTokenFingerprintMismatch,
+		AccountType: null.NewString(accountType, accountType != ""),
+		AccountID:   null.NewString(t.Subject(), t.Subject() != ""),
+		TokenID:     null.NewString(t.JwtID(), t.JwtID() != ""),
+		IPAddress:   null.NewString(ip, ip != ""),
+		UserAgent:   null.NewString(r.UserAgent(), r.UserAgent() != ""),
+	}
+	err = log.SetData(map[string]any{
+		"enforced": app.Auth.EnforcesTokenBinding(),
+		"method":   r.Method,
+		"path":     r.URL.Path,
+	})
+	if err != nil {
+		return err
+	}
+	return log.Insert(app.DB)
+}
@@ -0,0 +1,245 @@
+package middlewares
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"be20250107/internal/app"
+	"be20250107/internal/models"
+	"be20250107/internal/modules/authentication"
+	"be20250107/internal/modules/cache"
+	"be20250107/internal/modules/keyring"
+	"be20250107/internal/modules/logger"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/lestrrat-go/jwx/v2/jwa"
+	"github.com/lestrrat-go/jwx/v2/jwt"
+)
+
+// tokenDB is a database/sql connector answering the revoked_at lookup of
+// IsRevokedInDB with revokedAt, or no row when missing is set, and recording
+// the statements executed.
+type tokenDB struct {
+	mu        sync.Mutex
+	revokedAt driver.Value
+	missing   bool
+	execs     []string
+}
+
+func (d *tokenDB) Connect(context.Context) (driver.Conn, error) { return tokenConn{d}, nil }
+func (d *tokenDB) Driver() driver.Driver                        { return nil }
+
+// countExecs returns how many executed statements contain the fragment.
+func (d *tokenDB) countExecs(fragment string) int {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	n := 0
+	for _, q := range d.execs {
+		if strings.Contains(q, fragment) {
+			n++
+		}
+	}
+	return n
+}
+
+type tokenConn struct{ db *tokenDB }
+
+func (c tokenConn) Prepare(query string) (driver.Stmt, error) { return tokenStmt{c.db, query}, nil }
+func (c tokenConn) Close() error                              { return nil }
+func (c tokenConn) Begin() (driver.Tx, error)                 { return nil, errors.New("not supported") }
+
+type tokenStmt struct {
+	db    *tokenDB
+	query string
+}
+
+func (s tokenStmt) Close() error  { return nil }
+func (s tokenStmt) NumInput() int { return -1 }
+func (s tokenStmt) Exec(args []driver.Value) (driver.Result, error) {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+	s.db.execs = append(s.db.execs, s.query)
+	return driver.RowsAffected(1), nil
+}
+func (s tokenStmt) Query(args []driver.Value) (driver.Rows, error) {
+	if s.db.missing {
+		return &tokenRows{}, nil
+	}
+	return &tokenRows{values: []driver.Value{s.db.revokedAt}}, nil
+}
+
+type tokenRows struct{ values []driver.Value }
+
+func (r *tokenRows) Columns() []string { return []string{"revoked_at"} }
+func (r *tokenRows) Close() error      { return nil }
+func (r *tokenRows) Next(dest []driver.Value) error {
+	if len(r.values) == 0 {
+		return io.EOF
+	}
+	dest[0] = r.values[0]
+	r.values = r.values[1:]
+	return nil
+}
+
+func newTestKeyRing(t *testing.T) *keyring.KeyRing {
+	t.Helper()
+	private, err := keyring.Generate(1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+	raw, err := json.Marshal(private)
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, err := keyring.ParseKey(string(raw), true, time.Time{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	kr, err := keyring.New([]*keyring.Key{key}, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return kr
+}
+
+func newAuthRegistry(t *testing.T, db *tokenDB, tokenBinding string) *app.Registry {
+	t.Helper()
+	registry := &app.Registry{
+		DB:      sqlx.NewDb(sql.OpenDB(db), "mysql"),
+		Cache:   cache.NewInMemoryCache(nil),
+		Log:     &logger.Logger{},
+		KeyRing: newTestKeyRing(t),
+	}
+	registry.Auth.TokenBinding = tokenBinding
+	registry.Auth.Init(registry.DB, registry.Cache, registry.KeyRing)
+	return registry
+}
+
+// signAdminToken signs an admin access token bound to the device fingerprint.
+func signAdminToken(t *testing.T, registry *app.Registry, fingerprint string) string {
+	t.Helper()
+	token, err := jwt.NewBuilder().
+		JwtID("admin_access_tokens:1").
+		Subject("admins:1").
+		Claim("act", models.AccountTypeAdmin).
+		Claim(authentication.ClaimFingerprint, authentication.HashFingerprint(fingerprint)).
+		Expiration(time.Now().Add(time.Hour)).
+		Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	signed, err := jwt.Sign(token, jwt.WithKey(jwa.RS256, registry.KeyRing.SigningKey()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(signed)
+}
+
+func requestFrom(token string, fingerprint string) *http.Request {
+	r := httptest.NewRequest(http.MethodGet, "/admin/catalogues", nil)
+	r.Header.Set("Authorization", "Bearer "+token)
+	r.Header.Set(authentication.HeaderFingerprint, fingerprint)
+	return r
+}
+
+// mismatchRecorder serves the request and records whether the handler saw a
+// fingerprint mismatch.
+type mismatchRecorder struct{ mismatch bool }
+
+func (m *mismatchRecorder) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	auth := r.Context().Value(ContextAuth).(*AuthInformation)
+	m.mismatch = auth.FingerprintMismatch()
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func TestAuthMiddlewareTokenBinding(t *testing.T) {
+	tests := []struct {
+		name         string
+		tokenBinding string
+		fingerprint  string
+		expected     int
+		mismatch     bool
+		audited      int
+	}{
+		{"flag, same device", authentication.TokenBindingFlag, "device-a", http.StatusNoContent, false, 0},
+		{"flag, other device", authentication.TokenBindingFlag, "device-b", http.StatusNoContent, true, 1},
+		{"enforce, same device", authentication.TokenBindingEnforce, "device-a", http.StatusNoContent, false, 0},
+		{"enforce, other device", authentication.TokenBindingEnforce, "device-b", http.StatusUnauthorized, false, 1},
+		{"off, other device", authentication.TokenBindingOff, "device-b", http.StatusNoContent, false, 0},
+	}
+	for _, test := range tests {
+		db := &tokenDB{}
+		registry := newAuthRegistry(t, db, test.tokenBinding)
+		next := &mismatchRecorder{}
+		h := Recover(registry)(AuthMiddleware(registry)(next))
+		token := signAdminToken(t, registry, "device-a")
+
+		rec := serve(h, requestFrom(token, test.fingerprint))
+		if rec.Code != test.expected {
+			t.Errorf("%s: expected %d, got %d", test.name, test.expected, rec.Code)
+		}
+		if next.mismatch != test.mismatch {
+			t.Errorf("%s: expected mismatch %v, got %v", test.name, test.mismatch, next.mismatch)
+		}
+		if n := db.countExecs("INSERT INTO audit_logs"); n != test.audited {
+			t.Errorf("%s: expected %d audit logs, got %d", test.name, test.audited, n)
+		}
+	}
+}
+
+func TestAuthMiddlewareAuditsMismatchOncePerInterval(t *testing.T) {
+	for _, tokenBinding := range []string{authentication.TokenBindingFlag, authentication.TokenBindingEnforce} {
+		db := &tokenDB{}
+		registry := newAuthRegistry(t, db, tokenBinding)
+		h := Recover(registry)(AuthMiddleware(registry)(&mismatchRecorder{}))
+		token := signAdminToken(t, registry, "device-a")
+
+		for i := 0; i < 3; i++ {
+			serve(h, requestFrom(token, "device-b"))
+		}
+		if n := db.countExecs("INSERT INTO audit_logs"); n != 1 {
+			t.Errorf("%s: expected the mismatch to be audited once, got %d", tokenBinding, n)
+		}
+	}
+}
+
+func TestStrictRevocationMiddleware(t *testing.T) {
+	tests := []struct {
+		name     string
+		db       *tokenDB
+		expected int
+	}{
+		{"active token", &tokenDB{revokedAt: nil}, http.StatusNoContent},
+		{"revoked token", &tokenDB{revokedAt: time.Now().Add(-time.Minute)}, http.StatusUnauthorized},
+		{"deleted token", &tokenDB{missing: true}, http.StatusUnauthorized},
+	}
+	for _, test := range tests {
+		registry := newAuthRegistry(t, test.db, authentication.TokenBindingOff)
+		h := Recover(registry)(AuthMiddleware(registry)(StrictRevocationMiddleware(registry)(&mismatchRecorder{})))
+		token := signAdminToken(t, registry, "device-a")
+
+		if rec := serve(h, requestFrom(token, "device-a")); rec.Code != test.expected {
+			t.Errorf("%s: expected %d, got %d", test.name, test.expected, rec.Code)
+		}
+
+		// A revocation found in the database is applied to the cache, so the
+		// token is also rejected on routes without the strict check.
+		rec := serve(Recover(registry)(AuthMiddleware(registry)(&mismatchRecorder{})), requestFrom(token, "device-a"))
+		if test.expected == http.StatusUnauthorized && rec.Code != http.StatusUnauthorized {
+			t.Errorf("%s: expected the revocation to be cached, got %d", test.name, rec.Code)
+		}
+		if test.expected != http.StatusUnauthorized && rec.Code != http.StatusNoContent {
+			t.Errorf("%s: expected the token to stay usable, got %d", test.name, rec.Code)
+		}
+	}
+}
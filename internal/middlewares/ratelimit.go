@@ -0,0 +1,85 @@
+package middlewares
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"slices"
+	"strconv"
+	"time"
+
+	"be20250107/internal/app"
+	"be20250107/internal/models"
+	"be20250107/internal/modules/ratelimiter"
+	"be20250107/internal/reqdata"
+)
+
+// RateLimitMiddleware limits requests with the named policy of the rate_limit
+// config and sets the RateLimit-* headers. Route groups whose policy is not
+// configured are not limited. Policies keyed by account must be used after
+// one of the auth middlewares.
+func RateLimitMiddleware(app *app.Registry, name string) func(http.Handler) http.Handler {
+	cfg := app.Config.RateLimit
+	policyConfig, ok := cfg.Policies[name]
+	if !cfg.Enabled || !ok {
+		return func(next http.Handler) http.Handler {
+			return next
+		}
+	}
+
+	policy := ratelimiter.Policy{
+		Algorithm: ratelimiter.Algorithm(policyConfig.Algorithm),
+		Limit:     policyConfig.Limit,
+		Period:    policyConfig.Period,
+		Burst:     policyConfig.Burst,
+	}
+	if err := policy.Validate(); err != nil {
+		panic(fmt.Sprintf("[RateLimitMiddleware] policy %s: %v", name, err))
+	}
+	limiter := ratelimiter.New(app.Cache, "http_"+name, policy)
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			auth, _ := r.Context().Value(ContextAuth).(reqdata.AuthInformation)
+			if auth != nil && auth.AccountType() == models.AccountTypeSystem && slices.Contains(cfg.TrustedSystems, auth.UserID()) {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			key := rateLimitKey(policyConfig.Key, r, auth)
+			res, err := limiter.Allow(key)
+			if err != nil {
+				//	Do not turn a cache outage into an API outage, but make it
+				//	visible that requests are no longer limited.
+				app.Log.Error(fmt.Sprintf("[RateLimitMiddleware] policy %s: allowing %s without a limit: %v", name, key, err))
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			setRateLimitHeaders(w, res)
+			if !res.Allowed {
+				panic(&ratelimiter.LimitedError{Key: key, Result: res})
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// rateLimitKey returns what the request is counted by. Requests without the
+// account the policy is keyed by are counted by client IP.
+func rateLimitKey(kind string, r *http.Request, auth reqdata.AuthInformation) string {
+	if auth != nil && auth.UserID() != "" {
+		switch kind {
+		case "account", auth.AccountType():
+			return ratelimiter.Key(auth.AccountType(), auth.UserID())
+		}
+	}
+	return ratelimiter.Key("ip", reqdata.ClientIP(r))
+}
+
+func setRateLimitHeaders(w http.ResponseWriter, res ratelimiter.Result) {
+	reset := math.Ceil(max(time.Until(res.ResetAt), 0).Seconds())
+	w.Header().Set("RateLimit-Limit", strconv.Itoa(res.Limit))
+	w.Header().Set("RateLimit-Remaining", strconv.Itoa(res.Remaining))
+	w.Header().Set("RateLimit-Reset", strconv.Itoa(int(reset)))
+}
@@ -0,0 +1,143 @@
+package middlewares
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"be20250107/internal/app"
+	"be20250107/internal/config"
+	"be20250107/internal/models"
+	"be20250107/internal/modules/cache"
+	"be20250107/internal/modules/logger"
+)
+
+type capturingWriter struct{ messages []string }
+
+func (w *capturingWriter) Write(message string, stackTrace []byte, payload map[string][]byte) {
+	w.messages = append(w.messages, message)
+}
+func (w *capturingWriter) Close() {}
+
+// failingCache fails every counter update, as a cache outage would.
+type failingCache struct {
+	cache.Cache
+}
+
+func (failingCache) IncrementBy(string, int64, *cache.Options) (int64, error) {
+	return 0, errors.New("cache is down")
+}
+
+func newRateLimitedHandler(c cache.Cache, limit int) (http.Handler, *capturingWriter) {
+	w := &capturingWriter{}
+	registry := &app.Registry{
+		Config: &config.PublicConfig{RateLimit: config.RateLimitConfig{
+			Enabled: true,
+			Policies: map[string]config.RateLimitPolicyConfig{
+				"test": {Key: "ip", Algorithm: "fixed_window", Limit: limit, Period: time.Minute},
+			},
+			TrustedSystems: []string{"trusted"},
+		}},
+		Cache: c,
+		Log:   &logger.Logger{Writers: map[logger.Level][]logger.Writer{logger.Error: {w}}},
+	}
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	})
+	return Recover(registry)(RateLimitMiddleware(registry, "test")(ok)), w
+}
+
+func serve(h http.Handler, r *http.Request) *httptest.ResponseRecorder {
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, r)
+	return rec
+}
+
+func TestRateLimitMiddlewareSetsHeaders(t *testing.T) {
+	h, _ := newRateLimitedHandler(cache.NewInMemoryCache(nil), 2)
+
+	rec := serve(h, httptest.NewRequest(http.MethodGet, "/", nil))
+	if rec.Code != http.StatusNoContent {
+		t.Fatalf("expected the request to pass, got %d", rec.Code)
+	}
+	if got := rec.Header().Get("RateLimit-Limit"); got != "2" {
+		t.Errorf("expected RateLimit-Limit 2, got %q", got)
+	}
+	if got := rec.Header().Get("RateLimit-Remaining"); got != "1" {
+		t.Errorf("expected RateLimit-Remaining 1, got %q", got)
+	}
+	if got := rec.Header().Get("RateLimit-Reset"); got == "" || got == "0" {
+		t.Errorf("expected RateLimit-Reset to be set, got %q", got)
+	}
+}
+
+func TestRateLimitMiddlewareRejectsOverLimit(t *testing.T) {
+	h, _ := newRateLimitedHandler(cache.NewInMemoryCache(nil), 1)
+
+	serve(h, httptest.NewRequest(http.MethodGet, "/", nil))
+	rec := serve(h, httptest.NewRequest(http.MethodGet, "/", nil))
+	if rec.Code != http.StatusTooManyRequests {
+		t.Fatalf("expected 429, got %d", rec.Code)
+	}
+	if got := rec.Header().Get("Retry-After"); got == "" || got == "0" {
+		t.Errorf("expected Retry-After to be set, got %q", got)
+	}
+	if got := rec.Header().Get("RateLimit-Remaining"); got != "0" {
+		t.Errorf("expected RateLimit-Remaining 0, got %q", got)
+	}
+
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.RemoteAddr = "198.51.100.1:1234"
+	if rec := serve(h, r); rec.Code != http.StatusNoContent {
+		t.Errorf("expected another client IP to be counted apart, got %d", rec.Code)
+	}
+}
+
+func TestRateLimitMiddlewareExemptsTrustedSystems(t *testing.T) {
+	h, _ := newRateLimitedHandler(cache.NewInMemoryCache(nil), 1)
+
+	for _, userID := range []string{"trusted", "trusted", "other", "other"} {
+		auth := &AuthInformation{userID: userID, accountType: models.AccountTypeSystem}
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		r = r.WithContext(context.WithValue(r.Context(), ContextAuth, auth))
+		rec := serve(h, r)
+
+		switch {
+		case userID == "trusted" && (rec.Code != http.StatusNoContent || rec.Header().Get("RateLimit-Limit") != ""):
+			t.Errorf("expected the trusted system not to be limited, got %d", rec.Code)
+		case userID == "other" && rec.Code == http.StatusTooManyRequests:
+			return
+		}
+	}
+	t.Error("expected other systems to be limited")
+}
+
+func TestRateLimitMiddlewareFailsOpenAndLogs(t *testing.T) {
+	h, w := newRateLimitedHandler(failingCache{}, 1)
+
+	for range 2 {
+		if rec := serve(h, httptest.NewRequest(http.MethodGet, "/", nil)); rec.Code != http.StatusNoContent {
+			t.Fatalf("expected the request to pass when the cache fails, got %d", rec.Code)
+		}
+	}
+	if len(w.messages) != 2 || !strings.Contains(w.messages[0], "cache is down") {
+		t.Errorf("expected every fallback to be logged, got %v", w.messages)
+	}
+}
+
+func TestRateLimitMiddlewareWithoutPolicy(t *testing.T) {
+	registry := &app.Registry{Config: &config.PublicConfig{RateLimit: config.RateLimitConfig{Enabled: true}}}
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	})
+	h := RateLimitMiddleware(registry, "missing")(next)
+
+	rec := serve(h, httptest.NewRequest(http.MethodGet, "/", nil))
+	if rec.Code != http.StatusNoContent || rec.Header().Get("RateLimit-Limit") != "" {
+		t.Errorf("expected route groups without a policy not to be limited, got %d", rec.Code)
+	}
+}
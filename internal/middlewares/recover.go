@@ -5,10 +5,12 @@ import (
 	"database/sql"
 	"errors"
 	"fmt"
+	"math"
 	"net/http"
 	"net/url"
 	"os"
 	"runtime/debug"
+	"strconv"
 	"strings"
 	"time"
 
@@ -41,6 +43,11 @@ func Recover(app *app.Registry) func(handler http.Handler) http.Handler {
 							return
 						}
 
+						if errors.Is(err, httperr.ErrTwoFactorRequired) {
+							responses.TwoFactorRequired(w)
+							return
+						}
+
 						if errors.Is(err, httperr.ErrForbidden) {
 							responses.Forbidden(w)
 							return
@@ -72,6 +79,10 @@ func Recover(app *app.Registry) func(handler http.Handler) http.Handler {
 						}
 
 						if errors.Is(err, httperr.ErrTooManyRequests) || errors.Is(err, ratelimiter.ErrRateLimited) {
+							var retry interface{ RetryAfter() time.Duration }
+							if errors.As(err, &retry) {
+								w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(retry.RetryAfter().Seconds()))))
+							}
 							responses.TooManyRequests(w)
 							return
 						}
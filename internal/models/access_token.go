@@ -0,0 +1,52 @@
+package models
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"be20250107/utils/database"
+)
+
+// AccessTokenTables are the tables of every kind of access token.
+var AccessTokenTables = []string{"admin_access_tokens", "system_access_tokens", "user_access_tokens"}
+
+// DeleteExpiredAccessTokens deletes access tokens that expired before the
+// given time, batch rows at a time so that the tables are not locked for long.
+// Tokens without an expiry are kept, revoked or not, since the revocation list
+// is loaded from them. It stops between batches once ctx is done.
+func DeleteExpiredAccessTokens(ctx context.Context, db database.Queryer, before time.Time, batch int) (int64, error) {
+	var total int64
+	for _, table := range AccessTokenTables {
+		q := fmt.Sprintf("DELETE FROM %s WHERE expired_at IS NOT NULL AND expired_at < ? LIMIT ?", table)
+		n, err := deleteInBatches(ctx, db, q, before, batch)
+		total += n
+		if err != nil {
+			return total, fmt.Errorf("[DeleteExpiredAccessTokens][%s]%w", table, err)
+		}
+	}
+	return total, nil
+}
+
+// deleteInBatches runs the DELETE ... LIMIT query until it affects fewer rows
+// than the batch size, or ctx is done.
+func deleteInBatches(ctx context.Context, db database.Queryer, q string, before time.Time, batch int) (int64, error) {
+	var total int64
+	for {
+		if err := ctx.Err(); err != nil {
+			return total, err
+		}
+		res, err := db.Exec(q, before, batch)
+		if err != nil {
+			return total, err
+		}
+		n, err := res.RowsAffected()
+		if err != nil {
+			return total, err
+		}
+		total += n
+		if n < int64(batch) {
+			return total, nil
+		}
+	}
+}
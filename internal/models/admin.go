@@ -7,6 +7,7 @@ import (
 	"time"
 
 	"be20250107/internal/constants"
+	"be20250107/internal/modules/secretbox"
 	"be20250107/utils/database"
 
 	"github.com/lestrrat-go/jwx/v2/jwt"
@@ -23,6 +24,14 @@ type Admin struct {
 	Role          *Role       `json:"role,omitempty" mapstructure:"role"`
 	ProviderID    string      `json:"provider_id" db:"provider_id" mapstructure:"provider_id"`
 	DeactivatedAt null.Time   `json:"deactivated_at" db:"deactivated_at" mapstructure:"deactivated_at"`
+	// DeactivatedByProvider tells deactivations mirrored from the identity
+	// provider, which the sync lifts again, from local ones, which it keeps.
+	DeactivatedByProvider bool `json:"-" db:"deactivated_by_provider" mapstructure:"-"`
+
+	ProviderRefreshToken null.String `json:"-" db:"provider_refresh_token" mapstructure:"-"`
+	// TOTPSecret is set on enrollment and only used once TOTPEnabledAt is set.
+	TOTPSecret    null.String `json:"-" db:"totp_secret" mapstructure:"-"`
+	TOTPEnabledAt null.Time   `json:"totp_enabled_at" db:"totp_enabled_at" mapstructure:"totp_enabled_at"`
 }
 
 func (a *Admin) Insert(db database.Queryer) error {
@@ -30,9 +39,9 @@ func (a *Admin) Insert(db database.Queryer) error {
 
 	q := `
 		INSERT INTO admins
-		(id, name, username, provider, provider_id, deactivated_at, created_at, updated_at)
+		(id, name, username, provider, provider_id, role_id, deactivated_at, created_at, updated_at)
 		VALUES
-		(:id, :name, :username, :provider, :provider_id, :deactivated_at, :created_at, :updated_at)
+		(:id, :name, :username, :provider, :provider_id, :role_id, :deactivated_at, :created_at, :updated_at)
 	`
 	_, err := db.NamedExec(q, a)
 	if err != nil {
@@ -49,7 +58,8 @@ func (a *Admin) Update(db database.Queryer) error {
 			username = :username,
 			role_id = :role_id,
 			updated_at = :updated_at,
-			deactivated_at = :deactivated_at
+			deactivated_at = :deactivated_at,
+			deactivated_by_provider = :deactivated_by_provider
 		WHERE id = :id
 	`
 
@@ -60,6 +70,41 @@ func (a *Admin) Update(db database.Queryer) error {
 	return nil
 }
 
+// UpdateProviderRefreshToken stores the identity provider refresh token used by
+// the background account sync, sealed with SealProviderRefreshToken. It does
+// not touch updated_at since the admin itself has not changed.
+func (a *Admin) UpdateProviderRefreshToken(db database.Queryer, token null.String) error {
+	a.ProviderRefreshToken = token
+	_, err := db.Exec("UPDATE admins SET provider_refresh_token = ? WHERE id = ?", a.ProviderRefreshToken, a.ID)
+	if err != nil {
+		return fmt.Errorf("[a.UpdateProviderRefreshToken][Exec]%w", err)
+	}
+	return nil
+}
+
+// SealProviderRefreshToken encrypts a refresh token before it is stored with
+// UpdateProviderRefreshToken. Without an encryption key the token is not kept,
+// which leaves the admin out of the background sync.
+func SealProviderRefreshToken(box *secretbox.Box, token string) (null.String, error) {
+	if box == nil || token == "" {
+		return null.String{}, nil
+	}
+	sealed, err := box.Seal(token)
+	if err != nil {
+		return null.String{}, fmt.Errorf("[SealProviderRefreshToken][Seal]%w", err)
+	}
+	return null.StringFrom(sealed), nil
+}
+
+// PlainProviderRefreshToken decrypts the refresh token of the admin.
+func (a *Admin) PlainProviderRefreshToken(box *secretbox.Box) (string, error) {
+	token, err := box.Open(a.ProviderRefreshToken.String)
+	if err != nil {
+		return "", fmt.Errorf("[a.PlainProviderRefreshToken][Open]%w", err)
+	}
+	return token, nil
+}
+
 func (a *Admin) ChangeRole(db database.Queryer, r *Role) error {
 	a.RoleID = null.StringFrom(r.ID)
 	err := a.Update(db)
@@ -116,9 +161,13 @@ func (a *Admin) IssueAccessToken(exp time.Time) (jwt.Token, error) {
 
 type AdminAccessToken struct {
 	Model
-	AdminID   string    `json:"admin_id" db:"admin_id"`
-	RevokedAt null.Time `json:"revoked_at" db:"revoked_at"`
-	ExpiredAt null.Time `json:"expired_at" db:"expired_at"`
+	AdminID      string      `json:"admin_id" db:"admin_id"`
+	AuthProvider null.String `json:"auth_provider" db:"auth_provider"`
+	DeviceID     null.String `json:"device_id" db:"device_id"`
+	IPAddress    null.String `json:"ip_address" db:"ip_address"`
+	UserAgent    null.String `json:"user_agent" db:"user_agent"`
+	RevokedAt    null.Time   `json:"revoked_at" db:"revoked_at"`
+	ExpiredAt    null.Time   `json:"expired_at" db:"expired_at"`
 }
 
 func (aat *AdminAccessToken) Insert(db database.Queryer) error {
@@ -126,8 +175,9 @@ func (aat *AdminAccessToken) Insert(db database.Queryer) error {
 	aat.Model.CreatedAt = now
 	aat.Model.UpdatedAt = now
 
-	q := "INSERT INTO admin_access_tokens (id,admin_id,expired_at,revoked_at,created_at,updated_at) " +
-		"VALUES (:id,:admin_id,:expired_at,:revoked_at,:created_at,:updated_at)"
+	q := "INSERT INTO admin_access_tokens " +
+		"(id,admin_id,auth_provider,device_id,ip_address,user_agent,expired_at,revoked_at,created_at,updated_at) " +
+		"VALUES (:id,:admin_id,:auth_provider,:device_id,:ip_address,:user_agent,:expired_at,:revoked_at,:created_at,:updated_at)"
 	_, err := db.NamedExec(q, aat)
 	if err != nil {
 		return fmt.Errorf("[aat.Insert][NamedExec]%w", err)
@@ -138,7 +188,7 @@ func (aat *AdminAccessToken) Insert(db database.Queryer) error {
 func (aat *AdminAccessToken) Update(db database.Queryer) error {
 	aat.Model.UpdatedAt = time.Now().Unix()
 
-	q := "UPDATE admin_access_tokens" +
+	q := "UPDATE admin_access_tokens " +
 		"SET admin_id = :admin_id," +
 		"expired_at = :expired_at," +
 		"revoked_at = :revoked_at," +
@@ -151,6 +201,15 @@ func (aat *AdminAccessToken) Update(db database.Queryer) error {
 	}
 	return nil
 }
+
+// IsActive reports whether the token has neither been revoked nor expired.
+func (aat *AdminAccessToken) IsActive() bool {
+	if aat.RevokedAt.Valid {
+		return false
+	}
+	return !aat.ExpiredAt.Valid || aat.ExpiredAt.Time.After(time.Now())
+}
+
 func GetAdminAccessTokenByID(db database.Queryer, id string) (*AdminAccessToken, bool, error) {
 	var mat AdminAccessToken
 
@@ -164,6 +223,26 @@ func GetAdminAccessTokenByID(db database.Queryer, id string) (*AdminAccessToken,
 	return &mat, true, nil
 }
 
+// GetActiveAdminAccessTokens returns the tokens of an admin that are neither
+// revoked nor expired, newest first.
+func GetActiveAdminAccessTokens(db database.Queryer, adminID string) ([]AdminAccessToken, error) {
+	q := `
+		SELECT * FROM admin_access_tokens
+		WHERE
+			admin_id = ? AND
+			revoked_at IS NULL AND
+			(expired_at IS NULL OR expired_at > NOW())
+		ORDER BY created_at DESC, id DESC
+	`
+
+	tokens := []AdminAccessToken{}
+	err := db.Select(&tokens, q, adminID)
+	if err != nil {
+		return nil, fmt.Errorf("[GetActiveAdminAccessTokens][Select]%w", err)
+	}
+	return tokens, nil
+}
+
 func GetAdminBatched(db database.Queryer, lastID string, lastCreatedAt int64, limit int) ([]Admin, error) {
 	q := `
 	SELECT * FROM admins 
@@ -213,7 +292,7 @@ func GetAdminsByRoleID(db database.Queryer, role_id string) ([]Admin, bool, erro
 	return admins, true, nil
 }
 
-func GetAdminByProviderAndProviderID(db database.Queryer, providerID int, provider string) (*Admin, bool, error) {
+func GetAdminByProviderAndProviderID(db database.Queryer, providerID string, provider string) (*Admin, bool, error) {
 	var admin Admin
 	err := db.Get(&admin, "SELECT * FROM admins WHERE provider_id = ? AND provider = ?", providerID, provider)
 	if err == sql.ErrNoRows {
@@ -0,0 +1,45 @@
+package models
+
+import (
+	"testing"
+
+	"be20250107/internal/modules/secretbox"
+)
+
+func TestProviderRefreshTokenIsSealed(t *testing.T) {
+	key, err := secretbox.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	box, err := secretbox.New(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sealed, err := SealProviderRefreshToken(box, "refresh-token")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !sealed.Valid || sealed.String == "refresh-token" {
+		t.Fatalf("expected the token to be encrypted, got %+v", sealed)
+	}
+
+	admin := Admin{ProviderRefreshToken: sealed}
+	token, err := admin.PlainProviderRefreshToken(box)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if token != "refresh-token" {
+		t.Errorf("expected the token back, got %q", token)
+	}
+}
+
+func TestProviderRefreshTokenIsNotKeptWithoutKey(t *testing.T) {
+	sealed, err := SealProviderRefreshToken(nil, "refresh-token")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sealed.Valid {
+		t.Errorf("expected no token to be stored, got %q", sealed.String)
+	}
+}
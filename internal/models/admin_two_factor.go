@@ -0,0 +1,169 @@
+package models
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"time"
+
+	"be20250107/internal/modules/secretbox"
+	"be20250107/utils/database"
+	"be20250107/utils/random"
+
+	"gopkg.in/guregu/null.v4"
+)
+
+const (
+	recoveryCodeCount  = 10
+	recoveryCodeLength = 10
+)
+
+// HasTwoFactor reports whether the admin has completed TOTP enrollment.
+func (a *Admin) HasTwoFactor() bool {
+	return a.TOTPEnabledAt.Valid && a.TOTPSecret.Valid
+}
+
+// RequiresTwoFactor reports whether the admin must pass a TOTP challenge after
+// logging in, either because they enrolled or because their role enforces it.
+func (a *Admin) RequiresTwoFactor(db database.Queryer) (bool, error) {
+	if a.HasTwoFactor() {
+		return true, nil
+	}
+	if err := a.LoadRole(db, false); err != nil {
+		return false, fmt.Errorf("[a.RequiresTwoFactor]%w", err)
+	}
+	return a.Role != nil && a.Role.RequireTwoFactor, nil
+}
+
+// UpdateTOTP stores the TOTP secret, sealed with SealTOTPSecret, and the time
+// enrollment was completed. An invalid secret disables TOTP.
+func (a *Admin) UpdateTOTP(db database.TxQueryer, secret null.String, enabledAt null.Time) error {
+	a.TOTPSecret = secret
+	a.TOTPEnabledAt = enabledAt
+	_, err := db.Exec("UPDATE admins SET totp_secret = ?, totp_enabled_at = ? WHERE id = ?", a.TOTPSecret, a.TOTPEnabledAt, a.ID)
+	if err != nil {
+		return fmt.Errorf("[a.UpdateTOTP][Exec]%w", err)
+	}
+	return nil
+}
+
+// SealTOTPSecret encrypts a new TOTP secret before it is stored with
+// UpdateTOTP.
+func SealTOTPSecret(box *secretbox.Box, secret string) (null.String, error) {
+	sealed, err := box.Seal(secret)
+	if err != nil {
+		return null.String{}, fmt.Errorf("[SealTOTPSecret][Seal]%w", err)
+	}
+	return null.StringFrom(sealed), nil
+}
+
+// PlainTOTPSecret decrypts the TOTP secret of the admin. Secrets stored before
+// they were encrypted are returned as is.
+func (a *Admin) PlainTOTPSecret(box *secretbox.Box) (string, error) {
+	if !secretbox.IsSealed(a.TOTPSecret.String) {
+		return a.TOTPSecret.String, nil
+	}
+	secret, err := box.Open(a.TOTPSecret.String)
+	if err != nil {
+		return "", fmt.Errorf("[a.PlainTOTPSecret][Open]%w", err)
+	}
+	return secret, nil
+}
+
+// ResealTOTPSecret encrypts a TOTP secret stored before secrets were
+// encrypted. It does nothing if the secret is encrypted or there is no box.
+func (a *Admin) ResealTOTPSecret(db database.Queryer, box *secretbox.Box) error {
+	if !a.TOTPSecret.Valid || secretbox.IsSealed(a.TOTPSecret.String) || box == nil {
+		return nil
+	}
+	sealed, err := SealTOTPSecret(box, a.TOTPSecret.String)
+	if err != nil {
+		return fmt.Errorf("[a.ResealTOTPSecret]%w", err)
+	}
+	if err := a.UpdateTOTP(db, sealed, a.TOTPEnabledAt); err != nil {
+		return fmt.Errorf("[a.ResealTOTPSecret]%w", err)
+	}
+	return nil
+}
+
+type AdminRecoveryCode struct {
+	Model
+	AdminID  string    `json:"admin_id" db:"admin_id"`
+	CodeHash string    `json:"-" db:"code_hash"`
+	UsedAt   null.Time `json:"used_at" db:"used_at"`
+}
+
+func hashRecoveryCode(code string) string {
+	code = strings.ToLower(strings.ReplaceAll(strings.TrimSpace(code), "-", ""))
+	sum := sha256.Sum256([]byte(code))
+	return hex.EncodeToString(sum[:])
+}
+
+// GenerateRecoveryCodes replaces the recovery codes of the admin and returns
+// the new plain codes, which are not stored.
+func GenerateRecoveryCodes(db database.TxQueryer, adminID string) ([]string, error) {
+	_, err := db.Exec("DELETE FROM admin_recovery_codes WHERE admin_id = ?", adminID)
+	if err != nil {
+		return nil, fmt.Errorf("[GenerateRecoveryCodes][Exec]%w", err)
+	}
+
+	charset := random.LowercaseAlphabeticCharset + random.NumericCharset
+	codes := make([]string, recoveryCodeCount)
+	for i := range codes {
+		code := random.GenerateString(recoveryCodeLength, charset)
+		codes[i] = code[:recoveryCodeLength/2] + "-" + code[recoveryCodeLength/2:]
+
+		rc := AdminRecoveryCode{AdminID: adminID, CodeHash: hashRecoveryCode(code)}
+		rc.BeforeInsert("admin_recovery_codes")
+		q := `
+			INSERT INTO admin_recovery_codes
+			(id, admin_id, code_hash, used_at, created_at, updated_at)
+			VALUES
+			(:id, :admin_id, :code_hash, :used_at, :created_at, :updated_at)
+		`
+		if _, err := db.NamedExec(q, rc); err != nil {
+			return nil, fmt.Errorf("[GenerateRecoveryCodes][NamedExec]%w", err)
+		}
+	}
+	return codes, nil
+}
+
+// UseRecoveryCode marks an unused recovery code of the admin as used. It
+// returns false if the code does not exist or has been used.
+func UseRecoveryCode(db database.Queryer, adminID string, code string) (bool, error) {
+	q := `
+		UPDATE admin_recovery_codes SET used_at = ?, updated_at = ?
+		WHERE admin_id = ? AND code_hash = ? AND used_at IS NULL
+		LIMIT 1
+	`
+	now := time.Now()
+	res, err := db.Exec(q, now, now.Unix(), adminID, hashRecoveryCode(code))
+	if err != nil {
+		return false, fmt.Errorf("[UseRecoveryCode][Exec]%w", err)
+	}
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("[UseRecoveryCode][RowsAffected]%w", err)
+	}
+	return affected > 0, nil
+}
+
+// DeleteRecoveryCodes removes every recovery code of the admin.
+func DeleteRecoveryCodes(db database.Queryer, adminID string) error {
+	_, err := db.Exec("DELETE FROM admin_recovery_codes WHERE admin_id = ?", adminID)
+	if err != nil {
+		return fmt.Errorf("[DeleteRecoveryCodes][Exec]%w", err)
+	}
+	return nil
+}
+
+// CountUnusedRecoveryCodes returns the number of recovery codes left.
+func CountUnusedRecoveryCodes(db database.Queryer, adminID string) (int, error) {
+	var count int
+	err := db.Get(&count, "SELECT COUNT(*) FROM admin_recovery_codes WHERE admin_id = ? AND used_at IS NULL", adminID)
+	if err != nil {
+		return 0, fmt.Errorf("[CountUnusedRecoveryCodes][Get]%w", err)
+	}
+	return count, nil
+}
@@ -0,0 +1,52 @@
+package models
+
+import (
+	"testing"
+
+	"be20250107/internal/modules/secretbox"
+
+	"gopkg.in/guregu/null.v4"
+)
+
+func TestTOTPSecretIsSealed(t *testing.T) {
+	key, err := secretbox.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	box, err := secretbox.New(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sealed, err := SealTOTPSecret(box, "JBSWY3DPEHPK3PXP")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sealed.String == "JBSWY3DPEHPK3PXP" {
+		t.Fatal("expected the secret to be encrypted")
+	}
+
+	admin := Admin{TOTPSecret: sealed}
+	secret, err := admin.PlainTOTPSecret(box)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if secret != "JBSWY3DPEHPK3PXP" {
+		t.Errorf("expected the secret back, got %q", secret)
+	}
+
+	if _, err := SealTOTPSecret(nil, "JBSWY3DPEHPK3PXP"); err == nil {
+		t.Error("expected an error without an encryption key")
+	}
+}
+
+func TestPlainTOTPSecretAcceptsUnencryptedSecrets(t *testing.T) {
+	admin := Admin{TOTPSecret: null.StringFrom("JBSWY3DPEHPK3PXP")}
+	secret, err := admin.PlainTOTPSecret(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if secret != "JBSWY3DPEHPK3PXP" {
+		t.Errorf("expected the stored secret, got %q", secret)
+	}
+}
@@ -0,0 +1,216 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	"be20250107/utils/database"
+	"be20250107/utils/random"
+
+	"gopkg.in/guregu/null.v4"
+)
+
+const (
+	apiKeyPrefixLength = 8
+	apiKeySecretLength = 40
+
+	// lastUsedAtResolution is how stale last_used_at of a key may be.
+	lastUsedAtResolution = time.Minute
+)
+
+type APIKey struct {
+	Model
+	Name   string `json:"name" db:"name"`
+	Prefix string `json:"prefix" db:"prefix"`
+	// KeyHash is the SHA-256 of the plain key, which is only shown once.
+	KeyHash string `json:"-" db:"key_hash"`
+	// Scopes is the space separated list of scopes granted to the key.
+	Scopes string `json:"scopes" db:"scopes"`
+	// AllowedIPs is the space separated list of IP addresses or CIDR ranges the
+	// key can be used from. Empty allows every address.
+	AllowedIPs null.String `json:"allowed_ips" db:"allowed_ips"`
+	DailyQuota null.Int    `json:"daily_quota" db:"daily_quota"`
+	CreatedBy  null.String `json:"created_by" db:"created_by"`
+	LastUsedAt null.Time   `json:"last_used_at" db:"last_used_at"`
+	ExpiredAt  null.Time   `json:"expired_at" db:"expired_at"`
+	RevokedAt  null.Time   `json:"revoked_at" db:"revoked_at"`
+	PlainKey   string      `json:"-" db:"-"`
+}
+
+type APIKeyUsage struct {
+	APIKeyID      string `json:"api_key_id" db:"api_key_id"`
+	Date          string `json:"date" db:"date"`
+	RequestCount  int64  `json:"request_count" db:"request_count"`
+	RejectedCount int64  `json:"rejected_count" db:"rejected_count"`
+}
+
+// GenerateAPIKey returns a new plain key in the form hk_<prefix>_<secret>. The
+// prefix is stored in clear so admins can recognize the key.
+func GenerateAPIKey() (prefix string, key string) {
+	charset := random.LowercaseAlphabeticCharset + random.NumericCharset
+	prefix = random.GenerateString(apiKeyPrefixLength, charset)
+	secret := random.GenerateString(apiKeySecretLength, random.UppercaseAlphabeticCharset+charset)
+	return prefix, fmt.Sprintf("hk_%s_%s", prefix, secret)
+}
+
+func (k *APIKey) Insert(db database.Queryer) error {
+	k.BeforeInsert("api_keys")
+
+	if k.PlainKey != "" {
+		k.KeyHash = hashSecretKey(k.PlainKey)
+		k.PlainKey = ""
+	}
+
+	q := `
+		INSERT INTO api_keys
+		(id, name, prefix, key_hash, scopes, allowed_ips, daily_quota, created_by, last_used_at, expired_at, revoked_at, created_at, updated_at)
+		VALUES
+		(:id, :name, :prefix, :key_hash, :scopes, :allowed_ips, :daily_quota, :created_by, :last_used_at, :expired_at, :revoked_at, :created_at, :updated_at)
+	`
+	_, err := db.NamedExec(q, k)
+	if err != nil {
+		return fmt.Errorf("[k.Insert][NamedExec]%w", err)
+	}
+	return nil
+}
+
+func (k *APIKey) Update(db database.Queryer) error {
+	k.BeforeUpdate()
+
+	q := `
+		UPDATE api_keys SET
+			name = :name,
+			scopes = :scopes,
+			allowed_ips = :allowed_ips,
+			daily_quota = :daily_quota,
+			expired_at = :expired_at,
+			revoked_at = :revoked_at,
+			updated_at = :updated_at
+		WHERE id = :id
+	`
+	_, err := db.NamedExec(q, k)
+	if err != nil {
+		return fmt.Errorf("[k.Update][NamedExec]%w", err)
+	}
+	return nil
+}
+
+// IsActive reports whether the key has neither been revoked nor expired.
+func (k *APIKey) IsActive() bool {
+	if k.RevokedAt.Valid {
+		return false
+	}
+	return !k.ExpiredAt.Valid || k.ExpiredAt.Time.After(time.Now())
+}
+
+// AllowsIP reports whether the key can be used from the IP address.
+func (k *APIKey) AllowsIP(ip string) bool {
+	allowed := strings.Fields(k.AllowedIPs.ValueOrZero())
+	if len(allowed) == 0 {
+		return true
+	}
+
+	parsed := net.ParseIP(ip)
+	if parsed == nil {
+		return false
+	}
+	for _, a := range allowed {
+		if strings.Contains(a, "/") {
+			if _, ipNet, err := net.ParseCIDR(a); err == nil && ipNet.Contains(parsed) {
+				return true
+			}
+		} else if allowedIP := net.ParseIP(a); allowedIP != nil && allowedIP.Equal(parsed) {
+			return true
+		}
+	}
+	return false
+}
+
+// RecordUsage counts a request admitted with the key on the given day. It
+// updates last_used_at only once it is lastUsedAtResolution old, so that a
+// busy key does not write its row on every request.
+func (k *APIKey) RecordUsage(db database.Queryer, day time.Time) error {
+	q := `
+		INSERT INTO api_key_usages (api_key_id, date, request_count)
+		VALUES (?, ?, 1)
+		ON DUPLICATE KEY UPDATE request_count = request_count + 1
+	`
+	if _, err := db.Exec(q, k.ID, day.Format("2006-01-02")); err != nil {
+		return fmt.Errorf("[k.RecordUsage][Exec]%w", err)
+	}
+
+	if k.LastUsedAt.Valid && day.Sub(k.LastUsedAt.Time) < lastUsedAtResolution {
+		return nil
+	}
+	if _, err := db.Exec("UPDATE api_keys SET last_used_at = ? WHERE id = ?", day, k.ID); err != nil {
+		return fmt.Errorf("[k.RecordUsage][Exec]%w", err)
+	}
+	k.LastUsedAt = null.TimeFrom(day)
+	return nil
+}
+
+// RecordRejection counts a request rejected because the quota was exceeded.
+func (k *APIKey) RecordRejection(db database.Queryer, day time.Time) error {
+	q := `
+		INSERT INTO api_key_usages (api_key_id, date, rejected_count)
+		VALUES (?, ?, 1)
+		ON DUPLICATE KEY UPDATE rejected_count = rejected_count + 1
+	`
+	if _, err := db.Exec(q, k.ID, day.Format("2006-01-02")); err != nil {
+		return fmt.Errorf("[k.RecordRejection][Exec]%w", err)
+	}
+	return nil
+}
+
+func GetAPIKeyByID(db database.Queryer, id string) (*APIKey, bool, error) {
+	var key APIKey
+	err := db.Get(&key, "SELECT * FROM api_keys WHERE id = ?", id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, false, nil
+	} else if err != nil {
+		return nil, false, fmt.Errorf("[GetAPIKeyByID][Get]%w", err)
+	}
+	return &key, true, nil
+}
+
+// GetAPIKeyByPlainKey finds the key matching the plain key sent by a client.
+func GetAPIKeyByPlainKey(db database.Queryer, plainKey string) (*APIKey, bool, error) {
+	var key APIKey
+	err := db.Get(&key, "SELECT * FROM api_keys WHERE key_hash = ?", hashSecretKey(plainKey))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, false, nil
+	} else if err != nil {
+		return nil, false, fmt.Errorf("[GetAPIKeyByPlainKey][Get]%w", err)
+	}
+	return &key, true, nil
+}
+
+func GetAPIKeys(db database.Queryer) ([]APIKey, error) {
+	keys := []APIKey{}
+	err := db.Select(&keys, "SELECT * FROM api_keys ORDER BY created_at DESC")
+	if err != nil {
+		return nil, fmt.Errorf("[GetAPIKeys][Select]%w", err)
+	}
+	return keys, nil
+}
+
+// GetAPIKeyUsages returns the daily usage of the key since the given day,
+// newest first.
+func GetAPIKeyUsages(db database.Queryer, apiKeyID string, since time.Time) ([]APIKeyUsage, error) {
+	q := `
+		SELECT api_key_id, DATE_FORMAT(date, '%Y-%m-%d') AS date, request_count, rejected_count
+		FROM api_key_usages
+		WHERE api_key_id = ? AND date >= ?
+		ORDER BY date DESC
+	`
+	usages := []APIKeyUsage{}
+	err := db.Select(&usages, q, apiKeyID, since.Format("2006-01-02"))
+	if err != nil {
+		return nil, fmt.Errorf("[GetAPIKeyUsages][Select]%w", err)
+	}
+	return usages, nil
+}
@@ -0,0 +1,58 @@
+package models
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"be20250107/utils/database"
+
+	"gopkg.in/guregu/null.v4"
+)
+
+const (
+	AuditTokenFingerprintMismatch = "token_fingerprint_mismatch"
+	AuditRoleTwoFactorChanged     = "role_two_factor_changed"
+)
+
+// AuditLog records a security relevant event, such as a request made with a
+// token from another device.
+type AuditLog struct {
+	Model
+	Event       string      `json:"event" db:"event"`
+	AccountType null.String `json:"account_type" db:"account_type"`
+	AccountID   null.String `json:"account_id" db:"account_id"`
+	TokenID     null.String `json:"token_id" db:"token_id"`
+	IPAddress   null.String `json:"ip_address" db:"ip_address"`
+	UserAgent   null.String `json:"user_agent" db:"user_agent"`
+	Data        null.String `json:"-" db:"data"`
+}
+
+func (l *AuditLog) Insert(db database.TxQueryer) error {
+	l.BeforeInsert("audit_logs")
+
+	q := `
+		INSERT INTO audit_logs
+		(id, event, account_type, account_id, token_id, ip_address, user_agent, data, created_at, updated_at)
+		VALUES
+		(:id, :event, :account_type, :account_id, :token_id, :ip_address, :user_agent, :data, :created_at, :updated_at)
+	`
+	_, err := db.NamedExec(q, l)
+	if err != nil {
+		return fmt.Errorf("[l.Insert][NamedExec]%w", err)
+	}
+	return nil
+}
+
+// SetData stores data as the JSON data column.
+func (l *AuditLog) SetData(data map[string]any) error {
+	if len(data) == 0 {
+		l.Data = null.String{}
+		return nil
+	}
+	b, err := json.Marshal(data)
+	if err != nil {
+		return fmt.Errorf("[l.SetData][Marshal]%w", err)
+	}
+	l.Data = null.StringFrom(string(b))
+	return nil
+}
@@ -9,6 +9,7 @@ import (
 	"net/http"
 	"os"
 	"path/filepath"
+	"strings"
 	"time"
 )
 
@@ -42,6 +43,14 @@ type Catalogue struct {
 	UpdatedBy      string         `db:"updated_by" json:"updated_by"`
 	DeletedBy      *string        `db:"deleted_by" json:"deleted_by"`
 	Tags           []Tag          `json:"categories"`
+
+	// previous holds the values replaced by Update, for the events it causes.
+	previous *catalogueState
+}
+
+type catalogueState struct {
+	Price       float64    `db:"price"`
+	PublishedAt *time.Time `db:"published_at"`
 }
 
 type Installment struct {
@@ -94,7 +103,7 @@ func (p *Catalogue) Insert(tx database.TxQueryer, r *http.Request) error {
 
 	// Step 1: Insert into catalogues table and get the inserted ID
 	query := `
-        INSERT INTO catalogues (name, brand_id, category_id, specifications, price, image_url, created_by, updated_by) VALUES (:name, :brand_id, :category_id, :specifications, :price, :image_url, :created_by, :updated_by);`
+        INSERT INTO catalogues (name, brand_id, category_id, specifications, price, image_url, published_at, created_by, updated_by) VALUES (:name, :brand_id, :category_id, :specifications, :price, :image_url, :published_at, :created_by, :updated_by);`
 	result, err := tx.NamedExec(query, map[string]interface{}{
 		"name":           p.Name,
 		"brand_id":       p.BrandID,
@@ -102,6 +111,7 @@ func (p *Catalogue) Insert(tx database.TxQueryer, r *http.Request) error {
 		"specifications": string(specs),
 		"price":          p.Price,
 		"image_url":      filePath,
+		"published_at":   p.PublishedAt,
 		"created_by":     p.CreatedBy,
 		"updated_by":     p.UpdatedBy,
 	})
@@ -204,6 +214,8 @@ func (b *Brand) Insert(tx database.TxQueryer) error {
 	return nil
 }
 
+// Update renames the brand. Once the transaction is committed, the caller must
+// invalidate BrandTag in the CatalogueCache and publish UpdatedEvent.
 func (b *Brand) Update(tx database.TxQueryer) error {
 	query := `UPDATE brands SET name = :name, updated_at = CURRENT_TIMESTAMP WHERE id = :id;`
 	_, err := tx.NamedExec(query, b)
@@ -213,6 +225,8 @@ func (b *Brand) Update(tx database.TxQueryer) error {
 	return nil
 }
 
+// Delete deletes the brand. Once the transaction is committed, the caller must
+// invalidate BrandTag in the CatalogueCache and publish UpdatedEvent.
 func (b *Brand) Delete(tx database.TxQueryer) error {
 	query := "DELETE FROM brands WHERE id = :id;"
 	_, err := tx.NamedExec(query, b)
@@ -249,6 +263,8 @@ func (c *Tag) Insert(tx database.TxQueryer) error {
 	return nil
 }
 
+// Update updates the category. Once the transaction is committed, the caller
+// must invalidate CategoryTag in the CatalogueCache and publish UpdatedEvent.
 func (c *Tag) Update(tx database.TxQueryer) error {
 	query := `UPDATE categories SET name = :name, description = :description, updated_at = CURRENT_TIMESTAMP WHERE id = :id;`
 	_, err := tx.NamedExec(query, c)
@@ -257,6 +273,10 @@ func (c *Tag) Update(tx database.TxQueryer) error {
 	}
 	return nil
 }
+
+// Delete soft deletes the category. Once the transaction is committed, the
+// caller must invalidate CategoryTag in the CatalogueCache and publish
+// UpdatedEvent.
 func (c *Tag) Delete(tx database.TxQueryer) error {
 	query := "UPDATE categories SET deleted_at = CURRENT_TIMESTAMP WHERE id = :id;"
 	_, err := tx.NamedExec(query, c)
@@ -280,12 +300,13 @@ func (p *Catalogue) Update(tx database.TxQueryer) error {
 		return fmt.Errorf("[Catalogue.Update][Marshal Specifications]%w", err)
 	}
 
-	// Get the old price
-	var oldPrice float64
-	err = tx.Get(&oldPrice, "SELECT price FROM catalogues WHERE id=?", p.ID)
+	// Get the old price and publication time
+	var previous catalogueState
+	err = tx.Get(&previous, "SELECT price, published_at FROM catalogues WHERE id=?", p.ID)
 	if err != nil {
 		return fmt.Errorf("[Catalogue.Update][Get old price]%w", err)
 	}
+	oldPrice := previous.Price
 
 	// Insert price change into PriceHistory
 	priceHistory := PriceHistory{
@@ -327,6 +348,7 @@ func (p *Catalogue) Update(tx database.TxQueryer) error {
 			return fmt.Errorf("[Catalogue.Update][InsertTag]%w", err)
 		}
 	}
+	p.previous = &previous
 	return nil
 }
 
@@ -338,7 +360,16 @@ func (p *Catalogue) Delete(tx database.TxQueryer) error {
 	}
 	return nil
 }
-func GetCatalogues(db database.Queryer, limit, offset int, sortBy, order, filterBy, filterValue string) ([]Catalogue, int, error) {
+
+// likeEscaper escapes the wildcards of a LIKE pattern, so that a filter value
+// matches literally.
+var likeEscaper = strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`)
+
+func GetCatalogues(db database.Queryer, q CatalogueQuery) ([]Catalogue, int, error) {
+	q, err := q.Normalize()
+	if err != nil {
+		return nil, 0, fmt.Errorf("[GetCatalogues][Normalize]%w", err)
+	}
 	Catalogues := []Catalogue{}
 	baseQuery := `
        SELECT catalogues.id, catalogues.name, catalogues.brand_id,  brands.name AS brand_name, catalogues.specifications,catalogues.image_url, catalogues.price, catalogues.created_at, catalogues.updated_at, catalogues.deleted_at, catalogues.published_at 
@@ -346,29 +377,33 @@ func GetCatalogues(db database.Queryer, limit, offset int, sortBy, order, filter
        JOIN brands ON catalogues.brand_id = brands.id
        WHERE catalogues.deleted_at IS NULL
        `
+	//	Only whitelisted columns are written into the query, the filter value
+	//	is bound.
 	filterQuery := ""
-	if filterBy != "" && filterValue != "" {
-		filterQuery = fmt.Sprintf("AND %s LIKE '%%%s%%'", filterBy, filterValue)
+	var args []any
+	if q.FilterBy != "" {
+		filterQuery = fmt.Sprintf("AND %s LIKE ?", catalogueFilterColumns[q.FilterBy])
+		args = append(args, "%"+likeEscaper.Replace(q.FilterValue)+"%")
 	}
 	sortQuery := ""
-	if sortBy != "" && order != "" {
-		sortQuery = fmt.Sprintf("ORDER BY %s %s", sortBy, order)
+	if q.SortBy != "" {
+		sortQuery = fmt.Sprintf("ORDER BY %s %s", catalogueSortColumns[q.SortBy], q.Order)
 	}
 	paginationQuery := ""
-	if limit > 0 {
-		paginationQuery = fmt.Sprintf("LIMIT %d OFFSET %d", limit, offset)
+	if q.Limit > 0 {
+		paginationQuery = fmt.Sprintf("LIMIT %d OFFSET %d", q.Limit, q.Offset)
 	}
 
 	query := fmt.Sprintf("%s %s %s %s", baseQuery, filterQuery, sortQuery, paginationQuery)
 	countQuery := fmt.Sprintf("SELECT COUNT(*) FROM catalogues JOIN brands ON catalogues.brand_id = brands.id WHERE catalogues.deleted_at IS NULL %s", filterQuery)
 
 	var totalCount int
-	err := db.Get(&totalCount, countQuery)
+	err = db.Get(&totalCount, countQuery, args...)
 	if err != nil {
 		return nil, 0, fmt.Errorf("[GetCatalogues][Count]%w", err)
 	}
 
-	rows, err := db.Query(query)
+	rows, err := db.Query(query, args...)
 	if err != nil {
 		return nil, 0, fmt.Errorf("[GetCatalogues][Query]%w", err)
 	}
@@ -385,7 +420,7 @@ func GetCatalogues(db database.Queryer, limit, offset int, sortBy, order, filter
 		// Unmarshal the JSON stored in the 'specifications' field.
 		err = json.Unmarshal([]byte(specifications), &c.Specifications)
 		if err != nil {
-			return nil, 0, fmt.Errorf("[GetCatalogues][Unmarshal Specifications]%w", &c.Specifications)
+			return nil, 0, fmt.Errorf("[GetCatalogues][Unmarshal Specifications]%w", err)
 		}
 
 		categories, err := GetCategoriesForCatalogue(db, c.ID)
@@ -0,0 +1,267 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"be20250107/internal/modules/cache"
+	"be20250107/utils/database"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// CacheResult tells how a cached read was served.
+type CacheResult string
+
+const (
+	CacheHit    CacheResult = "HIT"
+	CacheMiss   CacheResult = "MISS"
+	CacheBypass CacheResult = "BYPASS"
+)
+
+// CataloguesTag is carried by every cached catalogue listing, which any
+// catalogue write may change.
+const CataloguesTag = "catalogues"
+
+// catalogueGenerationKey counts the invalidations. A read only keeps what it
+// loaded if no invalidation happened meanwhile, since it may have loaded the
+// rows from before the write.
+const catalogueGenerationKey = "catalogue:generation"
+
+var catalogueCacheRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Name: "catalogue_cache_requests_total",
+	Help: "Total number of catalogue reads by cache result.",
+}, []string{"job", "kind", "result"})
+
+func init() {
+	prometheus.MustRegister(catalogueCacheRequests)
+}
+
+func CatalogueTag(id int) string {
+	return fmt.Sprintf("catalogue:%d", id)
+}
+
+// BrandTag and CategoryTag are carried by the entries showing the brand or
+// category, which must be invalidated when it is updated or deleted.
+func BrandTag(id int) string {
+	return fmt.Sprintf("brand:%d", id)
+}
+
+func CategoryTag(id int) string {
+	return fmt.Sprintf("category:%d", id)
+}
+
+// CatalogueQuery holds the parameters of a catalogue listing.
+type CatalogueQuery struct {
+	Limit       int
+	Offset      int
+	SortBy      string
+	Order       string
+	FilterBy    string
+	FilterValue string
+}
+
+// ErrInvalidCatalogueQuery is returned for a listing sorted or filtered by a
+// column that is not in catalogueSortColumns or catalogueFilterColumns.
+var ErrInvalidCatalogueQuery = errors.New("invalid catalogue query")
+
+// catalogueSortColumns and catalogueFilterColumns map the names clients sort
+// and filter by to the columns of the listing query.
+var (
+	catalogueSortColumns = map[string]string{
+		"id":           "catalogues.id",
+		"name":         "catalogues.name",
+		"brand_name":   "brands.name",
+		"price":        "catalogues.price",
+		"created_at":   "catalogues.created_at",
+		"updated_at":   "catalogues.updated_at",
+		"published_at": "catalogues.published_at",
+	}
+	catalogueFilterColumns = map[string]string{
+		"name":       "catalogues.name",
+		"brand_name": "brands.name",
+	}
+)
+
+// Normalize returns the query with the parameters that do not change the
+// result cleared, so that equivalent queries share a cache entry. It fails
+// with ErrInvalidCatalogueQuery for columns that cannot be sorted or filtered
+// by and orders other than ASC and DESC.
+func (q CatalogueQuery) Normalize() (CatalogueQuery, error) {
+	q.SortBy = strings.TrimSpace(q.SortBy)
+	q.Order = strings.ToUpper(strings.TrimSpace(q.Order))
+	q.FilterBy = strings.TrimSpace(q.FilterBy)
+	if _, ok := catalogueSortColumns[q.SortBy]; q.SortBy != "" && !ok {
+		return CatalogueQuery{}, fmt.Errorf("%w: cannot sort by %q", ErrInvalidCatalogueQuery, q.SortBy)
+	}
+	if q.Order != "" && q.Order != "ASC" && q.Order != "DESC" {
+		return CatalogueQuery{}, fmt.Errorf("%w: order must be ASC or DESC", ErrInvalidCatalogueQuery)
+	}
+	if _, ok := catalogueFilterColumns[q.FilterBy]; q.FilterBy != "" && !ok {
+		return CatalogueQuery{}, fmt.Errorf("%w: cannot filter by %q", ErrInvalidCatalogueQuery, q.FilterBy)
+	}
+	if q.SortBy == "" || q.Order == "" {
+		q.SortBy, q.Order = "", ""
+	}
+	if q.FilterBy == "" || q.FilterValue == "" {
+		q.FilterBy, q.FilterValue = "", ""
+	}
+	if q.Limit <= 0 {
+		q.Limit, q.Offset = 0, 0
+	}
+	return q, nil
+}
+
+func (q CatalogueQuery) cacheKey() string {
+	values := url.Values{
+		"limit":        {strconv.Itoa(q.Limit)},
+		"offset":       {strconv.Itoa(q.Offset)},
+		"sort_by":      {q.SortBy},
+		"order":        {q.Order},
+		"filter_by":    {q.FilterBy},
+		"filter_value": {q.FilterValue},
+	}
+	return "catalogue:list:" + values.Encode()
+}
+
+// CataloguePage is a page of a catalogue listing.
+type CataloguePage struct {
+	Catalogues []Catalogue
+	TotalCount int
+}
+
+// CatalogueCache reads catalogues through the cache. Entries are tagged with
+// the catalogues, brands and categories they show, so that writes can drop
+// them with Invalidate. A nil CatalogueCache reads the database directly.
+type CatalogueCache struct {
+	Cache cache.Cache
+	TTL   time.Duration
+	// Job labels the metrics, as the job of MetricsMiddleware does.
+	Job string
+}
+
+func NewCatalogueCache(c cache.Cache, ttl time.Duration) *CatalogueCache {
+	return &CatalogueCache{Cache: c, TTL: ttl}
+}
+
+// GetCatalogues returns the listing of the query. Bypassing the cache reads
+// the database and refreshes the entry.
+func (cc *CatalogueCache) GetCatalogues(db database.Queryer, q CatalogueQuery, bypass bool) (CataloguePage, CacheResult, error) {
+	q, err := q.Normalize()
+	if err != nil {
+		return CataloguePage{}, "", err
+	}
+	page, result, err := readThrough(cc, "list", q.cacheKey(), bypass, func() (CataloguePage, []string, error) {
+		catalogues, totalCount, err := GetCatalogues(db, q)
+		if err != nil {
+			return CataloguePage{}, nil, err
+		}
+		tags := []string{CataloguesTag}
+		for _, c := range catalogues {
+			tags = append(tags, c.cacheTags()...)
+		}
+		return CataloguePage{Catalogues: catalogues, TotalCount: totalCount}, tags, nil
+	})
+	if page.Catalogues == nil {
+		//	An empty listing decodes as nil, which would be sent as null.
+		page.Catalogues = []Catalogue{}
+	}
+	return page, result, err
+}
+
+// GetCatalogue returns the catalogue of the ID. Bypassing the cache reads the
+// database and refreshes the entry.
+func (cc *CatalogueCache) GetCatalogue(db database.Queryer, id int, bypass bool) (Catalogue, CacheResult, error) {
+	key := fmt.Sprintf("catalogue:detail:%d", id)
+	return readThrough(cc, "detail", key, bypass, func() (Catalogue, []string, error) {
+		catalogue, err := GetCatalogue(db, id)
+		if err != nil {
+			return Catalogue{}, nil, err
+		}
+		return catalogue, catalogue.cacheTags(), nil
+	})
+}
+
+// Invalidate drops the entries carrying any of the tags. It must be called
+// after the write is committed, or a concurrent read may cache the old rows
+// again.
+func (cc *CatalogueCache) Invalidate(tags ...string) error {
+	if cc == nil || cc.Cache == nil {
+		return nil
+	}
+	//	Bump the generation first, so that a read storing its value after the
+	//	tags are dropped sees the bump.
+	if _, err := cc.generationCache().Increment(catalogueGenerationKey, nil); err != nil {
+		return fmt.Errorf("[CatalogueCache.Invalidate][Increment]%w", err)
+	}
+	if err := cc.Cache.InvalidateTags(tags...); err != nil {
+		return fmt.Errorf("[CatalogueCache.Invalidate][InvalidateTags]%w", err)
+	}
+	return nil
+}
+
+// generationCache is the engine behind the cache, since the generation must
+// not be served from the local tier of a TieredCache.
+func (cc *CatalogueCache) generationCache() cache.Cache {
+	return cache.Engine(cc.Cache)
+}
+
+func (cc *CatalogueCache) generation() (int, error) {
+	n, err := cc.generationCache().GetInt(catalogueGenerationKey)
+	if errors.Is(err, cache.ErrKeyNotFound) {
+		return 0, nil
+	}
+	return n, err
+}
+
+func (p *Catalogue) cacheTags() []string {
+	tags := []string{CatalogueTag(p.ID), BrandTag(p.BrandID)}
+	for _, t := range p.Tags {
+		tags = append(tags, CategoryTag(t.ID))
+	}
+	return tags
+}
+
+// readThrough returns the cached value of the key, or loads it and caches it
+// with the tags load returns. Cache errors are treated as misses so that a
+// cache outage does not fail reads. The loaded value is dropped again if the
+// generation changed while it was loaded or stored, since an invalidation
+// racing the read may have missed it.
+func readThrough[T any](cc *CatalogueCache, kind string, key string, bypass bool, load func() (T, []string, error)) (T, CacheResult, error) {
+	if cc == nil || cc.Cache == nil {
+		value, _, err := load()
+		return value, CacheBypass, err
+	}
+
+	result := CacheBypass
+	if !bypass {
+		var value T
+		_, err := cc.Cache.GetValue(key, &value)
+		if err == nil {
+			catalogueCacheRequests.WithLabelValues(cc.Job, kind, "hit").Inc()
+			return value, CacheHit, nil
+		}
+		if !errors.Is(err, cache.ErrKeyNotFound) {
+			catalogueCacheRequests.WithLabelValues(cc.Job, kind, "error").Inc()
+		}
+		result = CacheMiss
+	}
+	catalogueCacheRequests.WithLabelValues(cc.Job, kind, strings.ToLower(string(result))).Inc()
+
+	generation, genErr := cc.generation()
+	value, tags, err := load()
+	if err != nil || genErr != nil {
+		return value, result, err
+	}
+	if err := cc.Cache.PutValue(key, value, &cache.Options{Expiration: cc.TTL, Tags: tags}); err != nil {
+		return value, result, nil
+	}
+	if current, err := cc.generation(); err != nil || current != generation {
+		_ = cc.Cache.Delete(key)
+	}
+	return value, result, nil
+}
@@ -0,0 +1,142 @@
+package models
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"be20250107/internal/modules/cache"
+)
+
+func newTestCatalogueCache() *CatalogueCache {
+	return NewCatalogueCache(cache.NewInMemoryCache(nil), time.Hour)
+}
+
+// countingLoad returns a load function of readThrough returning the catalogue
+// with the tags, counting its calls.
+func countingLoad(calls *int, catalogue Catalogue) func() (Catalogue, []string, error) {
+	return func() (Catalogue, []string, error) {
+		*calls++
+		return catalogue, catalogue.cacheTags(), nil
+	}
+}
+
+func TestReadThrough(t *testing.T) {
+	cc := newTestCatalogueCache()
+	calls := 0
+	load := countingLoad(&calls, Catalogue{ID: 1, Name: "first", BrandID: 2})
+
+	for i, expected := range []CacheResult{CacheMiss, CacheHit, CacheHit} {
+		value, result, err := readThrough(cc, "detail", "catalogue:detail:1", false, load)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if result != expected {
+			t.Errorf("read %d: expected %s, got %s", i, expected, result)
+		}
+		if value.Name != "first" {
+			t.Errorf("read %d: expected the catalogue, got %+v", i, value)
+		}
+	}
+	if calls != 1 {
+		t.Errorf("expected the database to be read once, got %d", calls)
+	}
+}
+
+func TestReadThroughBypass(t *testing.T) {
+	cc := newTestCatalogueCache()
+	calls := 0
+	if _, _, err := readThrough(cc, "detail", "catalogue:detail:1", false, countingLoad(&calls, Catalogue{ID: 1, Name: "first"})); err != nil {
+		t.Fatal(err)
+	}
+
+	value, result, err := readThrough(cc, "detail", "catalogue:detail:1", true, countingLoad(&calls, Catalogue{ID: 1, Name: "second"}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result != CacheBypass || value.Name != "second" {
+		t.Errorf("expected the database to be read, got %s %+v", result, value)
+	}
+
+	//	Bypassing refreshes the entry.
+	value, result, _ = readThrough(cc, "detail", "catalogue:detail:1", false, countingLoad(&calls, Catalogue{ID: 1, Name: "third"}))
+	if result != CacheHit || value.Name != "second" {
+		t.Errorf("expected the refreshed entry, got %s %+v", result, value)
+	}
+
+	var nilCache *CatalogueCache
+	if _, result, _ := readThrough(nilCache, "detail", "catalogue:detail:1", false, countingLoad(&calls, Catalogue{ID: 1})); result != CacheBypass {
+		t.Errorf("expected a nil cache to read the database, got %s", result)
+	}
+}
+
+func TestCatalogueCacheInvalidate(t *testing.T) {
+	cc := newTestCatalogueCache()
+	calls := 0
+	catalogue := Catalogue{ID: 1, BrandID: 2, Tags: []Tag{{ID: 3}}}
+	for _, tag := range []string{CatalogueTag(1), BrandTag(2), CategoryTag(3)} {
+		if _, _, err := readThrough(cc, "detail", "catalogue:detail:1", false, countingLoad(&calls, catalogue)); err != nil {
+			t.Fatal(err)
+		}
+		if err := cc.Invalidate(tag); err != nil {
+			t.Fatal(err)
+		}
+		if _, result, _ := readThrough(cc, "detail", "catalogue:detail:1", false, countingLoad(&calls, catalogue)); result != CacheMiss {
+			t.Errorf("expected invalidating %s to drop the entry, got %s", tag, result)
+		}
+	}
+
+	if err := cc.Invalidate(BrandTag(9)); err != nil {
+		t.Fatal(err)
+	}
+	if _, result, _ := readThrough(cc, "detail", "catalogue:detail:1", false, countingLoad(&calls, catalogue)); result != CacheHit {
+		t.Errorf("expected invalidating another brand to keep the entry, got %s", result)
+	}
+}
+
+func TestReadThroughDropsValueLoadedDuringInvalidation(t *testing.T) {
+	cc := newTestCatalogueCache()
+	load := func() (Catalogue, []string, error) {
+		//	A write commits and invalidates while the old rows are loaded.
+		if err := cc.Invalidate(CatalogueTag(1)); err != nil {
+			t.Fatal(err)
+		}
+		return Catalogue{ID: 1, Name: "stale"}, []string{CatalogueTag(1)}, nil
+	}
+	if _, _, err := readThrough(cc, "detail", "catalogue:detail:1", false, load); err != nil {
+		t.Fatal(err)
+	}
+
+	calls := 0
+	value, result, _ := readThrough(cc, "detail", "catalogue:detail:1", false, countingLoad(&calls, Catalogue{ID: 1, Name: "fresh"}))
+	if result != CacheMiss || value.Name != "fresh" {
+		t.Errorf("expected the stale value not to be cached, got %s %+v", result, value)
+	}
+}
+
+func TestCatalogueQueryNormalize(t *testing.T) {
+	q, err := CatalogueQuery{Limit: 10, SortBy: " price ", Order: "desc", FilterBy: "name", FilterValue: "phone"}.Normalize()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if q.SortBy != "price" || q.Order != "DESC" {
+		t.Errorf("expected the sort to be normalized, got %+v", q)
+	}
+
+	a, _ := CatalogueQuery{Limit: 10, SortBy: "price"}.Normalize()
+	b, _ := CatalogueQuery{Limit: 10}.Normalize()
+	if a.cacheKey() != b.cacheKey() {
+		t.Errorf("expected a sort without order to share the entry, got %q and %q", a.cacheKey(), b.cacheKey())
+	}
+
+	for _, q := range []CatalogueQuery{
+		{SortBy: "price; DROP TABLE catalogues", Order: "ASC"},
+		{SortBy: "price", Order: "ASC, (SELECT 1)"},
+		{FilterBy: "1=1 OR name", FilterValue: "x"},
+		{SortBy: "secret_key"},
+	} {
+		if _, err := q.Normalize(); !errors.Is(err, ErrInvalidCatalogueQuery) {
+			t.Errorf("%+v: expected ErrInvalidCatalogueQuery, got %v", q, err)
+		}
+	}
+}
@@ -0,0 +1,131 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"be20250107/internal/modules/mq"
+)
+
+// Event is a domain event to publish once the transaction causing it is
+// committed. Publishing before the commit could announce a write that is then
+// rolled back.
+type Event struct {
+	Topic string
+	Msg   any
+}
+
+// PublishEvents publishes the events in order and returns the errors of those
+// that failed, so that one failure does not drop the others.
+func PublishEvents(publisher mq.Publisher, events ...Event) error {
+	var errs []error
+	for _, e := range events {
+		if err := mq.PublishMessage(publisher, e.Topic, e.Msg); err != nil {
+			errs = append(errs, fmt.Errorf("[PublishEvents][%s]%w", e.Topic, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// CreatedEvents returns the events of a committed Insert: the creation
+// itself, then the publication if the catalogue was created published.
+func (p *Catalogue) CreatedEvents() []Event {
+	now := time.Now().Unix()
+	events := []Event{{Topic: mq.CatalogueCreatedTopic, Msg: mq.CatalogueCreatedMsg{
+		Version:     mq.CatalogueEventVersion,
+		CatalogueID: p.ID,
+		Name:        p.Name,
+		BrandID:     p.BrandID,
+		CategoryID:  p.CategoryID,
+		Price:       p.Price,
+		CreatedBy:   p.CreatedBy,
+		OccurredAt:  now,
+	}}}
+	if p.PublishedAt != nil {
+		events = append(events, p.publishedEvent(now))
+	}
+	return events
+}
+
+// UpdatedEvents returns the events of a committed Update: the update itself,
+// then the price change and the publication it made, if any.
+func (p *Catalogue) UpdatedEvents() []Event {
+	now := time.Now().Unix()
+	events := []Event{{Topic: mq.CatalogueUpdatedTopic, Msg: mq.CatalogueUpdatedMsg{
+		Version:     mq.CatalogueEventVersion,
+		CatalogueID: p.ID,
+		Name:        p.Name,
+		BrandID:     p.BrandID,
+		Price:       p.Price,
+		PublishedAt: unixTime(p.PublishedAt),
+		OccurredAt:  now,
+	}}}
+	if p.previous == nil {
+		return events
+	}
+	if p.previous.Price != p.Price {
+		events = append(events, Event{Topic: mq.CataloguePriceChangedTopic, Msg: mq.CataloguePriceChangedMsg{
+			Version:     mq.CatalogueEventVersion,
+			CatalogueID: p.ID,
+			OldPrice:    p.previous.Price,
+			NewPrice:    p.Price,
+			OccurredAt:  now,
+		}})
+	}
+	if p.previous.PublishedAt == nil && p.PublishedAt != nil {
+		events = append(events, p.publishedEvent(now))
+	}
+	return events
+}
+
+func (p *Catalogue) publishedEvent(now int64) Event {
+	return Event{Topic: mq.CataloguePublishedTopic, Msg: mq.CataloguePublishedMsg{
+		Version:     mq.CatalogueEventVersion,
+		CatalogueID: p.ID,
+		PublishedAt: p.PublishedAt.Unix(),
+		OccurredAt:  now,
+	}}
+}
+
+// DeletedEvents returns the events of a committed Delete.
+func (p *Catalogue) DeletedEvents() []Event {
+	return []Event{{Topic: mq.CatalogueDeletedTopic, Msg: mq.CatalogueDeletedMsg{
+		Version:     mq.CatalogueEventVersion,
+		CatalogueID: p.ID,
+		OccurredAt:  time.Now().Unix(),
+	}}}
+}
+
+// UpdatedEvent returns the event of a committed Update, or of a committed
+// Delete when deleted is set.
+func (b *Brand) UpdatedEvent(deleted bool) Event {
+	return Event{Topic: mq.BrandUpdatedTopic, Msg: mq.BrandUpdatedMsg{
+		Version:    mq.CatalogueEventVersion,
+		BrandID:    b.ID,
+		Name:       b.Name,
+		Deleted:    deleted,
+		OccurredAt: time.Now().Unix(),
+	}}
+}
+
+// UpdatedEvent returns the event of a committed Update, or of a committed
+// Delete when deleted is set.
+func (t *Tag) UpdatedEvent(deleted bool) Event {
+	return Event{Topic: mq.CategoryUpdatedTopic, Msg: mq.CategoryUpdatedMsg{
+		Version:     mq.CatalogueEventVersion,
+		CategoryID:  t.ID,
+		Name:        t.Name,
+		Description: t.Description,
+		Deleted:     deleted,
+		OccurredAt:  time.Now().Unix(),
+	}}
+}
+
+func unixTime(t *time.Time) *int64 {
+	if t == nil {
+		return nil
+	}
+	unix := t.Unix()
+	return &unix
+}
@@ -0,0 +1,106 @@
+package models
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"be20250107/internal/modules/mq"
+)
+
+func eventTopics(events []Event) []string {
+	topics := make([]string, len(events))
+	for i, e := range events {
+		topics[i] = e.Topic
+	}
+	return topics
+}
+
+func equalTopics(got []string, expected ...string) bool {
+	if len(got) != len(expected) {
+		return false
+	}
+	for i := range got {
+		if got[i] != expected[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestCreatedEvents(t *testing.T) {
+	p := Catalogue{ID: 1, Name: "phone", Price: 100}
+	if topics := eventTopics(p.CreatedEvents()); !equalTopics(topics, mq.CatalogueCreatedTopic) {
+		t.Errorf("expected the creation only, got %v", topics)
+	}
+
+	publishedAt := time.Unix(1700000000, 0)
+	p.PublishedAt = &publishedAt
+	events := p.CreatedEvents()
+	if topics := eventTopics(events); !equalTopics(topics, mq.CatalogueCreatedTopic, mq.CataloguePublishedTopic) {
+		t.Fatalf("expected the creation and the publication, got %v", topics)
+	}
+	if msg := events[1].Msg.(mq.CataloguePublishedMsg); msg.CatalogueID != 1 || msg.PublishedAt != publishedAt.Unix() {
+		t.Errorf("expected the publication of the catalogue, got %+v", msg)
+	}
+}
+
+func TestUpdatedEvents(t *testing.T) {
+	earlier := time.Unix(1600000000, 0)
+	now := time.Unix(1700000000, 0)
+
+	tests := []struct {
+		name        string
+		previous    *catalogueState
+		price       float64
+		publishedAt *time.Time
+		expected    []string
+	}{
+		{"unknown previous values", nil, 200, &now, []string{mq.CatalogueUpdatedTopic}},
+		{"nothing changed", &catalogueState{Price: 100}, 100, nil, []string{mq.CatalogueUpdatedTopic}},
+		{"price changed", &catalogueState{Price: 100}, 200, nil, []string{mq.CatalogueUpdatedTopic, mq.CataloguePriceChangedTopic}},
+		{"published", &catalogueState{Price: 100}, 100, &now, []string{mq.CatalogueUpdatedTopic, mq.CataloguePublishedTopic}},
+		{"already published", &catalogueState{Price: 100, PublishedAt: &earlier}, 100, &now, []string{mq.CatalogueUpdatedTopic}},
+		{"price changed and published", &catalogueState{Price: 100}, 200, &now, []string{mq.CatalogueUpdatedTopic, mq.CataloguePriceChangedTopic, mq.CataloguePublishedTopic}},
+	}
+	for _, test := range tests {
+		p := Catalogue{ID: 1, Price: test.price, PublishedAt: test.publishedAt, previous: test.previous}
+		events := p.UpdatedEvents()
+		if topics := eventTopics(events); !equalTopics(topics, test.expected...) {
+			t.Errorf("%s: expected %v, got %v", test.name, test.expected, topics)
+			continue
+		}
+		for _, e := range events {
+			if msg, ok := e.Msg.(mq.CataloguePriceChangedMsg); ok && (msg.OldPrice != 100 || msg.NewPrice != 200) {
+				t.Errorf("%s: expected the price to change from 100 to 200, got %+v", test.name, msg)
+			}
+		}
+	}
+}
+
+type recordingPublisher struct {
+	topics []string
+	fail   map[string]bool
+}
+
+func (p *recordingPublisher) Publish(topic string, body []byte) error {
+	if p.fail[topic] {
+		return errors.New("queue is full")
+	}
+	p.topics = append(p.topics, topic)
+	return nil
+}
+
+func TestPublishEventsContinuesAfterFailure(t *testing.T) {
+	publisher := &recordingPublisher{fail: map[string]bool{mq.CatalogueUpdatedTopic: true}}
+	publishedAt := time.Unix(1700000000, 0)
+	p := Catalogue{ID: 1, Price: 200, PublishedAt: &publishedAt, previous: &catalogueState{Price: 100}}
+
+	err := PublishEvents(publisher, p.UpdatedEvents()...)
+	if err == nil {
+		t.Error("expected the failure to be returned")
+	}
+	if !equalTopics(publisher.topics, mq.CataloguePriceChangedTopic, mq.CataloguePublishedTopic) {
+		t.Errorf("expected the other events to be published, got %v", publisher.topics)
+	}
+}
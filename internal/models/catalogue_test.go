@@ -0,0 +1,108 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// queryRecordingDB is a database/sql connector recording the queries and their
+// arguments. Counts are zero and other queries return no rows.
+type queryRecordingDB struct {
+	queries []string
+	args    [][]driver.Value
+}
+
+func (d *queryRecordingDB) Connect(context.Context) (driver.Conn, error) {
+	return queryRecordingConn{d}, nil
+}
+func (d *queryRecordingDB) Driver() driver.Driver { return nil }
+
+type queryRecordingConn struct{ db *queryRecordingDB }
+
+func (c queryRecordingConn) Prepare(query string) (driver.Stmt, error) {
+	return queryRecordingStmt{c.db, query}, nil
+}
+func (c queryRecordingConn) Close() error              { return nil }
+func (c queryRecordingConn) Begin() (driver.Tx, error) { return nil, errors.New("not supported") }
+
+type queryRecordingStmt struct {
+	db    *queryRecordingDB
+	query string
+}
+
+func (s queryRecordingStmt) Close() error  { return nil }
+func (s queryRecordingStmt) NumInput() int { return -1 }
+func (s queryRecordingStmt) Exec(args []driver.Value) (driver.Result, error) {
+	return nil, errors.New("not supported")
+}
+func (s queryRecordingStmt) Query(args []driver.Value) (driver.Rows, error) {
+	s.db.queries = append(s.db.queries, s.query)
+	s.db.args = append(s.db.args, args)
+	if strings.Contains(s.query, "COUNT(*)") {
+		return &countRows{}, nil
+	}
+	return &countRows{done: true}, nil
+}
+
+type countRows struct{ done bool }
+
+func (r *countRows) Columns() []string { return []string{"count"} }
+func (r *countRows) Close() error      { return nil }
+func (r *countRows) Next(dest []driver.Value) error {
+	if r.done {
+		return io.EOF
+	}
+	r.done = true
+	dest[0] = int64(0)
+	return nil
+}
+
+func TestGetCataloguesBindsFilterValue(t *testing.T) {
+	db := &queryRecordingDB{}
+	_, _, err := GetCatalogues(sqlx.NewDb(sql.OpenDB(db), "mysql"), CatalogueQuery{
+		Limit:       10,
+		SortBy:      "brand_name",
+		Order:       "desc",
+		FilterBy:    "name",
+		FilterValue: "50%' OR '1'='1",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(db.queries) != 2 {
+		t.Fatalf("expected the count and the listing, got %v", db.queries)
+	}
+	for i, q := range db.queries {
+		if strings.Contains(q, "OR '1'='1") {
+			t.Errorf("expected the filter value not to be in the query, got %s", q)
+		}
+		if !strings.Contains(q, "catalogues.name LIKE ?") {
+			t.Errorf("expected the filter column to be bound, got %s", q)
+		}
+		if len(db.args[i]) != 1 || db.args[i][0] != `%50\%' OR '1'='1%` {
+			t.Errorf("expected the escaped filter value as argument, got %v", db.args[i])
+		}
+	}
+	if !strings.Contains(db.queries[1], "ORDER BY brands.name DESC") {
+		t.Errorf("expected the sort column to be mapped, got %s", db.queries[1])
+	}
+}
+
+func TestGetCataloguesRejectsUnknownColumns(t *testing.T) {
+	db := &queryRecordingDB{}
+	_, _, err := GetCatalogues(sqlx.NewDb(sql.OpenDB(db), "mysql"), CatalogueQuery{SortBy: "(SELECT 1)", Order: "ASC"})
+	if !errors.Is(err, ErrInvalidCatalogueQuery) {
+		t.Errorf("expected ErrInvalidCatalogueQuery, got %v", err)
+	}
+	if len(db.queries) != 0 {
+		t.Errorf("expected no query to run, got %v", db.queries)
+	}
+}
@@ -1,14 +1,14 @@
 package models
 
 import (
-	"context"
 	"fmt"
-	"log"
+	"strings"
 	"time"
 
+	"be20250107/internal/modules/cache"
 	"be20250107/utils/random"
+	stringsutil "be20250107/utils/strings"
 
-	"github.com/go-redis/redis/v8"
 	"gopkg.in/guregu/null.v4"
 )
 
@@ -57,6 +57,42 @@ var AvailableContactCombinationLists = []string{
 	ContactMediumHome + "-" + ContactTypePrimary,
 	ContactMediumLineID + "-" + ContactTypePrimary,
 }
+
+// NormalizeContactMedium maps a medium name sent by clients, in any case, to
+// one of the ContactMedium constants. "phone" and "mobile" are accepted as
+// aliases of ContactMediumCatalogue.
+func NormalizeContactMedium(medium string) (string, bool) {
+	switch strings.ToLower(strings.TrimSpace(medium)) {
+	case "email":
+		return ContactMediumEmail, true
+	case "catalogue", "phone", "mobile":
+		return ContactMediumCatalogue, true
+	case "landline":
+		return ContactMediumHome, true
+	case "lineid", "line_id":
+		return ContactMediumLineID, true
+	}
+	return "", false
+}
+
+// NormalizeContactValue validates the value for the contact medium and returns
+// it in the form it is stored in. Phone numbers are converted to E.164.
+func NormalizeContactValue(medium string, value string) (string, bool) {
+	switch medium {
+	case ContactMediumCatalogue:
+		return stringsutil.NormalizeTaiwanMobile(value)
+	case ContactMediumHome:
+		return stringsutil.NormalizeTaiwanLandline(value)
+	case ContactMediumEmail:
+		email := strings.ToLower(strings.TrimSpace(value))
+		return email, stringsutil.ValidateEmail(email)
+	case ContactMediumLineID:
+		lineID := strings.TrimSpace(value)
+		return lineID, stringsutil.ValidateLineID(lineID)
+	}
+	return "", false
+}
+
 var TransactionRequestStatuses = []string{TransactionRequestPending, TransactionRequestApproved, TransactionRequestRejected}
 
 type BalanceLogFilter struct {
@@ -93,22 +129,17 @@ type ContactInformation struct {
 const (
 	AccountTypeAdmin  = "admin"
 	AccountTypeSystem = "system"
+	AccountTypeAPIKey = "api_key"
+	AccountTypeUser   = "user"
 )
 
-func GenerateCode(r *redis.Client, codePrefix string) (string, error) {
+// GenerateCode returns the next code of the prefix, such as PREFIX-202501-00000042.
+// Codes are numbered per month.
+func GenerateCode(c cache.Cache, codePrefix string) (string, error) {
 	keyCounter := fmt.Sprintf("%v-%v", codePrefix, time.Now().Format("200601"))
-	latestCounter := r.Incr(context.Background(), keyCounter)
-	if latestCounter.Err() != nil {
-		return "", fmt.Errorf("[GenerateCode][Incr]%w", latestCounter.Err())
-	}
-
-	_, err := r.Expire(context.Background(), keyCounter, time.Hour*24*32).Result()
-	if err != nil {
-		log.Printf("[GenerateCode] fail to set expiry on key %v with err:%v", keyCounter, err)
-	}
-	val, err := latestCounter.Result()
+	val, err := c.Increment(keyCounter, &cache.Options{Expiration: time.Hour * 24 * 32})
 	if err != nil {
-		return "", fmt.Errorf("[GenerateCode][Result]%w", err)
+		return "", fmt.Errorf("[GenerateCode][Increment]%w", err)
 	}
 
 	code := fmt.Sprintf("%v-%08v", keyCounter, val)
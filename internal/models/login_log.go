@@ -0,0 +1,197 @@
+package models
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"be20250107/utils/database"
+
+	"gopkg.in/guregu/null.v4"
+)
+
+const (
+	LoginFailureInvalidCredential = "invalid_credential"
+	LoginFailureDeactivated       = "deactivated"
+	LoginFailureLockedOut         = "locked_out"
+)
+
+// LoginLog is a successful or failed login of an admin, system or user.
+// AccountID is empty for failures where the account is not known. Credential
+// is masked with MaskCredential so that the logs do not hold contacts in full.
+type LoginLog struct {
+	Model
+	AccountType    string      `json:"account_type" db:"account_type"`
+	AccountID      null.String `json:"account_id" db:"account_id"`
+	Credential     null.String `json:"credential" db:"credential"`
+	AuthProvider   null.String `json:"auth_provider" db:"auth_provider"`
+	Successful     bool        `json:"successful" db:"successful"`
+	FailureReason  null.String `json:"failure_reason" db:"failure_reason"`
+	IPAddress      null.String `json:"ip_address" db:"ip_address"`
+	UserAgent      null.String `json:"user_agent" db:"user_agent"`
+	ClientID       null.String `json:"client_id" db:"client_id"`
+	DeviceID       null.String `json:"device_id" db:"device_id"`
+	Fingerprint    null.String `json:"fingerprint" db:"fingerprint"`
+	AccessTokenID  null.String `json:"access_token_id" db:"access_token_id"`
+	AdditionalInfo null.String `json:"-" db:"additional_info"`
+}
+
+func (l *LoginLog) Insert(db database.Queryer) error {
+	l.BeforeInsert("login_logs")
+
+	q := `
+		INSERT INTO login_logs
+		(id, account_type, account_id, credential, auth_provider, successful, failure_reason, ip_address, user_agent,
+			client_id, device_id, fingerprint, access_token_id, additional_info, created_at, updated_at)
+		VALUES
+		(:id, :account_type, :account_id, :credential, :auth_provider, :successful, :failure_reason, :ip_address, :user_agent,
+			:client_id, :device_id, :fingerprint, :access_token_id, :additional_info, :created_at, :updated_at)
+	`
+	_, err := db.NamedExec(q, l)
+	if err != nil {
+		return fmt.Errorf("[l.Insert][NamedExec]%w", err)
+	}
+	return nil
+}
+
+// MaskCredential hides most of an email address or phone number: only the
+// first character of an email address and its domain, or the first four and
+// last three characters of anything else, are kept. Short values are hidden
+// entirely.
+func MaskCredential(credential string) string {
+	if credential == "" {
+		return ""
+	}
+	if at := strings.LastIndex(credential, "@"); at > 0 {
+		return credential[:1] + "***" + credential[at:]
+	}
+	if len(credential) <= 7 {
+		return "***"
+	}
+	return credential[:4] + strings.Repeat("*", len(credential)-7) + credential[len(credential)-3:]
+}
+
+// SetAdditionalInfo stores info as the JSON additional_info column.
+func (l *LoginLog) SetAdditionalInfo(info map[string]any) error {
+	if len(info) == 0 {
+		l.AdditionalInfo = null.String{}
+		return nil
+	}
+	b, err := json.Marshal(info)
+	if err != nil {
+		return fmt.Errorf("[l.SetAdditionalInfo][Marshal]%w", err)
+	}
+	l.AdditionalInfo = null.StringFrom(string(b))
+	return nil
+}
+
+func (l LoginLog) MarshalJSON() ([]byte, error) {
+	type loginLog LoginLog
+	var info map[string]any
+	if l.AdditionalInfo.Valid {
+		if err := json.Unmarshal([]byte(l.AdditionalInfo.String), &info); err != nil {
+			return nil, err
+		}
+	}
+	return json.Marshal(struct {
+		loginLog
+		AdditionalInfo map[string]any `json:"additional_info"`
+	}{
+		loginLog:       loginLog(l),
+		AdditionalInfo: info,
+	})
+}
+
+type LoginLogFilter struct {
+	AccountType string
+	AccountID   string
+	IPAddress   string
+	Successful  *bool
+	From        *time.Time
+	To          *time.Time
+}
+
+// GetLoginLogs returns the logs matching the filter, newest first, together
+// with the total number of matching logs.
+func GetLoginLogs(db database.Queryer, filter LoginLogFilter, limit int, offset int) ([]LoginLog, int, error) {
+	conditions := []string{"1 = 1"}
+	args := []any{}
+	if filter.AccountType != "" {
+		conditions = append(conditions, "account_type = ?")
+		args = append(args, filter.AccountType)
+	}
+	if filter.AccountID != "" {
+		conditions = append(conditions, "account_id = ?")
+		args = append(args, filter.AccountID)
+	}
+	if filter.IPAddress != "" {
+		conditions = append(conditions, "ip_address = ?")
+		args = append(args, filter.IPAddress)
+	}
+	if filter.Successful != nil {
+		conditions = append(conditions, "successful = ?")
+		args = append(args, *filter.Successful)
+	}
+	if filter.From != nil {
+		conditions = append(conditions, "created_at >= ?")
+		args = append(args, filter.From.Unix())
+	}
+	if filter.To != nil {
+		conditions = append(conditions, "created_at < ?")
+		args = append(args, filter.To.Unix())
+	}
+	where := strings.Join(conditions, " AND ")
+
+	var total int
+	err := db.Get(&total, "SELECT COUNT(*) FROM login_logs WHERE "+where, args...)
+	if err != nil {
+		return nil, 0, fmt.Errorf("[GetLoginLogs][Count]%w", err)
+	}
+
+	logs := []LoginLog{}
+	q := "SELECT * FROM login_logs WHERE " + where + " ORDER BY created_at DESC, id DESC LIMIT ? OFFSET ?"
+	err = db.Select(&logs, q, append(args, limit, offset)...)
+	if err != nil {
+		return nil, 0, fmt.Errorf("[GetLoginLogs][Select]%w", err)
+	}
+	return logs, total, nil
+}
+
+// HasLoginFingerprint reports whether the account has logged in successfully
+// from the fingerprint before.
+func HasLoginFingerprint(db database.Queryer, accountType string, accountID string, fingerprint string) (bool, error) {
+	var count int
+	q := `
+		SELECT COUNT(*) FROM login_logs
+		WHERE account_type = ? AND account_id = ? AND fingerprint = ? AND successful = 1
+	`
+	err := db.Get(&count, q, accountType, accountID, fingerprint)
+	if err != nil {
+		return false, fmt.Errorf("[HasLoginFingerprint][Get]%w", err)
+	}
+	return count > 0, nil
+}
+
+// CountSuccessfulLogins returns the number of successful logins of the account.
+func CountSuccessfulLogins(db database.Queryer, accountType string, accountID string) (int, error) {
+	var count int
+	q := "SELECT COUNT(*) FROM login_logs WHERE account_type = ? AND account_id = ? AND successful = 1"
+	err := db.Get(&count, q, accountType, accountID)
+	if err != nil {
+		return 0, fmt.Errorf("[CountSuccessfulLogins][Get]%w", err)
+	}
+	return count, nil
+}
+
+// DeleteLoginLogs deletes login logs created before the given time, batch
+// rows at a time. It stops between batches once ctx is done.
+func DeleteLoginLogs(ctx context.Context, db database.Queryer, before time.Time, batch int) (int64, error) {
+	q := "DELETE FROM login_logs WHERE created_at < ? LIMIT ?"
+	n, err := deleteInBatches(ctx, db, q, before, batch)
+	if err != nil {
+		return n, fmt.Errorf("[DeleteLoginLogs][Exec]%w", err)
+	}
+	return n, nil
+}
@@ -0,0 +1,19 @@
+package models
+
+import "testing"
+
+func TestMaskCredential(t *testing.T) {
+	tests := map[string]string{
+		"":                     "",
+		"user@example.com":     "u***@example.com",
+		"a@example.com":        "a***@example.com",
+		"+886912345678":        "+886******678",
+		"1234567":              "***",
+		"provider-account-001": "prov*************001",
+	}
+	for credential, want := range tests {
+		if got := MaskCredential(credential); got != want {
+			t.Errorf("MaskCredential(%q): expected %q, got %q", credential, want, got)
+		}
+	}
+}
@@ -12,6 +12,9 @@ import (
 
 const (
 	PermissionAdminIndex = "admin::index"
+	// PermissionRoleTwoFactor allows changing whether a role requires
+	// two-factor authentication.
+	PermissionRoleTwoFactor = "role::two_factor"
 )
 
 type Authorities struct {
@@ -130,9 +133,11 @@ func GetAllPermissions(db database.Queryer) ([]Permission, bool, error) {
 }
 
 type Role struct {
-	Model       `mapstructure:",squash"`
-	Name        string       `db:"name" json:"name" mapstructure:"name"`
-	Permissions []Permission `json:"permissions,omitempty" mapstructure:"-"`
+	Model `mapstructure:",squash"`
+	Name  string `db:"name" json:"name" mapstructure:"name"`
+	// RequireTwoFactor makes TOTP mandatory for admins holding the role.
+	RequireTwoFactor bool         `db:"require_two_factor" json:"require_two_factor" mapstructure:"require_two_factor"`
+	Permissions      []Permission `json:"permissions,omitempty" mapstructure:"-"`
 }
 
 func (r *Role) Insert(db database.Queryer) error {
@@ -140,9 +145,9 @@ func (r *Role) Insert(db database.Queryer) error {
 
 	q := `
 		INSERT INTO roles
-		(id, name, created_at, updated_at)
+		(id, name, require_two_factor, created_at, updated_at)
 		VALUES
-		(:id, :name, :created_at, :updated_at)
+		(:id, :name, :require_two_factor, :created_at, :updated_at)
 	`
 	_, err := db.NamedExec(q, r)
 	if err != nil {
@@ -151,11 +156,12 @@ func (r *Role) Insert(db database.Queryer) error {
 	return nil
 }
 
-func (r *Role) Update(db database.Queryer) error {
+func (r *Role) Update(db database.TxQueryer) error {
 	r.BeforeUpdate()
 	q := `
 		UPDATE roles SET
 			name = :name,
+			require_two_factor = :require_two_factor,
 			updated_at = :updated_at
 		WHERE id = :id
 	`
@@ -189,6 +195,17 @@ func GetRoleByID(db database.Queryer, id string) (*Role, bool, error) {
 	return &role, true, nil
 }
 
+func GetRoleByName(db database.Queryer, name string) (*Role, bool, error) {
+	var role Role
+	err := db.Get(&role, "SELECT * FROM roles WHERE name = ?", name)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, false, nil
+	} else if err != nil {
+		return nil, false, fmt.Errorf("[GetRoleByName][Get]%w", err)
+	}
+	return &role, true, nil
+}
+
 func (r *Role) LoadPermissions(db database.Queryer) error {
 	r.Permissions = nil
 
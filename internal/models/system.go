@@ -2,11 +2,16 @@ package models
 
 import (
 	"crypto/sha256"
+	"crypto/subtle"
+	"database/sql"
 	"encoding/hex"
+	"errors"
 	"fmt"
+	"strings"
 	"time"
 
 	"be20250107/internal/constants"
+	"be20250107/internal/modules/secretbox"
 	"be20250107/utils/database"
 
 	"be20250107/utils/random"
@@ -17,12 +22,37 @@ import (
 	"github.com/oklog/ulid/v2"
 )
 
+const (
+	ScopeCatalogueRead = "catalogue:read"
+	ScopePricesRead    = "prices:read"
+)
+
+// AvailableScopes lists the scopes that can be granted to systems.
+var AvailableScopes = []string{
+	ScopeCatalogueRead,
+	ScopePricesRead,
+}
+
+// UserScopes lists the scopes every user token is granted without carrying
+// them, which keeps the public reads open to users.
+var UserScopes = []string{
+	ScopeCatalogueRead,
+	ScopePricesRead,
+}
+
 type System struct {
 	Model
-	Name           string      `json:"name"`
-	URL            string      `json:"url"`
-	SecretKey      null.String `json:"secret" db:"secret_key"`
+	Name           string      `json:"name" db:"name"`
+	URL            string      `json:"url" db:"url"`
+	SecretKey      null.String `json:"-" db:"secret_key"`
 	PlainSecretKey null.String `json:"-" db:"-"`
+	// SigningSecret is the plain secret encrypted with the application
+	// encryption key, from which the HMAC signing key is derived. It is null
+	// when no encryption key was configured, which disables signed requests.
+	SigningSecret null.String `json:"-" db:"signing_secret"`
+	// Scopes is the space separated list of scopes the system may request.
+	Scopes    string    `json:"scopes" db:"scopes"`
+	RevokedAt null.Time `json:"revoked_at" db:"revoked_at"`
 }
 
 func (s *System) Insert(db database.Queryer) error {
@@ -34,15 +64,12 @@ func (s *System) Insert(db database.Queryer) error {
 	s.Model.UpdatedAt = now
 
 	if s.PlainSecretKey.Valid {
-		h := sha256.New()
-		h.Write([]byte(s.PlainSecretKey.ValueOrZero()))
-		hashed := hex.EncodeToString(h.Sum(nil))
-		s.SecretKey = null.StringFrom(hashed)
+		s.SecretKey = null.StringFrom(hashSecretKey(s.PlainSecretKey.ValueOrZero()))
 		s.PlainSecretKey = null.String{}
 	}
 
-	q := "INSERT INTO systems (id, name, url, secret_key, created_at, updated_at) " +
-		"VALUES (:id, :name, :url, :secret_key, :created_at, :updated_at)"
+	q := "INSERT INTO systems (id, name, url, secret_key, signing_secret, scopes, revoked_at, created_at, updated_at) " +
+		"VALUES (:id, :name, :url, :secret_key, :signing_secret, :scopes, :revoked_at, :created_at, :updated_at)"
 	_, err := db.NamedExec(q, s)
 	if err != nil {
 		return fmt.Errorf("[s.Insert][NamedExec]%w", err)
@@ -54,10 +81,7 @@ func (s *System) Update(db database.Queryer) error {
 	s.UpdatedAt = time.Now().Unix()
 
 	if s.PlainSecretKey.Valid {
-		h := sha256.New()
-		h.Write([]byte(s.PlainSecretKey.ValueOrZero()))
-		hashed := hex.EncodeToString(h.Sum(nil))
-		s.SecretKey = null.StringFrom(hashed)
+		s.SecretKey = null.StringFrom(hashSecretKey(s.PlainSecretKey.ValueOrZero()))
 		s.PlainSecretKey = null.String{}
 	}
 
@@ -66,6 +90,9 @@ func (s *System) Update(db database.Queryer) error {
 			name = :name,
 			url = :url,
 			secret_key = :secret_key,
+			signing_secret = :signing_secret,
+			scopes = :scopes,
+			revoked_at = :revoked_at,
 			created_at = :created_at,
 			updated_at = :updated_at
 		WHERE id = :id
@@ -77,6 +104,83 @@ func (s *System) Update(db database.Queryer) error {
 	return nil
 }
 
+// SealSigningSecret encrypts the plain secret into SigningSecret. It must be
+// called before Insert or Update, which clear the plain secret. Without a box
+// SigningSecret is cleared, so the system cannot sign requests.
+func (s *System) SealSigningSecret(box *secretbox.Box) error {
+	if !s.PlainSecretKey.Valid {
+		return nil
+	}
+	if box == nil {
+		s.SigningSecret = null.String{}
+		return nil
+	}
+	sealed, err := box.Seal(s.PlainSecretKey.String)
+	if err != nil {
+		return fmt.Errorf("[s.SealSigningSecret][Seal]%w", err)
+	}
+	s.SigningSecret = null.StringFrom(sealed)
+	return nil
+}
+
+func hashSecretKey(secret string) string {
+	h := sha256.New()
+	h.Write([]byte(secret))
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// VerifySecretKey reports whether the plain secret matches the stored hash.
+// The hashes are compared in constant time.
+func (s *System) VerifySecretKey(secret string) bool {
+	hashed := hashSecretKey(secret)
+	return subtle.ConstantTimeCompare([]byte(hashed), []byte(s.SecretKey.ValueOrZero())) == 1 && s.SecretKey.Valid
+}
+
+// ScopeList returns the scopes granted to the system.
+func (s *System) ScopeList() []string {
+	return strings.Fields(s.Scopes)
+}
+
+// GrantScopes returns the requested scopes the system is allowed to use, or
+// every granted scope if none is requested.
+func (s *System) GrantScopes(requested []string) []string {
+	allowed := s.ScopeList()
+	if len(requested) == 0 {
+		return allowed
+	}
+
+	var granted []string
+	for _, scope := range requested {
+		for _, a := range allowed {
+			if scope == a {
+				granted = append(granted, scope)
+				break
+			}
+		}
+	}
+	return granted
+}
+
+func GetSystemByID(db database.Queryer, id string) (*System, bool, error) {
+	var system System
+	err := db.Get(&system, "SELECT * FROM systems WHERE id = ?", id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, false, nil
+	} else if err != nil {
+		return nil, false, fmt.Errorf("[GetSystemByID][Get]%w", err)
+	}
+	return &system, true, nil
+}
+
+func GetSystems(db database.Queryer) ([]System, error) {
+	systems := []System{}
+	err := db.Select(&systems, "SELECT * FROM systems ORDER BY created_at DESC")
+	if err != nil {
+		return nil, fmt.Errorf("[GetSystems][Select]%w", err)
+	}
+	return systems, nil
+}
+
 func GenerateSecretKey() string {
 	return random.GenerateString(
 		64,
@@ -111,7 +215,9 @@ type SystemAccessToken struct {
 }
 
 func (sat *SystemAccessToken) Insert(db database.Queryer) error {
-	sat.BeforeInsert("system_access_tokens")
+	now := time.Now().Unix()
+	sat.Model.CreatedAt = now
+	sat.Model.UpdatedAt = now
 
 	q := "INSERT INTO system_access_tokens (id, system_id, expired_at, revoked_at, created_at, updated_at) " +
 		"VALUES (:id, :system_id, :expired_at, :revoked_at, :created_at, :updated_at)"
@@ -139,6 +245,25 @@ func (sat *SystemAccessToken) Update(db database.Queryer) error {
 	return nil
 }
 
+// GetActiveSystemAccessTokens returns the tokens of a system that are neither
+// revoked nor expired.
+func GetActiveSystemAccessTokens(db database.Queryer, systemID string) ([]SystemAccessToken, error) {
+	q := `
+		SELECT * FROM system_access_tokens
+		WHERE
+			system_id = ? AND
+			revoked_at IS NULL AND
+			expired_at > NOW()
+	`
+
+	var tokens []SystemAccessToken
+	err := db.Select(&tokens, q, systemID)
+	if err != nil {
+		return nil, fmt.Errorf("[GetActiveSystemAccessTokens][Select]%w", err)
+	}
+	return tokens, nil
+}
+
 type UserClient struct {
 	Model
 	Name      string   `json:"name"`
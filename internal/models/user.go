@@ -0,0 +1,192 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"be20250107/internal/constants"
+	"be20250107/utils/database"
+
+	"github.com/lestrrat-go/jwx/v2/jwt"
+	"github.com/oklog/ulid/v2"
+	"gopkg.in/guregu/null.v4"
+)
+
+type User struct {
+	Model
+	Name          string    `json:"name" db:"name"`
+	DeactivatedAt null.Time `json:"deactivated_at" db:"deactivated_at"`
+}
+
+func (u *User) Insert(db database.TxQueryer) error {
+	u.BeforeInsert("users")
+
+	q := `
+		INSERT INTO users
+		(id, name, deactivated_at, created_at, updated_at)
+		VALUES
+		(:id, :name, :deactivated_at, :created_at, :updated_at)
+	`
+	_, err := db.NamedExec(q, u)
+	if err != nil {
+		return fmt.Errorf("[u.Insert][NamedExec]%w", err)
+	}
+	return nil
+}
+
+func (u *User) Update(db database.Queryer) error {
+	u.BeforeUpdate()
+
+	q := `
+		UPDATE users SET
+			name = :name,
+			deactivated_at = :deactivated_at,
+			updated_at = :updated_at
+		WHERE id = :id
+	`
+	_, err := db.NamedExec(q, u)
+	if err != nil {
+		return fmt.Errorf("[u.Update][NamedExec]%w", err)
+	}
+	return nil
+}
+
+func (u *User) IssueAccessToken(exp time.Time) (jwt.Token, error) {
+	id := ulid.Make()
+
+	token, err := jwt.
+		NewBuilder().
+		Issuer(constants.TokenIssuer).
+		Expiration(exp).
+		IssuedAt(time.Now()).
+		JwtID("user_access_tokens:"+id.String()).
+		Subject(u.ID).
+		Claim("act", AccountTypeUser).
+		Build()
+	if err != nil {
+		return nil, fmt.Errorf("[u.IssueAccessToken][Build]%w", err)
+	}
+
+	return token, nil
+}
+
+func GetUserByID(db database.Queryer, id string) (*User, bool, error) {
+	var user User
+	err := db.Get(&user, "SELECT * FROM users WHERE id = ?", id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, false, nil
+	} else if err != nil {
+		return nil, false, fmt.Errorf("[GetUserByID][Get]%w", err)
+	}
+	return &user, true, nil
+}
+
+// UserContact is an email address or E.164 phone number a user can be
+// reached and logged in with. Medium is one of the ContactMedium constants.
+type UserContact struct {
+	Model
+	UserID     string    `json:"user_id" db:"user_id"`
+	Medium     string    `json:"medium" db:"medium"`
+	Value      string    `json:"value" db:"value"`
+	VerifiedAt null.Time `json:"verified_at" db:"verified_at"`
+}
+
+func (uc *UserContact) Insert(db database.TxQueryer) error {
+	uc.BeforeInsert("user_contacts")
+
+	q := `
+		INSERT INTO user_contacts
+		(id, user_id, medium, value, verified_at, created_at, updated_at)
+		VALUES
+		(:id, :user_id, :medium, :value, :verified_at, :created_at, :updated_at)
+	`
+	_, err := db.NamedExec(q, uc)
+	if err != nil {
+		return fmt.Errorf("[uc.Insert][NamedExec]%w", err)
+	}
+	return nil
+}
+
+func (uc *UserContact) Update(db database.Queryer) error {
+	uc.BeforeUpdate()
+
+	q := `
+		UPDATE user_contacts SET
+			value = :value,
+			verified_at = :verified_at,
+			updated_at = :updated_at
+		WHERE id = :id
+	`
+	_, err := db.NamedExec(q, uc)
+	if err != nil {
+		return fmt.Errorf("[uc.Update][NamedExec]%w", err)
+	}
+	return nil
+}
+
+// GetUserContact finds a contact by its medium and normalized value.
+func GetUserContact(db database.Queryer, medium string, value string) (*UserContact, bool, error) {
+	var contact UserContact
+	err := db.Get(&contact, "SELECT * FROM user_contacts WHERE medium = ? AND value = ?", medium, value)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, false, nil
+	} else if err != nil {
+		return nil, false, fmt.Errorf("[GetUserContact][Get]%w", err)
+	}
+	return &contact, true, nil
+}
+
+type UserAccessToken struct {
+	Model
+	UserID    string      `json:"user_id" db:"user_id"`
+	ClientID  null.String `json:"client_id" db:"client_id"`
+	DeviceID  null.String `json:"device_id" db:"device_id"`
+	IPAddress null.String `json:"ip_address" db:"ip_address"`
+	UserAgent null.String `json:"user_agent" db:"user_agent"`
+	RevokedAt null.Time   `json:"revoked_at" db:"revoked_at"`
+	ExpiredAt null.Time   `json:"expired_at" db:"expired_at"`
+}
+
+func (uat *UserAccessToken) Insert(db database.Queryer) error {
+	now := time.Now().Unix()
+	uat.Model.CreatedAt = now
+	uat.Model.UpdatedAt = now
+
+	q := "INSERT INTO user_access_tokens " +
+		"(id,user_id,client_id,device_id,ip_address,user_agent,expired_at,revoked_at,created_at,updated_at) " +
+		"VALUES (:id,:user_id,:client_id,:device_id,:ip_address,:user_agent,:expired_at,:revoked_at,:created_at,:updated_at)"
+	_, err := db.NamedExec(q, uat)
+	if err != nil {
+		return fmt.Errorf("[uat.Insert][NamedExec]%w", err)
+	}
+	return nil
+}
+
+func (uat *UserAccessToken) Update(db database.Queryer) error {
+	uat.Model.UpdatedAt = time.Now().Unix()
+
+	q := "UPDATE user_access_tokens " +
+		"SET user_id = :user_id," +
+		"expired_at = :expired_at," +
+		"revoked_at = :revoked_at," +
+		"updated_at = :updated_at" +
+		" WHERE id = :id;"
+	_, err := db.NamedExec(q, uat)
+	if err != nil {
+		return fmt.Errorf("[uat.Update][NamedExec]%w", err)
+	}
+	return nil
+}
+
+func GetUserAccessTokenByID(db database.Queryer, id string) (*UserAccessToken, bool, error) {
+	var uat UserAccessToken
+	err := db.Get(&uat, "SELECT * FROM user_access_tokens WHERE id = ?", id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, false, nil
+	} else if err != nil {
+		return nil, false, fmt.Errorf("[GetUserAccessTokenByID][Get]%w", err)
+	}
+	return &uat, true, nil
+}
@@ -0,0 +1,110 @@
+package authentication
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"be20250107/internal/constants"
+	"be20250107/internal/models"
+	"be20250107/internal/modules/cache"
+	"be20250107/internal/modules/ratelimiter"
+
+	"github.com/lestrrat-go/jwx/v2/jwt"
+)
+
+const HeaderAPIKey = "X-API-Key"
+
+// apiKeyQuotaExpiration keeps a daily quota counter until its day is over.
+const apiKeyQuotaExpiration = 25 * time.Hour
+
+var (
+	ErrInvalidAPIKey = errors.New("api key is invalid, expired or revoked")
+	ErrIPNotAllowed  = errors.New("api key cannot be used from this address")
+)
+
+// IsAPIKeyRequest reports whether the request is authenticated with an API key.
+func IsAPIKeyRequest(r *http.Request) bool {
+	return r.Header.Get(HeaderAPIKey) != ""
+}
+
+// VerifyAPIKey authenticates a request made with an API key from the given IP
+// address and counts it against the daily quota of the key. Requests over the
+// quota fail with ratelimiter.ErrRateLimited. On success it returns an
+// unsigned token describing the key, so that API key requests can be handled
+// like bearer token requests.
+func (a *Auth) VerifyAPIKey(r *http.Request, ip string) (jwt.Token, error) {
+	plainKey := strings.TrimSpace(r.Header.Get(HeaderAPIKey))
+	if plainKey == "" {
+		return nil, ErrInvalidAPIKey
+	}
+
+	key, exist, err := models.GetAPIKeyByPlainKey(a.db, plainKey)
+	if err != nil {
+		return nil, err
+	}
+	if !exist || !key.IsActive() {
+		return nil, ErrInvalidAPIKey
+	}
+	if !key.AllowsIP(ip) {
+		return nil, ErrIPNotAllowed
+	}
+
+	now := time.Now()
+	admitted, err := a.admitAPIKeyRequest(key, now)
+	if err != nil {
+		return nil, err
+	}
+	if !admitted {
+		if err := key.RecordRejection(a.db, now); err != nil {
+			return nil, err
+		}
+		return nil, ratelimiter.ErrRateLimited
+	}
+	if err := key.RecordUsage(a.db, now); err != nil {
+		return nil, err
+	}
+
+	builder := jwt.NewBuilder().
+		Issuer(constants.TokenIssuer).
+		IssuedAt(now).
+		JwtID(key.ID).
+		Subject(key.ID).
+		Claim("act", models.AccountTypeAPIKey).
+		Claim("scope", key.Scopes)
+	if key.ExpiredAt.Valid {
+		builder = builder.Expiration(key.ExpiredAt.Time)
+	}
+	return builder.Build()
+}
+
+// admitAPIKeyRequest counts the request against the daily quota of the key on
+// an atomic cache counter and reports whether it is within the quota. Requests
+// over the quota are not counted.
+func (a *Auth) admitAPIKeyRequest(key *models.APIKey, day time.Time) (bool, error) {
+	if !key.DailyQuota.Valid {
+		return true, nil
+	}
+
+	k := apiKeyQuotaKey(key.ID, day)
+	count, err := a.cache.IncrementBy(k, 1, &cache.Options{Expiration: apiKeyQuotaExpiration})
+	if err != nil {
+		return false, fmt.Errorf("[Auth.admitAPIKeyRequest][IncrementBy]%w", err)
+	}
+	if count <= key.DailyQuota.Int64 {
+		return true, nil
+	}
+	if _, err := a.cache.IncrementBy(k, -1, nil); err != nil {
+		return false, fmt.Errorf("[Auth.admitAPIKeyRequest][IncrementBy]%w", err)
+	}
+	return false, nil
+}
+
+// apiKeyQuotaKey is the cache counter of the requests admitted for the key on
+// the day. It shares the rate limiter prefix so that tiered caches count it
+// on the shared tier.
+func apiKeyQuotaKey(keyID string, day time.Time) string {
+	return fmt.Sprintf("rl:apikey:%s:%s", keyID, day.Format("2006-01-02"))
+}
@@ -0,0 +1,219 @@
+package authentication
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"be20250107/internal/models"
+	"be20250107/internal/modules/cache"
+	"be20250107/internal/modules/ratelimiter"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// apiKeyDB is a database/sql connector serving a single api_keys row, or none
+// when row is nil, and recording the statements executed.
+type apiKeyDB struct {
+	mu    sync.Mutex
+	row   map[string]driver.Value
+	execs []string
+}
+
+func (d *apiKeyDB) Connect(context.Context) (driver.Conn, error) { return apiKeyConn{d}, nil }
+func (d *apiKeyDB) Driver() driver.Driver                        { return nil }
+
+// countExecs returns how many executed statements contain the fragment.
+func (d *apiKeyDB) countExecs(fragment string) int {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	n := 0
+	for _, q := range d.execs {
+		if strings.Contains(q, fragment) {
+			n++
+		}
+	}
+	return n
+}
+
+type apiKeyConn struct{ db *apiKeyDB }
+
+func (c apiKeyConn) Prepare(query string) (driver.Stmt, error) { return apiKeyStmt{c.db, query}, nil }
+func (c apiKeyConn) Close() error                              { return nil }
+func (c apiKeyConn) Begin() (driver.Tx, error)                 { return nil, errors.New("not supported") }
+
+type apiKeyStmt struct {
+	db    *apiKeyDB
+	query string
+}
+
+func (s apiKeyStmt) Close() error  { return nil }
+func (s apiKeyStmt) NumInput() int { return -1 }
+func (s apiKeyStmt) Exec(args []driver.Value) (driver.Result, error) {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+	s.db.execs = append(s.db.execs, s.query)
+	return driver.RowsAffected(1), nil
+}
+func (s apiKeyStmt) Query(args []driver.Value) (driver.Rows, error) {
+	rows := &apiKeyRows{}
+	if s.db.row != nil {
+		rows.rows = []map[string]driver.Value{s.db.row}
+	}
+	return rows, nil
+}
+
+var apiKeyColumns = []string{
+	"id", "name", "prefix", "key_hash", "scopes", "allowed_ips", "daily_quota", "created_by",
+	"last_used_at", "expired_at", "revoked_at", "created_at", "updated_at",
+}
+
+type apiKeyRows struct {
+	rows []map[string]driver.Value
+}
+
+func (r *apiKeyRows) Columns() []string { return apiKeyColumns }
+func (r *apiKeyRows) Close() error      { return nil }
+func (r *apiKeyRows) Next(dest []driver.Value) error {
+	if len(r.rows) == 0 {
+		return io.EOF
+	}
+	for i, column := range apiKeyColumns {
+		dest[i] = r.rows[0][column]
+	}
+	r.rows = r.rows[1:]
+	return nil
+}
+
+func apiKeyRow(edit func(row map[string]driver.Value)) map[string]driver.Value {
+	row := map[string]driver.Value{
+		"id":         "api_keys:1",
+		"name":       "reseller",
+		"prefix":     "abcdefgh",
+		"key_hash":   "hash",
+		"scopes":     models.ScopeCatalogueRead,
+		"created_at": int64(0),
+		"updated_at": int64(0),
+	}
+	if edit != nil {
+		edit(row)
+	}
+	return row
+}
+
+func newAPIKeyAuth(db *apiKeyDB) *Auth {
+	return (&Auth{}).Init(sqlx.NewDb(sql.OpenDB(db), "mysql"), cache.NewInMemoryCache(nil), nil)
+}
+
+func verifyAPIKey(a *Auth, ip string) error {
+	r := httptest.NewRequest("GET", "/catalogues", nil)
+	r.Header.Set(HeaderAPIKey, "hk_abcdefgh_secret")
+	_, err := a.VerifyAPIKey(r, ip)
+	return err
+}
+
+func TestVerifyAPIKey(t *testing.T) {
+	db := &apiKeyDB{row: apiKeyRow(nil)}
+	r := httptest.NewRequest("GET", "/catalogues", nil)
+	r.Header.Set(HeaderAPIKey, "hk_abcdefgh_secret")
+
+	token, err := newAPIKeyAuth(db).VerifyAPIKey(r, "192.0.2.1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if token.Subject() != "api_keys:1" {
+		t.Errorf("expected the key as subject, got %q", token.Subject())
+	}
+	if act, _ := token.Get("act"); act != models.AccountTypeAPIKey {
+		t.Errorf("expected an API key token, got %v", act)
+	}
+	if scope, _ := token.Get("scope"); scope != models.ScopeCatalogueRead {
+		t.Errorf("expected the scopes of the key, got %v", scope)
+	}
+	if n := db.countExecs("request_count + 1"); n != 1 {
+		t.Errorf("expected the request to be counted once, got %d", n)
+	}
+	if n := db.countExecs("last_used_at"); n != 1 {
+		t.Errorf("expected last_used_at to be updated, got %d", n)
+	}
+}
+
+func TestVerifyAPIKeyRejects(t *testing.T) {
+	tests := []struct {
+		name     string
+		row      map[string]driver.Value
+		ip       string
+		expected error
+	}{
+		{"unknown key", nil, "192.0.2.1", ErrInvalidAPIKey},
+		{"revoked key", apiKeyRow(func(row map[string]driver.Value) {
+			row["revoked_at"] = time.Now().Add(-time.Hour)
+		}), "192.0.2.1", ErrInvalidAPIKey},
+		{"expired key", apiKeyRow(func(row map[string]driver.Value) {
+			row["expired_at"] = time.Now().Add(-time.Hour)
+		}), "192.0.2.1", ErrInvalidAPIKey},
+		{"address not allowed", apiKeyRow(func(row map[string]driver.Value) {
+			row["allowed_ips"] = "198.51.100.7 203.0.113.0/24"
+		}), "192.0.2.1", ErrIPNotAllowed},
+	}
+	for _, test := range tests {
+		db := &apiKeyDB{row: test.row}
+		if err := verifyAPIKey(newAPIKeyAuth(db), test.ip); !errors.Is(err, test.expected) {
+			t.Errorf("%s: expected %v, got %v", test.name, test.expected, err)
+		}
+		if len(db.execs) != 0 {
+			t.Errorf("%s: expected no usage to be recorded, got %v", test.name, db.execs)
+		}
+	}
+}
+
+func TestVerifyAPIKeyAllowsListedAddress(t *testing.T) {
+	db := &apiKeyDB{row: apiKeyRow(func(row map[string]driver.Value) {
+		row["allowed_ips"] = "198.51.100.7 203.0.113.0/24"
+	})}
+	a := newAPIKeyAuth(db)
+	for _, ip := range []string{"198.51.100.7", "203.0.113.20"} {
+		if err := verifyAPIKey(a, ip); err != nil {
+			t.Errorf("%s: expected the key to be accepted, got %v", ip, err)
+		}
+	}
+}
+
+func TestVerifyAPIKeyQuota(t *testing.T) {
+	db := &apiKeyDB{row: apiKeyRow(func(row map[string]driver.Value) {
+		row["daily_quota"] = int64(2)
+	})}
+	a := newAPIKeyAuth(db)
+
+	for i := 0; i < 2; i++ {
+		if err := verifyAPIKey(a, "192.0.2.1"); err != nil {
+			t.Fatalf("request %d: expected the request to be admitted, got %v", i, err)
+		}
+	}
+	for i := 0; i < 2; i++ {
+		if err := verifyAPIKey(a, "192.0.2.1"); !errors.Is(err, ratelimiter.ErrRateLimited) {
+			t.Fatalf("expected the quota to be exhausted, got %v", err)
+		}
+	}
+
+	if n := db.countExecs("request_count + 1"); n != 2 {
+		t.Errorf("expected only the admitted requests to be counted, got %d", n)
+	}
+	if n := db.countExecs("rejected_count + 1"); n != 2 {
+		t.Errorf("expected the rejected requests to be counted once each, got %d", n)
+	}
+	count, err := a.cache.GetInt(apiKeyQuotaKey("api_keys:1", time.Now()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count != 2 {
+		t.Errorf("expected the rejected requests not to use the quota, got %d", count)
+	}
+}
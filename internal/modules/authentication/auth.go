@@ -3,31 +3,56 @@ package authentication
 import (
 	"database/sql"
 	"fmt"
+	"log"
 	"net/http"
+	"slices"
+	"strings"
 	"time"
 
 	"gopkg.in/guregu/null.v4"
 
 	"be20250107/internal/models"
 	mobilebe "be20250107/internal/modules/authentication/mobile_be"
-	"be20250107/internal/modules/authentication/xinchuanauth"
 	"be20250107/internal/modules/cache"
+	"be20250107/internal/modules/keyring"
+	"be20250107/internal/modules/secretbox"
 	"be20250107/utils/database"
 
-	"github.com/lestrrat-go/jwx/v2/jwa"
-	"github.com/lestrrat-go/jwx/v2/jwk"
 	"github.com/lestrrat-go/jwx/v2/jwt"
 	"github.com/pkg/errors"
 )
 
 type Auth struct {
-	XinchuanAuth *xinchuanauth.Client
+	Providers    *IdentityProviders
 	MobileBEAuth *mobilebe.Client
-	cache        cache.Cache
-	db           database.Queryer
-	verifyKey    jwk.RSAPublicKey
+	SyncInterval time.Duration
+	// SignatureSkew is the allowed clock skew of HMAC signed requests.
+	SignatureSkew time.Duration
+	// TokenBinding is one of the TokenBinding modes.
+	TokenBinding string
+	// RevocationResyncInterval is how often revocations are reloaded from the
+	// database in case a broadcast was missed.
+	RevocationResyncInterval time.Duration
+	// PublishRevocation broadcasts a revoked token to the other instances. It
+	// is optional.
+	PublishRevocation RevocationPublisher
+	// SecretBox decrypts the signing secrets of systems, and the TOTP secrets
+	// and identity provider refresh tokens of admins. Signed requests are
+	// rejected and admins are not synced without it.
+	SecretBox *secretbox.Box
+
+	cache   cache.Cache
+	db      database.Queryer
+	keyRing *keyring.KeyRing
 }
 
+// RevocationPublisher broadcasts the ID and expiry of a revoked token.
+type RevocationPublisher func(tokenID string, expiredAt null.Time) error
+
+// revocationTables are the tables of the access tokens that can be revoked.
+// Their IDs are prefixed with the table name.
+var revocationTables = []string{"system_access_tokens", "admin_access_tokens", "user_access_tokens"}
+
 var (
 	ErrInvalidTokenString   = errors.New("failed to parse token string or verify its signature")
 	ErrUnprocessableRequest = errors.New("failed to parse request or verify its signature")
@@ -35,10 +60,10 @@ var (
 	ErrIncorrectAccountType = errors.New("token is created for other account type")
 )
 
-func (a *Auth) Init(db database.Queryer, cache cache.Cache, verifyKey jwk.RSAPublicKey) *Auth {
+func (a *Auth) Init(db database.Queryer, cache cache.Cache, keyRing *keyring.KeyRing) *Auth {
 	a.db = db
 	a.cache = cache
-	a.verifyKey = verifyKey
+	a.keyRing = keyRing
 	return a
 }
 
@@ -51,7 +76,7 @@ func (a *Auth) GetTokenInfo(r *http.Request) (jwt.Token, error) {
 	opts := []jwt.ParseOption{
 		jwt.WithHeaderKey("Authorization"),
 		jwt.WithFormKey("x_access_token"),
-		jwt.WithKey(jwa.RS256, a.verifyKey),
+		a.keyRing.VerifyOption(),
 	}
 
 	t, err := jwt.ParseRequest(r, opts...)
@@ -63,16 +88,21 @@ func (a *Auth) GetTokenInfo(r *http.Request) (jwt.Token, error) {
 }
 
 func (a *Auth) LoadRevocationList() error {
-	tables := []string{"system_access_tokens", "admin_access_tokens"}
-	for _, table := range tables {
+	return a.LoadRevocationsSince(time.Time{})
+}
+
+// LoadRevocationsSince adds the tokens revoked since the time to the cache.
+func (a *Auth) LoadRevocationsSince(since time.Time) error {
+	for _, table := range revocationTables {
 		q := fmt.Sprintf(`
 			SELECT id, expired_at 
 			FROM %s 
 			WHERE
 				revoked_at IS NOT NULL AND 
+				revoked_at >= ? AND
 				(expired_at IS NULL OR expired_at > NOW());
 		`, table)
-		rows, err := a.db.Queryx(q)
+		rows, err := a.db.Queryx(q, since)
 		if errors.Is(sql.ErrNoRows, err) {
 			continue
 		} else if err != nil {
@@ -81,24 +111,54 @@ func (a *Auth) LoadRevocationList() error {
 		for rows.Next() {
 			var token tokenToRevoke
 			if err = rows.StructScan(&token); err != nil {
+				rows.Close()
 				return err
 			}
-			opt := cache.Options{}
-			if token.ExpiredAt.Valid {
-				opt.Expiration = time.Until(token.ExpiredAt.Time) + time.Hour
-			}
-			err := a.cache.PutValue(fmt.Sprintf("auth:revoked_%s", token.ID), true, &opt)
-			if err != nil {
-				panic(err)
+			if err := a.ApplyRevocation(token.ID, token.ExpiredAt); err != nil {
+				rows.Close()
+				return err
 			}
 		}
+		rows.Close()
 	}
 	return nil
 }
 
+// ApplyRevocation marks the token as revoked in the cache of this instance
+// until an hour after it expires.
+func (a *Auth) ApplyRevocation(tokenID string, expiredAt null.Time) error {
+	opt := cache.Options{}
+	if expiredAt.Valid {
+		if time.Until(expiredAt.Time) < -time.Hour {
+			return nil
+		}
+		opt.Expiration = time.Until(expiredAt.Time) + time.Hour
+	}
+	return a.cache.PutValue(fmt.Sprintf("auth:revoked_%s", tokenID), true, &opt)
+}
+
+// IsRevokedInDB checks the token table instead of the cache, for routes that
+// cannot afford to miss a revocation. Tokens not stored in a token table are
+// reported as not revoked.
+func (a *Auth) IsRevokedInDB(tokenID string) (bool, error) {
+	table, _, found := strings.Cut(tokenID, ":")
+	if !found || !slices.Contains(revocationTables, table) {
+		return false, nil
+	}
+
+	var revokedAt null.Time
+	err := a.db.Get(&revokedAt, fmt.Sprintf("SELECT revoked_at FROM %s WHERE id = ?", table), tokenID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return true, nil
+	} else if err != nil {
+		return false, err
+	}
+	return revokedAt.Valid, nil
+}
+
 func (a *Auth) Verify(tokenStr string, accountType string) (jwt.Token, error) {
 	opts := []jwt.ParseOption{
-		jwt.WithKey(jwa.RS256, a.verifyKey),
+		a.keyRing.VerifyOption(),
 	}
 
 	t, err := jwt.Parse([]byte(tokenStr), opts...)
@@ -142,13 +202,16 @@ func (a *Auth) verifyTokenContent(token jwt.Token, accountTypes ...string) (jwt.
 func (a *Auth) Revoke(token any) error {
 	var tokenID string
 	var expiration null.Time
-	assertionError := errors.New("Revoke expects  models.SystemAccessToken, models.AdminAccessToken or reference to those instances")
+	assertionError := errors.New("Revoke expects models.SystemAccessToken, models.AdminAccessToken, models.UserAccessToken or reference to those instances")
 
 	switch t := token.(type) {
 	case models.AdminAccessToken:
 		token = &t
 	case models.SystemAccessToken:
 		token = &t
+	case models.UserAccessToken:
+		token = &t
+	case *models.AdminAccessToken, *models.SystemAccessToken, *models.UserAccessToken:
 	default:
 		return assertionError
 	}
@@ -171,14 +234,58 @@ func (a *Auth) Revoke(token any) error {
 		}
 		tokenID = t.ID
 		expiration = t.ExpiredAt
+	case *models.UserAccessToken:
+		t.RevokedAt = now
+		if err := t.Update(a.db); err != nil {
+			return err
+		}
+		tokenID = t.ID
+		expiration = t.ExpiredAt
 
 	default:
 		return assertionError
 	}
 
-	opt := cache.Options{}
-	if expiration.Valid {
-		opt.Expiration = time.Until(expiration.Time) + time.Hour
+	if err := a.ApplyRevocation(tokenID, expiration); err != nil {
+		return err
 	}
-	return a.cache.PutValue(fmt.Sprintf("auth:revoked_%s", tokenID), true, &opt)
+
+	if a.PublishRevocation != nil {
+		if err := a.PublishRevocation(tokenID, expiration); err != nil {
+			log.Println("[Auth.Revoke] publish:", err)
+		}
+	}
+	return nil
+}
+
+// RevokeAdminTokens revokes every active access token issued to the admin and
+// returns the number of tokens revoked.
+func (a *Auth) RevokeAdminTokens(adminID string) (int, error) {
+	tokens, err := models.GetActiveAdminAccessTokens(a.db, adminID)
+	if err != nil {
+		return 0, err
+	}
+
+	for i := range tokens {
+		if err := a.Revoke(&tokens[i]); err != nil {
+			return i, err
+		}
+	}
+	return len(tokens), nil
+}
+
+// RevokeSystemTokens revokes every active access token issued to the system
+// and returns the number of tokens revoked.
+func (a *Auth) RevokeSystemTokens(systemID string) (int, error) {
+	tokens, err := models.GetActiveSystemAccessTokens(a.db, systemID)
+	if err != nil {
+		return 0, err
+	}
+
+	for i := range tokens {
+		if err := a.Revoke(&tokens[i]); err != nil {
+			return i, err
+		}
+	}
+	return len(tokens), nil
 }
@@ -0,0 +1,183 @@
+package authentication
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"be20250107/internal/modules/cache"
+
+	"github.com/jmoiron/sqlx"
+	"gopkg.in/guregu/null.v4"
+)
+
+// tokenTableDB is a database/sql connector serving the revoked tokens of each
+// token table, keyed by table name, and the revoked_at of the token ids. Tokens
+// missing from revokedAt have no row.
+type tokenTableDB struct {
+	revoked   map[string][]tokenToRevoke
+	revokedAt map[string]driver.Value
+	queries   []string
+}
+
+func (d *tokenTableDB) Connect(context.Context) (driver.Conn, error) { return tokenTableConn{d}, nil }
+func (d *tokenTableDB) Driver() driver.Driver                        { return nil }
+
+type tokenTableConn struct{ db *tokenTableDB }
+
+func (c tokenTableConn) Prepare(query string) (driver.Stmt, error) {
+	return tokenTableStmt{c.db, query}, nil
+}
+func (c tokenTableConn) Close() error              { return nil }
+func (c tokenTableConn) Begin() (driver.Tx, error) { return nil, errors.New("not supported") }
+
+type tokenTableStmt struct {
+	db    *tokenTableDB
+	query string
+}
+
+func (s tokenTableStmt) Close() error  { return nil }
+func (s tokenTableStmt) NumInput() int { return -1 }
+func (s tokenTableStmt) Exec(args []driver.Value) (driver.Result, error) {
+	return nil, errors.New("not supported")
+}
+func (s tokenTableStmt) Query(args []driver.Value) (driver.Rows, error) {
+	s.db.queries = append(s.db.queries, s.query)
+
+	if strings.Contains(s.query, "SELECT revoked_at") {
+		rows := &tokenTableRows{columns: []string{"revoked_at"}}
+		if value, exist := s.db.revokedAt[args[0].(string)]; exist {
+			rows.values = [][]driver.Value{{value}}
+		}
+		return rows, nil
+	}
+
+	rows := &tokenTableRows{columns: []string{"id", "expired_at"}}
+	for table, tokens := range s.db.revoked {
+		if !strings.Contains(s.query, "FROM "+table) {
+			continue
+		}
+		for _, token := range tokens {
+			var expiredAt driver.Value
+			if token.ExpiredAt.Valid {
+				expiredAt = token.ExpiredAt.Time
+			}
+			rows.values = append(rows.values, []driver.Value{token.ID, expiredAt})
+		}
+	}
+	return rows, nil
+}
+
+type tokenTableRows struct {
+	columns []string
+	values  [][]driver.Value
+}
+
+func (r *tokenTableRows) Columns() []string { return r.columns }
+func (r *tokenTableRows) Close() error      { return nil }
+func (r *tokenTableRows) Next(dest []driver.Value) error {
+	if len(r.values) == 0 {
+		return io.EOF
+	}
+	copy(dest, r.values[0])
+	r.values = r.values[1:]
+	return nil
+}
+
+func newRevocationAuth(db *tokenTableDB) *Auth {
+	return (&Auth{}).Init(sqlx.NewDb(sql.OpenDB(db), "mysql"), cache.NewInMemoryCache(nil), nil)
+}
+
+func isRevokedInCache(t *testing.T, a *Auth, tokenID string) bool {
+	t.Helper()
+	revoked, err := a.cache.Has("auth:revoked_" + tokenID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return revoked
+}
+
+func TestApplyRevocation(t *testing.T) {
+	a := newRevocationAuth(&tokenTableDB{})
+
+	tests := []struct {
+		name      string
+		tokenID   string
+		expiredAt null.Time
+		expected  bool
+	}{
+		{"without expiry", "admin_access_tokens:1", null.Time{}, true},
+		{"not expired", "admin_access_tokens:2", null.TimeFrom(time.Now().Add(time.Hour)), true},
+		{"recently expired", "admin_access_tokens:3", null.TimeFrom(time.Now().Add(-time.Minute)), true},
+		{"long expired", "admin_access_tokens:4", null.TimeFrom(time.Now().Add(-2 * time.Hour)), false},
+	}
+	for _, test := range tests {
+		if err := a.ApplyRevocation(test.tokenID, test.expiredAt); err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+		if revoked := isRevokedInCache(t, a, test.tokenID); revoked != test.expected {
+			t.Errorf("%s: expected revoked %v, got %v", test.name, test.expected, revoked)
+		}
+	}
+}
+
+func TestLoadRevocationsSince(t *testing.T) {
+	db := &tokenTableDB{revoked: map[string][]tokenToRevoke{
+		"admin_access_tokens":  {{ID: "admin_access_tokens:1"}},
+		"system_access_tokens": {{ID: "system_access_tokens:1", ExpiredAt: null.TimeFrom(time.Now().Add(time.Hour))}},
+		"user_access_tokens":   {{ID: "user_access_tokens:1"}, {ID: "user_access_tokens:2"}},
+	}}
+	a := newRevocationAuth(db)
+
+	if err := a.LoadRevocationsSince(time.Now().Add(-time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+	for _, tokenID := range []string{"admin_access_tokens:1", "system_access_tokens:1", "user_access_tokens:1", "user_access_tokens:2"} {
+		if !isRevokedInCache(t, a, tokenID) {
+			t.Errorf("expected %s to be revoked", tokenID)
+		}
+	}
+	if isRevokedInCache(t, a, "admin_access_tokens:2") {
+		t.Error("expected tokens that were not revoked to stay usable")
+	}
+	if len(db.queries) != len(revocationTables) {
+		t.Errorf("expected every token table to be queried, got %d queries", len(db.queries))
+	}
+}
+
+func TestIsRevokedInDB(t *testing.T) {
+	db := &tokenTableDB{revokedAt: map[string]driver.Value{
+		"admin_access_tokens:1": nil,
+		"admin_access_tokens:2": time.Now().Add(-time.Minute),
+	}}
+	a := newRevocationAuth(db)
+
+	tests := []struct {
+		name     string
+		tokenID  string
+		expected bool
+	}{
+		{"active token", "admin_access_tokens:1", false},
+		{"revoked token", "admin_access_tokens:2", true},
+		{"deleted token", "admin_access_tokens:3", true},
+		{"api key", "api_keys:1", false},
+		{"unprefixed id", "1", false},
+	}
+	for _, test := range tests {
+		revoked, err := a.IsRevokedInDB(test.tokenID)
+		if err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+		if revoked != test.expected {
+			t.Errorf("%s: expected revoked %v, got %v", test.name, test.expected, revoked)
+		}
+	}
+	if len(db.queries) != 3 {
+		t.Errorf("expected only the token tables to be queried, got %d queries", len(db.queries))
+	}
+}
@@ -0,0 +1,70 @@
+package authentication
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/lestrrat-go/jwx/v2/jwt"
+)
+
+const (
+	// ClaimFingerprint holds the SHA-256 of the device fingerprint a token is
+	// bound to.
+	ClaimFingerprint = "fpt"
+	// HeaderFingerprint carries the device fingerprint of the client.
+	HeaderFingerprint = "X-Device-Fingerprint"
+)
+
+// Token binding modes. With TokenBindingFlag mismatching requests are let
+// through but recorded, with TokenBindingEnforce they are rejected.
+const (
+	TokenBindingOff     = "off"
+	TokenBindingFlag    = "flag"
+	TokenBindingEnforce = "enforce"
+)
+
+var ErrFingerprintMismatch = errors.New("request fingerprint does not match the token")
+
+// HashFingerprint returns the value of the fingerprint claim.
+func HashFingerprint(fingerprint string) string {
+	sum := sha256.Sum256([]byte(strings.TrimSpace(fingerprint)))
+	return hex.EncodeToString(sum[:])
+}
+
+// BindsTokens reports whether new tokens should carry a fingerprint claim.
+func (a *Auth) BindsTokens() bool {
+	return a.TokenBinding == TokenBindingFlag || a.TokenBinding == TokenBindingEnforce
+}
+
+// EnforcesTokenBinding reports whether mismatching requests are rejected.
+func (a *Auth) EnforcesTokenBinding() bool {
+	return a.TokenBinding == TokenBindingEnforce
+}
+
+// CheckTokenBinding fails with ErrFingerprintMismatch if the token is bound to
+// a fingerprint other than the one sent with the request. Tokens without the
+// claim always pass.
+func (a *Auth) CheckTokenBinding(t jwt.Token, r *http.Request) error {
+	if !a.BindsTokens() {
+		return nil
+	}
+
+	claim, exist := t.Get(ClaimFingerprint)
+	if !exist {
+		return nil
+	}
+	expected, _ := claim.(string)
+
+	fingerprint := r.Header.Get(HeaderFingerprint)
+	if fingerprint == "" {
+		return ErrFingerprintMismatch
+	}
+	if subtle.ConstantTimeCompare([]byte(expected), []byte(HashFingerprint(fingerprint))) != 1 {
+		return ErrFingerprintMismatch
+	}
+	return nil
+}
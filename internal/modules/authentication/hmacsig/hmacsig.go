@@ -0,0 +1,99 @@
+// Package hmacsig implements the HMAC-SHA256 request signing scheme that
+// systems can use instead of bearer tokens.
+//
+// The string to sign is made of the request method, request URI (path and
+// query), unix timestamp, nonce and the hex encoded SHA-256 of the body, joined
+// by new lines. It is signed with HMAC-SHA256 and sent hex encoded.
+//
+// The signing key is derived from the plain system secret with DeriveKey, on
+// the client and on the server alike. It cannot be computed from the SHA-256
+// hash of the secret stored for token requests, so read access to that hash
+// does not allow forging signatures.
+package hmacsig
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	HeaderSystem    = "X-Signature-System"
+	HeaderTimestamp = "X-Signature-Timestamp"
+	HeaderNonce     = "X-Signature-Nonce"
+	HeaderSignature = "X-Signature"
+)
+
+var (
+	ErrMissingHeader    = errors.New("signature header is missing")
+	ErrInvalidTimestamp = errors.New("signature timestamp is invalid or outside the allowed clock skew")
+	ErrInvalidNonce     = errors.New("signature nonce must be between 8 and 128 characters")
+	ErrInvalidSignature = errors.New("signature does not match the request")
+)
+
+// Request holds the signed parts of a request.
+type Request struct {
+	Method     string
+	RequestURI string
+	Timestamp  string
+	Nonce      string
+	Body       []byte
+}
+
+// DeriveKey returns the signing key for a plain system secret: the hex encoded
+// HMAC-SHA256 of "hmacsig" keyed with the secret.
+func DeriveKey(secret string) []byte {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte("hmacsig"))
+	return []byte(hex.EncodeToString(mac.Sum(nil)))
+}
+
+// StringToSign returns the canonical representation of the request.
+func (r Request) StringToSign() string {
+	bodyHash := sha256.Sum256(r.Body)
+	return strings.Join([]string{
+		strings.ToUpper(r.Method),
+		r.RequestURI,
+		r.Timestamp,
+		r.Nonce,
+		hex.EncodeToString(bodyHash[:]),
+	}, "\n")
+}
+
+// Sign returns the hex encoded signature of the request.
+func (r Request) Sign(key []byte) string {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(r.StringToSign()))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// Verify checks the timestamp against the allowed clock skew, the nonce format
+// and the signature. Replayed nonces must be checked by the caller.
+func (r Request) Verify(key []byte, signature string, skew time.Duration, now time.Time) error {
+	ts, err := strconv.ParseInt(r.Timestamp, 10, 64)
+	if err != nil {
+		return ErrInvalidTimestamp
+	}
+	diff := now.Sub(time.Unix(ts, 0))
+	if diff < -skew || diff > skew {
+		return ErrInvalidTimestamp
+	}
+
+	if len(r.Nonce) < 8 || len(r.Nonce) > 128 {
+		return ErrInvalidNonce
+	}
+
+	expected, err := hex.DecodeString(r.Sign(key))
+	if err != nil {
+		return err
+	}
+	actual, err := hex.DecodeString(signature)
+	if err != nil || !hmac.Equal(expected, actual) {
+		return ErrInvalidSignature
+	}
+	return nil
+}
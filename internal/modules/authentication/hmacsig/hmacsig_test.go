@@ -0,0 +1,79 @@
+package hmacsig
+
+import (
+	"errors"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func newRequest(now time.Time) Request {
+	return Request{
+		Method:     "GET",
+		RequestURI: "/catalogues?limit=10",
+		Timestamp:  strconv.FormatInt(now.Unix(), 10),
+		Nonce:      "0123456789abcdef",
+		Body:       []byte(`{"a":1}`),
+	}
+}
+
+func TestVerify(t *testing.T) {
+	now := time.Now()
+	key := DeriveKey("secret")
+	req := newRequest(now)
+	signature := req.Sign(key)
+
+	if err := req.Verify(key, signature, time.Minute, now); err != nil {
+		t.Fatalf("expected valid signature, got %v", err)
+	}
+
+	if err := req.Verify(DeriveKey("other"), signature, time.Minute, now); !errors.Is(err, ErrInvalidSignature) {
+		t.Errorf("expected ErrInvalidSignature for wrong key, got %v", err)
+	}
+
+	if err := req.Verify(key, "not-hex", time.Minute, now); !errors.Is(err, ErrInvalidSignature) {
+		t.Errorf("expected ErrInvalidSignature for malformed signature, got %v", err)
+	}
+
+	tampered := req
+	tampered.Body = []byte(`{"a":2}`)
+	if err := tampered.Verify(key, signature, time.Minute, now); !errors.Is(err, ErrInvalidSignature) {
+		t.Errorf("expected ErrInvalidSignature for tampered body, got %v", err)
+	}
+
+	tampered = req
+	tampered.RequestURI = "/catalogues?limit=100"
+	if err := tampered.Verify(key, signature, time.Minute, now); !errors.Is(err, ErrInvalidSignature) {
+		t.Errorf("expected ErrInvalidSignature for tampered query, got %v", err)
+	}
+}
+
+func TestVerifyClockSkew(t *testing.T) {
+	now := time.Now()
+	key := DeriveKey("secret")
+	req := newRequest(now)
+	signature := req.Sign(key)
+
+	if err := req.Verify(key, signature, time.Minute, now.Add(2*time.Minute)); !errors.Is(err, ErrInvalidTimestamp) {
+		t.Errorf("expected ErrInvalidTimestamp for old request, got %v", err)
+	}
+	if err := req.Verify(key, signature, time.Minute, now.Add(-2*time.Minute)); !errors.Is(err, ErrInvalidTimestamp) {
+		t.Errorf("expected ErrInvalidTimestamp for future request, got %v", err)
+	}
+
+	req.Timestamp = "yesterday"
+	if err := req.Verify(key, req.Sign(key), time.Minute, now); !errors.Is(err, ErrInvalidTimestamp) {
+		t.Errorf("expected ErrInvalidTimestamp for malformed timestamp, got %v", err)
+	}
+}
+
+func TestVerifyNonce(t *testing.T) {
+	now := time.Now()
+	key := DeriveKey("secret")
+	req := newRequest(now)
+	req.Nonce = "short"
+
+	if err := req.Verify(key, req.Sign(key), time.Minute, now); !errors.Is(err, ErrInvalidNonce) {
+		t.Errorf("expected ErrInvalidNonce, got %v", err)
+	}
+}
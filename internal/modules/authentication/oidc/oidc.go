@@ -0,0 +1,208 @@
+package oidc
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/sync/singleflight"
+)
+
+// DefaultTimeout bounds every request to the provider when Options.Timeout is
+// not set.
+const DefaultTimeout = 10 * time.Second
+
+type Client struct {
+	options Options
+	http    *http.Client
+
+	mu        sync.Mutex
+	discovery *Discovery
+	discover  singleflight.Group
+}
+
+type Options struct {
+	Issuer       string
+	ClientID     string
+	ClientSecret string
+	RedirectURI  string
+	Scopes       []string
+	Timeout      time.Duration
+}
+
+// Discovery is the subset of the OpenID Provider metadata used by the client.
+type Discovery struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	UserinfoEndpoint      string `json:"userinfo_endpoint"`
+}
+
+type Token struct {
+	TokenType    string `json:"token_type"`
+	ExpiresIn    int    `json:"expires_in"`
+	AccessToken  string `json:"access_token"`
+	RefreshToken string `json:"refresh_token"`
+	IDToken      string `json:"id_token"`
+}
+
+var (
+	ErrInvalidClient       = errors.New("invalid client")
+	ErrUnprocessableEntity = errors.New("unprocessable entity")
+	ErrServerUnavailable   = errors.New("server unavailable")
+)
+
+func NewClient(option Options) *Client {
+	if len(option.Scopes) == 0 {
+		option.Scopes = []string{"openid", "profile", "email"}
+	}
+	if option.Timeout <= 0 {
+		option.Timeout = DefaultTimeout
+	}
+	return &Client{options: option, http: &http.Client{Timeout: option.Timeout}}
+}
+
+// Discover fetches the provider metadata from the issuer's well-known
+// configuration endpoint. The result is cached for the lifetime of the client.
+// Concurrent callers share one fetch, which is made without holding the lock
+// so that a slow issuer does not block callers once the metadata is cached.
+func (c *Client) Discover() (*Discovery, error) {
+	c.mu.Lock()
+	d := c.discovery
+	c.mu.Unlock()
+	if d != nil {
+		return d, nil
+	}
+
+	v, err, _ := c.discover.Do("discovery", func() (any, error) {
+		d, err := c.fetchDiscovery()
+		if err != nil {
+			return nil, err
+		}
+		c.mu.Lock()
+		c.discovery = d
+		c.mu.Unlock()
+		return d, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return v.(*Discovery), nil
+}
+
+func (c *Client) fetchDiscovery() (*Discovery, error) {
+	u := strings.TrimRight(c.options.Issuer, "/") + "/.well-known/openid-configuration"
+	resp, err := c.http.Get(u)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 400 {
+		return nil, ErrServerUnavailable
+	}
+
+	var d Discovery
+	if err := json.NewDecoder(resp.Body).Decode(&d); err != nil {
+		return nil, err
+	}
+	return &d, nil
+}
+
+func (c *Client) AuthorizeURL(state string) (string, error) {
+	d, err := c.Discover()
+	if err != nil {
+		return "", err
+	}
+
+	u, err := url.Parse(d.AuthorizationEndpoint)
+	if err != nil {
+		return "", err
+	}
+	q := u.Query()
+	q.Set("response_type", "code")
+	q.Set("client_id", c.options.ClientID)
+	q.Set("redirect_uri", c.options.RedirectURI)
+	q.Set("scope", strings.Join(c.options.Scopes, " "))
+	if state != "" {
+		q.Set("state", state)
+	}
+	u.RawQuery = q.Encode()
+	return u.String(), nil
+}
+
+func (c *Client) Authenticate(code string) (*Token, error) {
+	d, err := c.Discover()
+	if err != nil {
+		return nil, err
+	}
+
+	form := url.Values{
+		"grant_type":    {"authorization_code"},
+		"client_id":     {c.options.ClientID},
+		"client_secret": {c.options.ClientSecret},
+		"redirect_uri":  {c.options.RedirectURI},
+		"code":          {code},
+	}
+	resp, err := c.http.PostForm(d.TokenEndpoint, form)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 400 {
+		switch resp.StatusCode {
+		case 400, 422:
+			return nil, ErrUnprocessableEntity
+		case 401:
+			return nil, ErrInvalidClient
+		default:
+			return nil, ErrServerUnavailable
+		}
+	}
+
+	var token Token
+	if err := json.NewDecoder(resp.Body).Decode(&token); err != nil {
+		return nil, err
+	}
+	return &token, nil
+}
+
+// FetchUserInfo returns the claims of the userinfo endpoint for the user the
+// token was issued to.
+func (c *Client) FetchUserInfo(token *Token) (map[string]any, error) {
+	d, err := c.Discover()
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequest("GET", d.UserinfoEndpoint, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header = http.Header{
+		"Authorization": {fmt.Sprintf("Bearer %s", token.AccessToken)},
+		"Accept":        {"application/json"},
+	}
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 400 {
+		return nil, ErrServerUnavailable
+	}
+
+	claims := map[string]any{}
+	if err := json.NewDecoder(resp.Body).Decode(&claims); err != nil {
+		return nil, err
+	}
+	return claims, nil
+}
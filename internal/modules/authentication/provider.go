@@ -0,0 +1,249 @@
+package authentication
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"be20250107/internal/modules/authentication/oidc"
+	"be20250107/internal/modules/authentication/xinchuanauth"
+)
+
+const ProviderXinchuanAuth = "xinchuan-auth"
+
+var ErrUnknownProvider = errors.New("identity provider is not registered")
+
+// IdentityProvider is an external service admins can sign in with using the
+// OAuth 2.0 authorization code flow.
+type IdentityProvider interface {
+	// Name is the value stored in admins.provider for accounts of this provider.
+	Name() string
+	AuthorizeURL(state string) (string, error)
+	Exchange(code string) (*ProviderToken, error)
+	FetchAccount(token *ProviderToken) (*ProviderAccount, error)
+}
+
+type ProviderToken struct {
+	TokenType    string
+	AccessToken  string
+	RefreshToken string
+	ExpiresIn    int
+}
+
+// ProviderAccount is the account information returned by an identity
+// provider, normalized so that it can be matched to admins.provider_id.
+type ProviderAccount struct {
+	ID            string
+	Name          string
+	Username      string
+	Email         string
+	RoleID        string
+	Status        string
+	DeactivatedAt *time.Time
+}
+
+// IsDeactivated reports whether the account has been deactivated on the
+// identity provider side.
+func (a ProviderAccount) IsDeactivated() bool {
+	if a.DeactivatedAt != nil && !a.DeactivatedAt.After(time.Now()) {
+		return true
+	}
+	return strings.EqualFold(a.Status, "deactivated")
+}
+
+// RoleMapper is implemented by identity providers that can map their own role
+// IDs to the name of a local role.
+type RoleMapper interface {
+	MapRole(providerRoleID string) (string, bool)
+}
+
+// TokenRefresher is implemented by identity providers that issue refresh
+// tokens, allowing accounts to be synced in the background.
+type TokenRefresher interface {
+	Refresh(refreshToken string) (*ProviderToken, error)
+}
+
+// IdentityProviders is the registry of identity providers keyed by name.
+type IdentityProviders struct {
+	providers map[string]IdentityProvider
+}
+
+func NewIdentityProviders() *IdentityProviders {
+	return &IdentityProviders{providers: map[string]IdentityProvider{}}
+}
+
+func (p *IdentityProviders) Register(provider IdentityProvider) {
+	p.providers[provider.Name()] = provider
+}
+
+func (p *IdentityProviders) Get(name string) (IdentityProvider, error) {
+	provider, ok := p.providers[name]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownProvider, name)
+	}
+	return provider, nil
+}
+
+func (p *IdentityProviders) Names() []string {
+	names := make([]string, 0, len(p.providers))
+	for name := range p.providers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// XinchuanProvider adapts the Xinchuan SSO client to IdentityProvider.
+type XinchuanProvider struct {
+	Client *xinchuanauth.Client
+	// RoleMapping maps Xinchuan role IDs to local role names.
+	RoleMapping map[string]string
+}
+
+func (p XinchuanProvider) Name() string {
+	return ProviderXinchuanAuth
+}
+
+func (p XinchuanProvider) AuthorizeURL(state string) (string, error) {
+	return p.Client.AuthorizeURL(state)
+}
+
+func (p XinchuanProvider) Exchange(code string) (*ProviderToken, error) {
+	t, err := p.Client.Authenticate(code)
+	if err != nil {
+		return nil, err
+	}
+	return &ProviderToken{
+		TokenType:    t.TokenType,
+		AccessToken:  t.AccessToken,
+		RefreshToken: t.RefreshToken,
+		ExpiresIn:    t.ExpiresIn,
+	}, nil
+}
+
+func (p XinchuanProvider) Refresh(refreshToken string) (*ProviderToken, error) {
+	t, err := p.Client.Refresh(refreshToken)
+	if err != nil {
+		return nil, err
+	}
+	return &ProviderToken{
+		TokenType:    t.TokenType,
+		AccessToken:  t.AccessToken,
+		RefreshToken: t.RefreshToken,
+		ExpiresIn:    t.ExpiresIn,
+	}, nil
+}
+
+func (p XinchuanProvider) MapRole(providerRoleID string) (string, bool) {
+	role, ok := p.RoleMapping[providerRoleID]
+	return role, ok && role != ""
+}
+
+func (p XinchuanProvider) FetchAccount(token *ProviderToken) (*ProviderAccount, error) {
+	a, err := p.Client.FetchAccount(&xinchuanauth.Token{
+		TokenType:    token.TokenType,
+		AccessToken:  token.AccessToken,
+		RefreshToken: token.RefreshToken,
+		ExpiresIn:    token.ExpiresIn,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	account := &ProviderAccount{
+		ID:            fmt.Sprintf("%d", a.ID),
+		Name:          a.Name,
+		Username:      a.Username,
+		Status:        a.Status,
+		DeactivatedAt: a.DeactivatedAt,
+	}
+	if a.RoleID != nil {
+		account.RoleID = fmt.Sprintf("%d", *a.RoleID)
+	}
+	return account, nil
+}
+
+// OIDCProvider adapts a generic OpenID Connect client to IdentityProvider.
+// Claims are mapped to the account fields using the configured claim names.
+type OIDCProvider struct {
+	ProviderName  string
+	Client        *oidc.Client
+	IDClaim       string
+	UsernameClaim string
+	NameClaim     string
+	EmailClaim    string
+	RoleClaim     string
+}
+
+func (p OIDCProvider) Name() string {
+	return p.ProviderName
+}
+
+func (p OIDCProvider) AuthorizeURL(state string) (string, error) {
+	return p.Client.AuthorizeURL(state)
+}
+
+func (p OIDCProvider) Exchange(code string) (*ProviderToken, error) {
+	t, err := p.Client.Authenticate(code)
+	if err != nil {
+		return nil, err
+	}
+	return &ProviderToken{
+		TokenType:    t.TokenType,
+		AccessToken:  t.AccessToken,
+		RefreshToken: t.RefreshToken,
+		ExpiresIn:    t.ExpiresIn,
+	}, nil
+}
+
+func (p OIDCProvider) FetchAccount(token *ProviderToken) (*ProviderAccount, error) {
+	claims, err := p.Client.FetchUserInfo(&oidc.Token{
+		TokenType:    token.TokenType,
+		AccessToken:  token.AccessToken,
+		RefreshToken: token.RefreshToken,
+		ExpiresIn:    token.ExpiresIn,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	account := &ProviderAccount{
+		ID:       claimString(claims, p.IDClaim, "sub"),
+		Name:     claimString(claims, p.NameClaim, "name"),
+		Username: claimString(claims, p.UsernameClaim, "preferred_username"),
+		Email:    claimString(claims, p.EmailClaim, "email"),
+		RoleID:   claimString(claims, p.RoleClaim, ""),
+	}
+	if account.ID == "" {
+		return nil, oidc.ErrUnprocessableEntity
+	}
+	if account.Username == "" && account.Email != "" {
+		account.Username = strings.SplitN(account.Email, "@", 2)[0]
+	}
+	if account.Name == "" {
+		account.Name = account.Username
+	}
+	return account, nil
+}
+
+func claimString(claims map[string]any, name string, fallback string) string {
+	if name == "" {
+		name = fallback
+	}
+	if name == "" {
+		return ""
+	}
+
+	switch v := claims[name].(type) {
+	case string:
+		return v
+	case float64:
+		return fmt.Sprintf("%.0f", v)
+	case nil:
+		return ""
+	default:
+		return fmt.Sprint(v)
+	}
+}
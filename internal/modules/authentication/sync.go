@@ -0,0 +1,158 @@
+package authentication
+
+import (
+	"context"
+	"log"
+	"math"
+	"time"
+
+	"be20250107/internal/models"
+
+	"gopkg.in/guregu/null.v4"
+)
+
+const syncBatchSize = 100
+
+// SyncAdmin applies the account state reported by the identity provider to the
+// admin: name, mapped role and deactivation. Changes are persisted, and every
+// active token of a newly deactivated admin is revoked. Only deactivations made
+// by the provider are lifted when it reports the account active again; admins
+// deactivated locally stay deactivated. It reports whether the admin has
+// changed.
+func (a *Auth) SyncAdmin(admin *models.Admin, provider IdentityProvider, account *ProviderAccount) (bool, error) {
+	changed := false
+	deactivated := false
+
+	if account.Name != "" && account.Name != admin.Name {
+		admin.Name = account.Name
+		changed = true
+	}
+
+	if mapper, ok := provider.(RoleMapper); ok && account.RoleID != "" {
+		if roleName, ok := mapper.MapRole(account.RoleID); ok {
+			role, exist, err := models.GetRoleByName(a.db, roleName)
+			if err != nil {
+				return false, err
+			}
+
+			if !exist {
+				log.Printf("[Auth.SyncAdmin] role %q mapped from %s role %s does not exist", roleName, provider.Name(), account.RoleID)
+			} else if admin.RoleID.ValueOrZero() != role.ID {
+				admin.RoleID = null.StringFrom(role.ID)
+				changed = true
+			}
+		}
+	}
+
+	if account.IsDeactivated() && !admin.DeactivatedAt.Valid {
+		deactivatedAt := time.Now()
+		if account.DeactivatedAt != nil {
+			deactivatedAt = *account.DeactivatedAt
+		}
+		admin.DeactivatedAt = null.TimeFrom(deactivatedAt)
+		admin.DeactivatedByProvider = true
+		changed = true
+		deactivated = true
+	} else if !account.IsDeactivated() && admin.DeactivatedAt.Valid && admin.DeactivatedByProvider {
+		admin.DeactivatedAt = null.Time{}
+		admin.DeactivatedByProvider = false
+		changed = true
+	}
+
+	if !changed {
+		return false, nil
+	}
+
+	if err := admin.Update(a.db); err != nil {
+		return false, err
+	}
+
+	if deactivated {
+		if _, err := a.RevokeAdminTokens(admin.ID); err != nil {
+			return true, err
+		}
+	}
+	return true, nil
+}
+
+// SyncProviderAdmins refreshes every admin whose identity provider supports
+// refresh tokens and applies the fetched account with SyncAdmin. It returns the
+// IDs of the admins that have changed. Admins whose account cannot be fetched
+// are skipped. It stops between batches once ctx is done.
+func (a *Auth) SyncProviderAdmins(ctx context.Context) ([]string, error) {
+	var changedIDs []string
+	lastID := ""
+	var lastCreatedAt int64 = math.MaxInt64
+
+	for {
+		if err := ctx.Err(); err != nil {
+			return changedIDs, err
+		}
+		admins, err := models.GetAdminBatched(a.db, lastID, lastCreatedAt, syncBatchSize)
+		if err != nil {
+			return changedIDs, err
+		}
+
+		for i := range admins {
+			admin := &admins[i]
+			changed, err := a.syncProviderAdmin(admin)
+			if err != nil {
+				log.Printf("[Auth.SyncProviderAdmins] sync %s: %v", admin.ID, err)
+				continue
+			}
+			if changed {
+				changedIDs = append(changedIDs, admin.ID)
+			}
+		}
+
+		if len(admins) < syncBatchSize {
+			return changedIDs, nil
+		}
+		last := admins[len(admins)-1]
+		lastID = last.ID
+		lastCreatedAt = last.CreatedAt
+	}
+}
+
+func (a *Auth) syncProviderAdmin(admin *models.Admin) (bool, error) {
+	if !admin.ProviderRefreshToken.Valid || a.Providers == nil || a.SecretBox == nil {
+		return false, nil
+	}
+
+	provider, err := a.Providers.Get(admin.Provider)
+	if err != nil {
+		return false, nil
+	}
+
+	refresher, ok := provider.(TokenRefresher)
+	if !ok {
+		return false, nil
+	}
+
+	refreshToken, err := admin.PlainProviderRefreshToken(a.SecretBox)
+	if err != nil {
+		return false, err
+	}
+
+	token, err := refresher.Refresh(refreshToken)
+	if err != nil {
+		return false, err
+	}
+
+	if token.RefreshToken != "" {
+		sealed, err := models.SealProviderRefreshToken(a.SecretBox, token.RefreshToken)
+		if err != nil {
+			return false, err
+		}
+		if err := admin.UpdateProviderRefreshToken(a.db, sealed); err != nil {
+			return false, err
+		}
+	}
+
+	account, err := provider.FetchAccount(token)
+	if err != nil {
+		return false, err
+	}
+
+	return a.SyncAdmin(admin, provider, account)
+}
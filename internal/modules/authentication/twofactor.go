@@ -0,0 +1,59 @@
+package authentication
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"be20250107/internal/models"
+	"be20250107/internal/modules/cache"
+	"be20250107/internal/modules/totp"
+
+	"github.com/lestrrat-go/jwx/v2/jwt"
+)
+
+// ClaimTwoFactorPending is set on admin tokens issued before the admin passed
+// the TOTP challenge. Such tokens are refused by privileged routes.
+const ClaimTwoFactorPending = "2fa_pending"
+
+// totpSkew is the number of 30 second steps a code may be early or late.
+const totpSkew = 1
+
+var (
+	ErrInvalidTOTP = errors.New("TOTP code is invalid")
+	ErrTOTPReused  = errors.New("TOTP code has already been used")
+)
+
+// IsTwoFactorPending reports whether the token was issued before the TOTP
+// challenge was passed.
+func IsTwoFactorPending(t jwt.Token) bool {
+	pending, _ := t.Get(ClaimTwoFactorPending)
+	b, _ := pending.(bool)
+	return b
+}
+
+// VerifyTOTP checks the code against the TOTP secret of the admin, which may
+// still be pending enrollment. A code is accepted only once.
+func (a *Auth) VerifyTOTP(admin *models.Admin, code string) error {
+	if !admin.TOTPSecret.Valid {
+		return ErrInvalidTOTP
+	}
+
+	secret, err := admin.PlainTOTPSecret(a.SecretBox)
+	if err != nil {
+		return err
+	}
+	step, ok := totp.Validate(secret, code, time.Now(), totpSkew)
+	if !ok {
+		return ErrInvalidTOTP
+	}
+
+	key := fmt.Sprintf("auth:totp_used_%s_%d", admin.ID, step)
+	fresh, err := a.cache.SetNX(key, true, &cache.Options{Expiration: (2*totpSkew + 1) * totp.Period})
+	if err != nil {
+		return err
+	} else if !fresh {
+		return ErrTOTPReused
+	}
+	return nil
+}
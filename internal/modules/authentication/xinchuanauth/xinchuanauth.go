@@ -53,6 +53,25 @@ func NewClient(option Options) *Client {
 	return &Client{options: option}
 }
 
+func (c *Client) AuthorizeURL(state string) (string, error) {
+	u, err := url.Parse(c.options.BaseURL)
+	if err != nil {
+		return "", err
+	}
+	u.Path = "/oauth/authorize"
+
+	q := u.Query()
+	q.Set("response_type", "code")
+	q.Set("client_id", fmt.Sprintf("%d", c.options.ClientID))
+	q.Set("redirect_uri", c.options.RedirectURI)
+	q.Set("scope", "*")
+	if state != "" {
+		q.Set("state", state)
+	}
+	u.RawQuery = q.Encode()
+	return u.String(), nil
+}
+
 func (c *Client) Authenticate(code string) (*Token, error) {
 	u, err := url.Parse(c.options.BaseURL)
 	if err != nil {
@@ -102,6 +121,56 @@ func (c *Client) Authenticate(code string) (*Token, error) {
 	return &token, nil
 }
 
+// Refresh exchanges a refresh token for a new token pair, which allows the
+// account to be fetched again without the user being present.
+func (c *Client) Refresh(refreshToken string) (*Token, error) {
+	u, err := url.Parse(c.options.BaseURL)
+	if err != nil {
+		return nil, err
+	}
+	u.Path = "/oauth/token"
+
+	payload, err := json.Marshal(struct {
+		GrantType    string `json:"grant_type"`
+		ClientID     int    `json:"client_id"`
+		ClientSecret string `json:"client_secret"`
+		Scope        string `json:"scope"`
+		RefreshToken string `json:"refresh_token"`
+	}{
+		GrantType:    "refresh_token",
+		ClientID:     c.options.ClientID,
+		ClientSecret: c.options.ClientSecret,
+		Scope:        "*",
+		RefreshToken: refreshToken,
+	})
+	if err != nil {
+		return nil, err
+	}
+	resp, err := http.Post(u.String(), "application/json", bytes.NewBuffer(payload))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 400 {
+		switch resp.StatusCode {
+		case 400, 422:
+			return nil, ErrUnprocessableEntity
+		case 401:
+			return nil, ErrInvalidClient
+		default:
+			return nil, ErrServerUnavailable
+		}
+	}
+
+	var token Token
+	err = json.NewDecoder(resp.Body).Decode(&token)
+	if err != nil {
+		return nil, err
+	}
+	return &token, nil
+}
+
 func parseTime(t string) *time.Time {
 	if t == "" {
 		return nil
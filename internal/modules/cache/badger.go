@@ -2,21 +2,27 @@ package cache
 
 import (
 	"bytes"
-	"encoding/gob"
 	"errors"
-	"io"
 	"reflect"
+	"time"
 
 	"github.com/dgraph-io/badger/v3"
 )
 
 type BadgerCache struct {
-	db *badger.DB
+	db    *badger.DB
+	codec Codec
 }
 
-func NewBadgerCache(db *badger.DB) Cache {
+// NewBadgerCache returns a BadgerCache writing values with the codec, or with
+// DefaultCodec if it is nil.
+func NewBadgerCache(db *badger.DB, codec Codec) Cache {
+	if codec == nil {
+		codec = DefaultCodec
+	}
 	return &BadgerCache{
 		db,
+		codec,
 	}
 }
 
@@ -88,9 +94,10 @@ func (c BadgerCache) GetValue(key string, ptr any) (any, error) {
 		return nil, err
 	}
 
-	buf := bytes.NewBuffer(value)
-	err = gob.NewDecoder(buf).Decode(ptr)
-	return ptr, err
+	if err := decodeEntry(value, ptr); err != nil {
+		return nil, err
+	}
+	return ptr, nil
 }
 
 func (c BadgerCache) GetKeysWithPrefix(prefix string) ([]string, error) {
@@ -144,15 +151,13 @@ func (c BadgerCache) GetValuesWithPrefix(prefix string, arrPtr any) (any, error)
 		elemType = elemType.Elem()
 	}
 
-	arr := reflect.MakeSlice(reflect.SliceOf(elemType), len(vBuf), len(vBuf))
-	for i, out := range vBuf {
+	arr := reflect.MakeSlice(reflect.SliceOf(elemType), 0, len(vBuf))
+	for _, out := range vBuf {
 		ins := reflect.New(elemType)
-		buf := bytes.NewBuffer(out)
-		err = gob.NewDecoder(buf).DecodeValue(ins)
-		if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) {
-			return nil, err
+		if err := decodeEntry(out, ins.Interface()); err != nil {
+			continue
 		}
-		arr.Index(i).Set(ins.Elem())
+		arr = reflect.Append(arr, ins.Elem())
 	}
 
 	baseValue := reflect.ValueOf(arrPtr)
@@ -200,10 +205,8 @@ func (c BadgerCache) GetItemsWithPrefix(prefix string, mapPtr any) (any, error)
 	for i, v := range valBuf {
 		key := string(keyBuf[i])
 		ins := reflect.New(elemType)
-		buf := bytes.NewBuffer(v)
-		err = gob.NewDecoder(buf).DecodeValue(ins)
-		if err != nil && !errors.Is(err, io.EOF) && !errors.Is(err, io.ErrUnexpectedEOF) {
-			return nil, err
+		if err := decodeEntry(v, ins.Interface()); err != nil {
+			continue
 		}
 		m.SetMapIndex(reflect.ValueOf(key), ins.Elem())
 	}
@@ -228,23 +231,262 @@ func (c BadgerCache) PutInt(key string, value int, config *Options) error {
 }
 
 func (c BadgerCache) PutValue(key string, value any, config *Options) error {
-	var buf bytes.Buffer
-	err := gob.NewEncoder(&buf).Encode(value)
+	data, err := encodeEntry(c.codec, value)
 	if err != nil {
 		return err
 	}
 
 	err = c.db.Update(func(txn *badger.Txn) error {
-		e := badger.NewEntry([]byte(key), buf.Bytes())
+		e := badger.NewEntry([]byte(key), data)
 		if config != nil && config.Expiration > 0 {
-			e = e.WithTTL(config.Expiration)
+			e = e.WithTTL(badgerTTL(config.Expiration))
 		}
-		err := txn.SetEntry(e)
-		return err
+		if err := txn.SetEntry(e); err != nil {
+			return err
+		}
+
+		//	Index entries expire with the key they point to.
+		for _, tag := range config.tags() {
+			index := badger.NewEntry(badgerTagKey(tag, key), nil)
+			index.ExpiresAt = e.ExpiresAt
+			if err := txn.SetEntry(index); err != nil {
+				return err
+			}
+		}
+		return nil
 	})
 	return err
 }
 
+func (c BadgerCache) Increment(key string, config *Options) (int64, error) {
+	return c.IncrementBy(key, 1, config)
+}
+
+func (c BadgerCache) IncrementBy(key string, delta int64, config *Options) (int64, error) {
+	var n int64
+	err := c.update(func(txn *badger.Txn) error {
+		n = 0
+		e := badger.NewEntry([]byte(key), nil)
+		item, err := txn.Get([]byte(key))
+		if err == nil {
+			err = item.Value(func(val []byte) error {
+				return decodeEntry(val, &n)
+			})
+		}
+		if err == nil {
+			e.ExpiresAt = item.ExpiresAt()
+		} else if errors.Is(err, badger.ErrKeyNotFound) || errors.Is(err, ErrKeyNotFound) {
+			//	A counter that cannot be decoded starts over like a missing
+			//	one.
+			n = 0
+			if config != nil && config.Expiration > 0 {
+				e = e.WithTTL(badgerTTL(config.Expiration))
+			}
+		} else {
+			return err
+		}
+
+		n += delta
+		e.Value, err = encodeEntry(c.codec, n)
+		if err != nil {
+			return err
+		}
+		return txn.SetEntry(e)
+	})
+	if err != nil {
+		return 0, err
+	}
+	return n, nil
+}
+
+func (c BadgerCache) CompareAndSwap(key string, old []byte, new []byte, config *Options) (bool, error) {
+	data, err := encodeEntry(c.codec, new)
+	if err != nil {
+		return false, err
+	}
+
+	swapped := false
+	err = c.db.Update(func(txn *badger.Txn) error {
+		var current []byte
+		item, err := txn.Get([]byte(key))
+		if err == nil {
+			err = item.Value(func(val []byte) error {
+				return decodeEntry(val, &current)
+			})
+		}
+		if errors.Is(err, badger.ErrKeyNotFound) || errors.Is(err, ErrKeyNotFound) {
+			if old != nil {
+				return nil
+			}
+		} else if err != nil {
+			return err
+		} else if old == nil || !bytes.Equal(current, old) {
+			return nil
+		}
+
+		e := badger.NewEntry([]byte(key), data)
+		if config != nil && config.Expiration > 0 {
+			e = e.WithTTL(badgerTTL(config.Expiration))
+		}
+		if err := txn.SetEntry(e); err != nil {
+			return err
+		}
+		swapped = true
+		return nil
+	})
+	if errors.Is(err, badger.ErrConflict) {
+		//	Another transaction changed the key in the meantime.
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+	return swapped, nil
+}
+
+func (c BadgerCache) SetNX(key string, value any, config *Options) (bool, error) {
+	data, err := encodeEntry(c.codec, value)
+	if err != nil {
+		return false, err
+	}
+
+	set := false
+	err = c.update(func(txn *badger.Txn) error {
+		set = false
+		if _, err := txn.Get([]byte(key)); err == nil {
+			return nil
+		} else if !errors.Is(err, badger.ErrKeyNotFound) {
+			return err
+		}
+
+		e := badger.NewEntry([]byte(key), data)
+		if config != nil && config.Expiration > 0 {
+			e = e.WithTTL(badgerTTL(config.Expiration))
+		}
+		if err := txn.SetEntry(e); err != nil {
+			return err
+		}
+		set = true
+		return nil
+	})
+	return set, err
+}
+
+func (c BadgerCache) TTL(key string) (time.Duration, error) {
+	var expiresAt uint64
+	err := c.db.View(func(txn *badger.Txn) error {
+		item, err := txn.Get([]byte(key))
+		if err != nil {
+			return err
+		}
+		expiresAt = item.ExpiresAt()
+		return nil
+	})
+	if errors.Is(err, badger.ErrKeyNotFound) {
+		return 0, ErrKeyNotFound
+	} else if err != nil {
+		return 0, err
+	}
+
+	if expiresAt == 0 {
+		return 0, nil
+	}
+	return max(time.Until(time.Unix(int64(expiresAt), 0)), 0), nil
+}
+
+func (c BadgerCache) Expire(key string, expiration time.Duration) error {
+	err := c.update(func(txn *badger.Txn) error {
+		item, err := txn.Get([]byte(key))
+		if err != nil {
+			return err
+		}
+		value, err := item.ValueCopy(nil)
+		if err != nil {
+			return err
+		}
+
+		e := badger.NewEntry([]byte(key), value)
+		if expiration > 0 {
+			e = e.WithTTL(badgerTTL(expiration))
+		}
+		return txn.SetEntry(e)
+	})
+	if errors.Is(err, badger.ErrKeyNotFound) {
+		return ErrKeyNotFound
+	}
+	return err
+}
+
+// badgerTagKey returns the index key marking the key as written with the tag.
+// The tag is terminated by a NUL byte so that no tag is a prefix of another.
+func badgerTagKey(tag string, key string) []byte {
+	return []byte(badgerTagPrefix(tag) + key)
+}
+
+func badgerTagPrefix(tag string) string {
+	return "cache_tag:" + tag + "\x00"
+}
+
+func (c BadgerCache) InvalidateTags(tags ...string) error {
+	_, err := c.InvalidateTaggedKeys(tags...)
+	return err
+}
+
+// InvalidateTaggedKeys deletes the keys written with any of the tags and
+// returns them.
+func (c BadgerCache) InvalidateTaggedKeys(tags ...string) ([]string, error) {
+	var keys [][]byte
+	var deleted []string
+	err := c.db.View(func(txn *badger.Txn) error {
+		opts := badger.DefaultIteratorOptions
+		opts.PrefetchValues = false
+		it := txn.NewIterator(opts)
+		defer it.Close()
+
+		for _, tag := range tags {
+			prefix := []byte(badgerTagPrefix(tag))
+			for it.Seek(prefix); it.ValidForPrefix(prefix); it.Next() {
+				index := it.Item().KeyCopy(nil)
+				keys = append(keys, index, index[len(prefix):])
+				deleted = append(deleted, string(index[len(prefix):]))
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	wb := c.db.NewWriteBatch()
+	defer wb.Cancel()
+	for _, k := range keys {
+		if err := wb.Delete(k); err != nil {
+			return nil, err
+		}
+	}
+	if err := wb.Flush(); err != nil {
+		return nil, err
+	}
+	return deleted, nil
+}
+
+// badgerTTL rounds the expiration up to whole seconds, which is the
+// resolution of badger expirations, so that short expirations do not expire
+// immediately.
+func badgerTTL(d time.Duration) time.Duration {
+	return (d + time.Second - 1).Truncate(time.Second)
+}
+
+// update runs fn in a read-write transaction, retrying it when it conflicts
+// with a concurrent transaction.
+func (c BadgerCache) update(fn func(txn *badger.Txn) error) error {
+	for {
+		err := c.db.Update(fn)
+		if !errors.Is(err, badger.ErrConflict) {
+			return err
+		}
+	}
+}
+
 func (c BadgerCache) Delete(key string) error {
 	err := c.db.Update(func(txn *badger.Txn) error {
 		err := txn.Delete([]byte(key))
@@ -259,6 +501,19 @@ func (c BadgerCache) Delete(key string) error {
 	return err
 }
 
+// RunGC rewrites value log files until none has enough stale data to be worth
+// rewriting.
+func (c BadgerCache) RunGC() error {
+	for {
+		err := c.db.RunValueLogGC(0.5)
+		if errors.Is(err, badger.ErrNoRewrite) || errors.Is(err, badger.ErrGCInMemoryMode) {
+			return nil
+		} else if err != nil {
+			return err
+		}
+	}
+}
+
 func (c BadgerCache) Flush() error {
 	return c.db.DropAll()
 }
@@ -21,14 +21,66 @@ type Cache interface {
 	PutInt(key string, value int, config *Options) error
 	PutValue(key string, value any, config *Options) error
 
+	// Increment is IncrementBy with a delta of one.
+	Increment(key string, config *Options) (int64, error)
+	// IncrementBy atomically adds delta to the counter at key and returns the
+	// new value. A missing counter starts at zero and is created with the
+	// expiration of config, while an existing one keeps its expiration.
+	IncrementBy(key string, delta int64, config *Options) (int64, error)
+	// SetNX stores the value only if key is missing and reports whether it
+	// did.
+	SetNX(key string, value any, config *Options) (bool, error)
+	// CompareAndSwap atomically replaces the value of key with new if its
+	// current value, as returned by Get, is old. A nil old only matches a
+	// missing key.
+	CompareAndSwap(key string, old []byte, new []byte, config *Options) (bool, error)
+
+	// TTL returns how long until key expires, or zero if it does not expire.
+	TTL(key string) (time.Duration, error)
+	// Expire sets the expiration of an existing key. A non-positive duration
+	// makes it persistent.
+	Expire(key string, expiration time.Duration) error
+
 	Delete(key string) error
+	// InvalidateTags deletes every key written with any of the tags since the
+	// tag was last invalidated.
+	InvalidateTags(tags ...string) error
 
 	Flush() error
 	Close() error
 }
 
+// Pruner is implemented by engines that need expired keys removed
+// periodically.
+type Pruner interface {
+	PruneExpiredKeys() error
+}
+
+// GarbageCollector is implemented by engines that need their storage
+// compacted periodically.
+type GarbageCollector interface {
+	RunGC() error
+}
+
+// TagInvalidator is implemented by engines that can report the keys deleted by
+// InvalidateTags, so that a TieredCache drops only those keys from the local
+// tiers.
+type TagInvalidator interface {
+	InvalidateTaggedKeys(tags ...string) ([]string, error)
+}
+
 type Options struct {
 	Expiration time.Duration
+	// Tags are the tags the Put methods write the key with, such as
+	// "brand:3", so that it can be deleted with InvalidateTags.
+	Tags []string
+}
+
+func (o *Options) tags() []string {
+	if o == nil {
+		return nil
+	}
+	return o.Tags
 }
 
 var ErrKeyNotFound = errors.New("cache key not found")
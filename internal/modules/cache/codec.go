@@ -0,0 +1,124 @@
+package cache
+
+import (
+	"bytes"
+	"encoding/gob"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+// ErrUndecodable is wrapped by the ErrKeyNotFound returned for entries that
+// cannot be decoded, such as entries written with a struct definition that
+// has since changed.
+var ErrUndecodable = errors.New("cache value cannot be decoded")
+
+// Codec serializes the values of cache entries. Every entry starts with the ID
+// of the codec that wrote it, so that entries written by another codec, or by
+// another service, are still read with the right one.
+type Codec interface {
+	ID() byte
+	Name() string
+	Marshal(value any) ([]byte, error)
+	Unmarshal(data []byte, ptr any) error
+}
+
+// DefaultCodec is used by engines that are not given a codec.
+var DefaultCodec Codec = GobCodec{}
+
+var codecs = map[byte]Codec{}
+
+func init() {
+	for _, codec := range []Codec{GobCodec{}, JSONCodec{}, MsgpackCodec{}} {
+		codecs[codec.ID()] = codec
+	}
+}
+
+// CodecByName returns the codec of the name, or DefaultCodec for an empty
+// name.
+func CodecByName(name string) (Codec, error) {
+	if name == "" {
+		return DefaultCodec, nil
+	}
+	for _, codec := range codecs {
+		if codec.Name() == name {
+			return codec, nil
+		}
+	}
+	return nil, fmt.Errorf("unsupported cache codec: %s", name)
+}
+
+// GobCodec only works between Go programs sharing the type definitions.
+type GobCodec struct{}
+
+func (GobCodec) ID() byte     { return 1 }
+func (GobCodec) Name() string { return "gob" }
+
+func (GobCodec) Marshal(value any) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(value); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (GobCodec) Unmarshal(data []byte, ptr any) error {
+	return gob.NewDecoder(bytes.NewReader(data)).Decode(ptr)
+}
+
+type JSONCodec struct{}
+
+func (JSONCodec) ID() byte     { return 2 }
+func (JSONCodec) Name() string { return "json" }
+
+func (JSONCodec) Marshal(value any) ([]byte, error) {
+	return json.Marshal(value)
+}
+
+func (JSONCodec) Unmarshal(data []byte, ptr any) error {
+	return json.Unmarshal(data, ptr)
+}
+
+type MsgpackCodec struct{}
+
+func (MsgpackCodec) ID() byte     { return 3 }
+func (MsgpackCodec) Name() string { return "msgpack" }
+
+func (MsgpackCodec) Marshal(value any) ([]byte, error) {
+	return msgpack.Marshal(value)
+}
+
+func (MsgpackCodec) Unmarshal(data []byte, ptr any) error {
+	return msgpack.Unmarshal(data, ptr)
+}
+
+// encodeEntry returns the stored form of value.
+func encodeEntry(codec Codec, value any) ([]byte, error) {
+	if codec == nil {
+		codec = DefaultCodec
+	}
+	data, err := codec.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte{codec.ID()}, data...), nil
+}
+
+// decodeEntry decodes a stored entry into ptr with the codec that wrote it.
+// It returns an error wrapping both ErrKeyNotFound and ErrUndecodable when it
+// cannot, so that callers treat the entry as missing.
+func decodeEntry(data []byte, ptr any) error {
+	if len(data) == 0 {
+		return fmt.Errorf("%w: %w: empty entry", ErrKeyNotFound, ErrUndecodable)
+	}
+	codec, ok := codecs[data[0]]
+	if !ok {
+		return fmt.Errorf("%w: %w: unknown codec %d", ErrKeyNotFound, ErrUndecodable, data[0])
+	}
+	if err := codec.Unmarshal(data[1:], ptr); err != nil {
+		return fmt.Errorf("%w: %w: %s: %v", ErrKeyNotFound, ErrUndecodable, codec.Name(), err)
+	}
+	return nil
+}
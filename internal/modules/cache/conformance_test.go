@@ -0,0 +1,386 @@
+package cache
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/dgraph-io/badger/v3"
+	"github.com/go-redis/redis/v8"
+)
+
+// engines returns a constructor of an empty cache for every engine and codec,
+// so that every engine is held to the same behaviour.
+func engines() map[string]func(t *testing.T) Cache {
+	constructors := map[string]func(t *testing.T) Cache{
+		"tiered": func(t *testing.T) Cache {
+			return NewTieredCache(NewInMemoryCache(nil), TieredOptions{})
+		},
+	}
+	for _, codec := range codecs {
+		constructors["memory/"+codec.Name()] = func(t *testing.T) Cache {
+			return NewInMemoryCache(codec)
+		}
+		constructors["badger/"+codec.Name()] = func(t *testing.T) Cache {
+			return NewBadgerCache(newBadgerDB(t), codec)
+		}
+		constructors["redis/"+codec.Name()] = func(t *testing.T) Cache {
+			return NewRedisCache(newRedisClient(t), context.Background(), codec)
+		}
+	}
+	return constructors
+}
+
+func newBadgerDB(t *testing.T) *badger.DB {
+	db, err := badger.Open(badger.DefaultOptions("").WithInMemory(true).WithLogger(nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+func newRedisClient(t *testing.T) *redis.Client {
+	server := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	t.Cleanup(func() { client.Close() })
+	return client
+}
+
+func runConformance(t *testing.T, test func(t *testing.T, c Cache)) {
+	for name, newCache := range engines() {
+		t.Run(name, func(t *testing.T) {
+			test(t, newCache(t))
+		})
+	}
+}
+
+func assertTTL(t *testing.T, c Cache, key string, min time.Duration, max time.Duration) {
+	t.Helper()
+	ttl, err := c.TTL(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ttl < min || ttl > max {
+		t.Errorf("expected the TTL of %s between %s and %s, got %s", key, min, max, ttl)
+	}
+}
+
+func TestPutAndGet(t *testing.T) {
+	type value struct {
+		Name  string
+		Count int
+	}
+
+	runConformance(t, func(t *testing.T, c Cache) {
+		if err := c.PutString("string", "hello", nil); err != nil {
+			t.Fatal(err)
+		}
+		if s, err := c.GetString("string"); err != nil || s != "hello" {
+			t.Errorf("expected hello, got %q %v", s, err)
+		}
+
+		if err := c.PutInt("int", 42, nil); err != nil {
+			t.Fatal(err)
+		}
+		if i, err := c.GetInt("int"); err != nil || i != 42 {
+			t.Errorf("expected 42, got %d %v", i, err)
+		}
+
+		if err := c.Put("bytes", []byte("raw"), nil); err != nil {
+			t.Fatal(err)
+		}
+		if b, err := c.Get("bytes"); err != nil || string(b) != "raw" {
+			t.Errorf("expected raw, got %q %v", b, err)
+		}
+
+		if err := c.PutValue("value", value{Name: "a", Count: 1}, nil); err != nil {
+			t.Fatal(err)
+		}
+		var v value
+		if _, err := c.GetValue("value", &v); err != nil || v != (value{Name: "a", Count: 1}) {
+			t.Errorf("expected the stored struct, got %+v %v", v, err)
+		}
+
+		if _, err := c.GetString("missing"); !errors.Is(err, ErrKeyNotFound) {
+			t.Errorf("expected ErrKeyNotFound, got %v", err)
+		}
+	})
+}
+
+func TestHasAndDelete(t *testing.T) {
+	runConformance(t, func(t *testing.T, c Cache) {
+		if err := c.PutString("key", "value", nil); err != nil {
+			t.Fatal(err)
+		}
+		if has, err := c.Has("key"); err != nil || !has {
+			t.Fatalf("expected key to exist, got %v %v", has, err)
+		}
+
+		if err := c.Delete("key"); err != nil {
+			t.Fatal(err)
+		}
+		if has, err := c.Has("key"); err != nil || has {
+			t.Fatalf("expected key to be deleted, got %v %v", has, err)
+		}
+		if err := c.Delete("key"); err != nil {
+			t.Errorf("expected deleting a missing key to succeed, got %v", err)
+		}
+	})
+}
+
+func TestGetKeysWithPrefix(t *testing.T) {
+	runConformance(t, func(t *testing.T, c Cache) {
+		for _, key := range []string{"a:1", "a:2", "b:1"} {
+			if err := c.PutString(key, key, nil); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		keys, err := c.GetKeysWithPrefix("a:")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(keys) != 2 {
+			t.Errorf("expected 2 keys, got %v", keys)
+		}
+	})
+}
+
+func TestIncrement(t *testing.T) {
+	runConformance(t, func(t *testing.T, c Cache) {
+		n, err := c.Increment("counter", &Options{Expiration: time.Hour})
+		if err != nil || n != 1 {
+			t.Fatalf("expected 1, got %d %v", n, err)
+		}
+		n, err = c.IncrementBy("counter", 5, &Options{Expiration: time.Second})
+		if err != nil || n != 6 {
+			t.Fatalf("expected 6, got %d %v", n, err)
+		}
+		n, err = c.IncrementBy("counter", -2, nil)
+		if err != nil || n != 4 {
+			t.Fatalf("expected 4, got %d %v", n, err)
+		}
+
+		if i, err := c.GetInt("counter"); err != nil || i != 4 {
+			t.Errorf("expected GetInt to read 4, got %d %v", i, err)
+		}
+		//	The expiration is only set when the counter is created.
+		assertTTL(t, c, "counter", 59*time.Minute, time.Hour)
+	})
+}
+
+func TestConcurrentIncrement(t *testing.T) {
+	runConformance(t, func(t *testing.T, c Cache) {
+		var wg sync.WaitGroup
+		for i := 0; i < 50; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				if _, err := c.Increment("counter", nil); err != nil {
+					t.Error(err)
+				}
+			}()
+		}
+		wg.Wait()
+
+		if i, err := c.GetInt("counter"); err != nil || i != 50 {
+			t.Errorf("expected 50, got %d %v", i, err)
+		}
+	})
+}
+
+func TestSetNX(t *testing.T) {
+	runConformance(t, func(t *testing.T, c Cache) {
+		if set, err := c.SetNX("key", "first", nil); err != nil || !set {
+			t.Fatalf("expected the missing key to be set, got %v %v", set, err)
+		}
+		if set, err := c.SetNX("key", "second", nil); err != nil || set {
+			t.Fatalf("expected the existing key not to be set, got %v %v", set, err)
+		}
+		if s, err := c.GetString("key"); err != nil || s != "first" {
+			t.Errorf("expected first, got %q %v", s, err)
+		}
+	})
+}
+
+func TestCompareAndSwap(t *testing.T) {
+	runConformance(t, func(t *testing.T, c Cache) {
+		if ok, err := c.CompareAndSwap("key", nil, []byte("a"), nil); err != nil || !ok {
+			t.Fatalf("expected the missing key to be swapped, got %v %v", ok, err)
+		}
+		if ok, err := c.CompareAndSwap("key", nil, []byte("b"), nil); err != nil || ok {
+			t.Fatalf("expected a nil old not to match an existing key, got %v %v", ok, err)
+		}
+		if ok, err := c.CompareAndSwap("key", []byte("x"), []byte("b"), nil); err != nil || ok {
+			t.Fatalf("expected a wrong old not to match, got %v %v", ok, err)
+		}
+		if ok, err := c.CompareAndSwap("key", []byte("a"), []byte("b"), &Options{Expiration: time.Hour}); err != nil || !ok {
+			t.Fatalf("expected the current old to match, got %v %v", ok, err)
+		}
+
+		if b, err := c.Get("key"); err != nil || string(b) != "b" {
+			t.Errorf("expected b, got %q %v", b, err)
+		}
+		assertTTL(t, c, "key", 59*time.Minute, time.Hour)
+	})
+}
+
+func TestTTLAndExpire(t *testing.T) {
+	runConformance(t, func(t *testing.T, c Cache) {
+		if _, err := c.TTL("missing"); !errors.Is(err, ErrKeyNotFound) {
+			t.Errorf("expected ErrKeyNotFound, got %v", err)
+		}
+		if err := c.Expire("missing", time.Hour); !errors.Is(err, ErrKeyNotFound) {
+			t.Errorf("expected ErrKeyNotFound, got %v", err)
+		}
+
+		if err := c.PutString("key", "value", nil); err != nil {
+			t.Fatal(err)
+		}
+		assertTTL(t, c, "key", 0, 0)
+
+		if err := c.Expire("key", time.Hour); err != nil {
+			t.Fatal(err)
+		}
+		assertTTL(t, c, "key", 59*time.Minute, time.Hour)
+
+		if err := c.Expire("key", 0); err != nil {
+			t.Fatal(err)
+		}
+		assertTTL(t, c, "key", 0, 0)
+		if s, err := c.GetString("key"); err != nil || s != "value" {
+			t.Errorf("expected Expire to keep the value, got %q %v", s, err)
+		}
+	})
+}
+
+func TestInvalidateTags(t *testing.T) {
+	runConformance(t, func(t *testing.T, c Cache) {
+		puts := map[string][]string{
+			"catalogue:1": {"brand:1", "category:1"},
+			"catalogue:2": {"brand:2", "category:1"},
+			"catalogue:3": {"brand:2"},
+			"untagged":    nil,
+		}
+		for key, tags := range puts {
+			if err := c.PutString(key, key, &Options{Expiration: time.Hour, Tags: tags}); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		if err := c.InvalidateTags("category:1"); err != nil {
+			t.Fatal(err)
+		}
+		for key, expected := range map[string]bool{"catalogue:1": false, "catalogue:2": false, "catalogue:3": true, "untagged": true} {
+			if has, err := c.Has(key); err != nil || has != expected {
+				t.Errorf("expected %s to exist: %v, got %v %v", key, expected, has, err)
+			}
+		}
+
+		//	Invalidated tags only apply to keys written with them afterwards.
+		if err := c.PutString("catalogue:1", "again", &Options{Tags: []string{"brand:1"}}); err != nil {
+			t.Fatal(err)
+		}
+		if err := c.InvalidateTags("category:1", "brand:2"); err != nil {
+			t.Fatal(err)
+		}
+		if has, _ := c.Has("catalogue:1"); !has {
+			t.Error("expected catalogue:1 to survive invalidating a tag it was not rewritten with")
+		}
+		if has, _ := c.Has("catalogue:3"); has {
+			t.Error("expected catalogue:3 to be invalidated with brand:2")
+		}
+		if err := c.InvalidateTags("missing"); err != nil {
+			t.Errorf("expected invalidating an unknown tag to succeed, got %v", err)
+		}
+	})
+}
+
+func TestInvalidateTaggedKeys(t *testing.T) {
+	runConformance(t, func(t *testing.T, c Cache) {
+		invalidator, ok := c.(TagInvalidator)
+		if !ok {
+			t.Skip("engine does not report invalidated keys")
+		}
+		for _, key := range []string{"catalogue:1", "catalogue:2"} {
+			if err := c.PutString(key, key, &Options{Tags: []string{"brand:1"}}); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := c.PutString("catalogue:3", "catalogue:3", &Options{Tags: []string{"brand:2"}}); err != nil {
+			t.Fatal(err)
+		}
+
+		keys, err := invalidator.InvalidateTaggedKeys("brand:1")
+		if err != nil {
+			t.Fatal(err)
+		}
+		slices.Sort(keys)
+		if !slices.Equal(keys, []string{"catalogue:1", "catalogue:2"}) {
+			t.Errorf("expected the keys of brand:1, got %v", keys)
+		}
+		if keys, err := invalidator.InvalidateTaggedKeys("brand:1"); err != nil || len(keys) != 0 {
+			t.Errorf("expected no keys once the tag is invalidated, got %v %v", keys, err)
+		}
+	})
+}
+
+func TestUndecodableEntriesAreMisses(t *testing.T) {
+	runConformance(t, func(t *testing.T, c Cache) {
+		if err := c.PutValue("key", struct{ Name string }{"a"}, nil); err != nil {
+			t.Fatal(err)
+		}
+
+		var i int
+		_, err := c.GetValue("key", &i)
+		if !errors.Is(err, ErrKeyNotFound) || !errors.Is(err, ErrUndecodable) {
+			t.Errorf("expected an undecodable miss, got %v", err)
+		}
+	})
+}
+
+func TestEntriesAreReadAcrossCodecs(t *testing.T) {
+	db := newBadgerDB(t)
+	writer := NewBadgerCache(db, JSONCodec{})
+	reader := NewBadgerCache(db, MsgpackCodec{})
+
+	if err := writer.PutString("key", "value", nil); err != nil {
+		t.Fatal(err)
+	}
+	if s, err := reader.GetString("key"); err != nil || s != "value" {
+		t.Errorf("expected value, got %q %v", s, err)
+	}
+
+	//	Values are compared decoded, whichever codec wrote them.
+	if err := writer.Put("bytes", []byte("old"), nil); err != nil {
+		t.Fatal(err)
+	}
+	if ok, err := reader.CompareAndSwap("bytes", []byte("old"), []byte("new"), nil); err != nil || !ok {
+		t.Errorf("expected the value written by another codec to be swapped, got %v %v", ok, err)
+	}
+	if _, err := CodecByName("xml"); err == nil {
+		t.Error("expected an unknown codec to be rejected")
+	}
+}
+
+func TestInMemoryCacheWritesWithCodec(t *testing.T) {
+	for _, codec := range codecs {
+		c := NewInMemoryCache(codec).(*InMemoryCache)
+		if err := c.PutString("key", "value", nil); err != nil {
+			t.Fatal(err)
+		}
+		expected, err := encodeEntry(codec, "value")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if entry, _ := c.entry("key"); entry == nil || !bytes.Equal(entry.Data, expected) {
+			t.Errorf("%s: expected the entry to be written with the codec", codec.Name())
+		}
+	}
+}
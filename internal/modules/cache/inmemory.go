@@ -2,16 +2,18 @@ package cache
 
 import (
 	"bytes"
-	"encoding/gob"
-	"errors"
-	"io"
 	"reflect"
 	"strings"
+	"sync"
 	"time"
 )
 
 type InMemoryCache struct {
+	mu    sync.RWMutex
 	state map[string]*cacheEntry
+	// tags holds the keys written with every tag.
+	tags  map[string]map[string]struct{}
+	codec Codec
 }
 
 type cacheEntry struct {
@@ -19,21 +21,38 @@ type cacheEntry struct {
 	Expiration time.Time
 }
 
-func NewInMemoryCache() Cache {
+func (e *cacheEntry) expired() bool {
+	return !e.Expiration.IsZero() && e.Expiration.Before(time.Now())
+}
+
+// entry returns the entry of the key unless it is missing or expired.
+func (c *InMemoryCache) entry(key string) (*cacheEntry, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	v, ok := c.state[key]
+	if !ok || v.expired() {
+		return nil, false
+	}
+	return v, true
+}
+
+// NewInMemoryCache returns an InMemoryCache writing values with the codec, or
+// with DefaultCodec if it is nil.
+func NewInMemoryCache(codec Codec) Cache {
+	if codec == nil {
+		codec = DefaultCodec
+	}
 	return &InMemoryCache{
 		state: map[string]*cacheEntry{},
+		tags:  map[string]map[string]struct{}{},
+		codec: codec,
 	}
 }
 
 func (c *InMemoryCache) Has(key string) (bool, error) {
-	v, ok := c.state[key]
-	if !ok {
-		return false, nil
-	}
-	if !v.Expiration.IsZero() && v.Expiration.Before(time.Now()) {
-		return false, nil
-	}
-	return true, nil
+	_, ok := c.entry(key)
+	return ok, nil
 }
 
 func (c *InMemoryCache) Get(key string) ([]byte, error) {
@@ -64,26 +83,27 @@ func (c *InMemoryCache) GetInt(key string) (int, error) {
 }
 
 func (c *InMemoryCache) GetValue(key string, ptr any) (any, error) {
-	v, ok := c.state[key]
+	v, ok := c.entry(key)
 	if !ok {
 		return nil, ErrKeyNotFound
 	}
-	if !v.Expiration.IsZero() && v.Expiration.Before(time.Now()) {
-		return nil, ErrKeyNotFound
-	}
 
-	buf := bytes.NewBuffer(v.Data)
-	err := gob.NewDecoder(buf).Decode(ptr)
-	return ptr, err
+	if err := decodeEntry(v.Data, ptr); err != nil {
+		return nil, err
+	}
+	return ptr, nil
 }
 
 func (c *InMemoryCache) GetKeysWithPrefix(prefix string) ([]string, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
 	l := make([]string, 0)
 	for k, v := range c.state {
 		if !strings.HasPrefix(k, prefix) {
 			continue
 		}
-		if !v.Expiration.IsZero() && v.Expiration.Before(time.Now()) {
+		if v.expired() {
 			continue
 		}
 		l = append(l, k)
@@ -106,17 +126,18 @@ func (c *InMemoryCache) GetValuesWithPrefix(prefix string, arrPtr any) (any, err
 		elemType = elemType.Elem()
 	}
 
-	arr := reflect.MakeSlice(reflect.SliceOf(elemType), len(keys), len(keys))
-	for i, k := range keys {
-		v := c.state[k]
+	arr := reflect.MakeSlice(reflect.SliceOf(elemType), 0, len(keys))
+	for _, k := range keys {
+		v, ok := c.entry(k)
+		if !ok {
+			continue
+		}
 
 		ins := reflect.New(elemType)
-		buf := bytes.NewBuffer(v.Data)
-		err = gob.NewDecoder(buf).DecodeValue(ins)
-		if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) {
-			return nil, err
+		if err := decodeEntry(v.Data, ins.Interface()); err != nil {
+			continue
 		}
-		arr.Index(i).Set(ins.Elem())
+		arr = reflect.Append(arr, ins.Elem())
 	}
 
 	baseValue := reflect.ValueOf(arrPtr)
@@ -144,13 +165,14 @@ func (c *InMemoryCache) GetItemsWithPrefix(prefix string, mapPtr any) (any, erro
 
 	m := reflect.MakeMap(reflect.MapOf(keyType, elemType))
 	for _, k := range keys {
-		v := c.state[k]
+		v, ok := c.entry(k)
+		if !ok {
+			continue
+		}
 
 		ins := reflect.New(elemType)
-		buf := bytes.NewBuffer(v.Data)
-		err = gob.NewDecoder(buf).DecodeValue(ins)
-		if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) {
-			return nil, err
+		if err := decodeEntry(v.Data, ins.Interface()); err != nil {
+			continue
 		}
 		m.SetMapIndex(reflect.ValueOf(k), ins.Elem())
 	}
@@ -175,50 +197,184 @@ func (c *InMemoryCache) PutInt(key string, value int, config *Options) error {
 }
 
 func (c *InMemoryCache) PutValue(key string, value any, config *Options) error {
-	var buf bytes.Buffer
-	err := gob.NewEncoder(&buf).Encode(value)
+	data, err := encodeEntry(c.codec, value)
 	if err != nil {
 		return err
 	}
 
-	exp := time.Time{}
-	if config != nil {
-		exp = time.Now().Add(config.Expiration)
-	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
 	c.state[key] = &cacheEntry{
-		Data:       buf.Bytes(),
-		Expiration: exp,
+		Data:       data,
+		Expiration: expirationTime(config),
+	}
+	for _, tag := range config.tags() {
+		if c.tags[tag] == nil {
+			c.tags[tag] = map[string]struct{}{}
+		}
+		c.tags[tag][key] = struct{}{}
 	}
 	return nil
 }
 
-func (c *InMemoryCache) Delete(key string) error {
-	if exist, err := c.Has(key); err != nil {
-		return err
-	} else if !exist {
+func (c *InMemoryCache) Increment(key string, config *Options) (int64, error) {
+	return c.IncrementBy(key, 1, config)
+}
+
+func (c *InMemoryCache) IncrementBy(key string, delta int64, config *Options) (int64, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var n int64
+	exp := expirationTime(config)
+	//	A counter that cannot be decoded starts over like a missing one.
+	if v, ok := c.state[key]; ok && !v.expired() && decodeEntry(v.Data, &n) == nil {
+		exp = v.Expiration
+	}
+
+	n += delta
+	data, err := encodeEntry(c.codec, n)
+	if err != nil {
+		return 0, err
+	}
+	c.state[key] = &cacheEntry{Data: data, Expiration: exp}
+	return n, nil
+}
+
+func (c *InMemoryCache) CompareAndSwap(key string, old []byte, new []byte, config *Options) (bool, error) {
+	data, err := encodeEntry(c.codec, new)
+	if err != nil {
+		return false, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var current []byte
+	v, ok := c.state[key]
+	ok = ok && !v.expired() && decodeEntry(v.Data, &current) == nil
+	if old == nil {
+		if ok {
+			return false, nil
+		}
+	} else if !ok || !bytes.Equal(current, old) {
+		return false, nil
+	}
+
+	c.state[key] = &cacheEntry{Data: data, Expiration: expirationTime(config)}
+	return true, nil
+}
+
+func (c *InMemoryCache) SetNX(key string, value any, config *Options) (bool, error) {
+	data, err := encodeEntry(c.codec, value)
+	if err != nil {
+		return false, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if v, ok := c.state[key]; ok && !v.expired() {
+		return false, nil
+	}
+	c.state[key] = &cacheEntry{Data: data, Expiration: expirationTime(config)}
+	return true, nil
+}
+
+func (c *InMemoryCache) TTL(key string) (time.Duration, error) {
+	v, ok := c.entry(key)
+	if !ok {
+		return 0, ErrKeyNotFound
+	}
+	if v.Expiration.IsZero() {
+		return 0, nil
+	}
+	return time.Until(v.Expiration), nil
+}
+
+func (c *InMemoryCache) Expire(key string, expiration time.Duration) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	v, ok := c.state[key]
+	if !ok || v.expired() {
 		return ErrKeyNotFound
 	}
+	c.state[key] = &cacheEntry{Data: v.Data, Expiration: expirationTime(&Options{Expiration: expiration})}
+	return nil
+}
+
+// expirationTime returns when an entry written with config expires, or the
+// zero time if it does not.
+func expirationTime(config *Options) time.Time {
+	if config == nil || config.Expiration <= 0 {
+		return time.Time{}
+	}
+	return time.Now().Add(config.Expiration)
+}
+
+func (c *InMemoryCache) Delete(key string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
 	delete(c.state, key)
 	return nil
 }
 
+func (c *InMemoryCache) InvalidateTags(tags ...string) error {
+	_, err := c.InvalidateTaggedKeys(tags...)
+	return err
+}
+
+// InvalidateTaggedKeys deletes the keys written with any of the tags and
+// returns them.
+func (c *InMemoryCache) InvalidateTaggedKeys(tags ...string) ([]string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var keys []string
+	for _, tag := range tags {
+		for key := range c.tags[tag] {
+			delete(c.state, key)
+			keys = append(keys, key)
+		}
+		delete(c.tags, tag)
+	}
+	return keys, nil
+}
+
 func (c *InMemoryCache) PruneExpiredKeys() error {
-	var prune []string
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
 	for k, v := range c.state {
-		if !v.Expiration.IsZero() && v.Expiration.Before(time.Now()) {
-			prune = append(prune, k)
+		if v.expired() {
+			delete(c.state, k)
 		}
 	}
-	for _, k := range prune {
-		delete(c.state, k)
+	for tag, keys := range c.tags {
+		for key := range keys {
+			if _, ok := c.state[key]; !ok {
+				delete(keys, key)
+			}
+		}
+		if len(keys) == 0 {
+			delete(c.tags, tag)
+		}
 	}
 	return nil
 }
 
 func (c *InMemoryCache) Flush() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
 	for k := range c.state {
 		delete(c.state, k)
 	}
+	for tag := range c.tags {
+		delete(c.tags, tag)
+	}
 	return nil
 }
 
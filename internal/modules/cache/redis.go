@@ -3,24 +3,83 @@ package cache
 import (
 	"bytes"
 	"context"
-	"encoding/gob"
 	"errors"
 	"github.com/go-redis/redis/v8"
-	"io"
 	"reflect"
+	"strconv"
 	"strings"
 	"time"
 )
 
+// incrementScript increments a counter and sets its expiration only if the
+// counter has none, so that an existing counter keeps its expiration.
+var incrementScript = redis.NewScript(`
+local n = redis.call('INCRBY', KEYS[1], ARGV[1])
+if tonumber(ARGV[2]) > 0 and redis.call('PTTL', KEYS[1]) == -1 then
+	redis.call('PEXPIRE', KEYS[1], ARGV[2])
+end
+return n
+`)
+
+// putTaggedScript sets KEYS[1] to ARGV[1], expiring in ARGV[2] milliseconds or
+// never if zero, and adds it to the tag sets KEYS[2..] in the same step, so
+// that a tagged key is never left out of its tags. Every set lives at least as
+// long as the key.
+var putTaggedScript = redis.NewScript(`
+local ttl = tonumber(ARGV[2])
+if ttl > 0 then
+	redis.call('SET', KEYS[1], ARGV[1], 'PX', ttl)
+else
+	redis.call('SET', KEYS[1], ARGV[1])
+end
+for i = 2, #KEYS do
+	local existed = redis.call('EXISTS', KEYS[i])
+	redis.call('SADD', KEYS[i], KEYS[1])
+	if ttl <= 0 then
+		redis.call('PERSIST', KEYS[i])
+	else
+		local current = redis.call('PTTL', KEYS[i])
+		if existed == 0 or (current >= 0 and current < ttl) then
+			redis.call('PEXPIRE', KEYS[i], ttl)
+		end
+	end
+end
+return 1
+`)
+
+// invalidateTagsScript deletes the members of the tag sets KEYS and the sets,
+// and returns the members.
+var invalidateTagsScript = redis.NewScript(`
+local deleted = {}
+for _, set in ipairs(KEYS) do
+	local keys = redis.call('SMEMBERS', set)
+	for i = 1, #keys, 1000 do
+		redis.call('DEL', unpack(keys, i, math.min(i + 999, #keys)))
+	end
+	for _, key in ipairs(keys) do
+		table.insert(deleted, key)
+	end
+	redis.call('DEL', set)
+end
+return deleted
+`)
+
 type RedisCache struct {
 	client *redis.Client
 	ctx    context.Context
+	codec  Codec
 }
 
-func NewRedisCache(client *redis.Client, ctx context.Context) Cache {
+// NewRedisCache returns a RedisCache writing values with the codec, or with
+// DefaultCodec if it is nil.
+func NewRedisCache(client *redis.Client, ctx context.Context, codec Codec) Cache {
+	if codec == nil {
+		codec = DefaultCodec
+	}
 	return &RedisCache{
 		client,
 		ctx,
+		codec,
 	}
 }
 
@@ -50,10 +109,21 @@ func (c RedisCache) GetString(key string) (string, error) {
 	return s, nil
 }
 
+// GetInt also reads counters written by IncrementBy, which redis stores as
+// decimal strings.
 func (c RedisCache) GetInt(key string) (int, error) {
+	v, err := c.client.Get(c.ctx, key).Result()
+	if errors.Is(err, redis.Nil) {
+		return 0, ErrKeyNotFound
+	} else if err != nil {
+		return 0, err
+	}
+	if n, err := strconv.Atoi(v); err == nil {
+		return n, nil
+	}
+
 	var i int
-	_, err := c.GetValue(key, &i)
-	if err != nil {
+	if err := decodeEntry([]byte(v), &i); err != nil {
 		return 0, err
 	}
 	return i, nil
@@ -67,9 +137,10 @@ func (c RedisCache) GetValue(key string, ptr any) (any, error) {
 		return "", err
 	}
 
-	buf := bytes.NewBuffer([]byte(v))
-	err = gob.NewDecoder(buf).Decode(ptr)
-	return ptr, err
+	if err := decodeEntry([]byte(v), ptr); err != nil {
+		return nil, err
+	}
+	return ptr, nil
 }
 
 func (c RedisCache) GetKeysWithPrefix(prefix string) ([]string, error) {
@@ -114,19 +185,17 @@ func (c RedisCache) GetValuesWithPrefix(prefix string, arrPtr any) (any, error)
 		elemType = elemType.Elem()
 	}
 
-	arr := reflect.MakeSlice(reflect.SliceOf(elemType), len(keys), len(keys))
-	for i, r := range res {
+	arr := reflect.MakeSlice(reflect.SliceOf(elemType), 0, len(keys))
+	for _, r := range res {
 		cmd := r.(*redis.StringCmd)
 		if cmd.Err() != nil {
 			continue
 		}
 		ins := reflect.New(elemType)
-		buf := bytes.NewBuffer([]byte(cmd.Val()))
-		err = gob.NewDecoder(buf).DecodeValue(ins)
-		if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) {
-			return nil, err
+		if err := decodeEntry([]byte(cmd.Val()), ins.Interface()); err != nil {
+			continue
 		}
-		arr.Index(i).Set(ins.Elem())
+		arr = reflect.Append(arr, ins.Elem())
 	}
 
 	baseValue := reflect.ValueOf(arrPtr)
@@ -170,10 +239,8 @@ func (c RedisCache) GetItemsWithPrefix(prefix string, mapPtr any) (any, error) {
 
 		key := keys[i]
 		ins := reflect.New(elemType)
-		buf := bytes.NewBuffer([]byte(cmd.Val()))
-		err = gob.NewDecoder(buf).DecodeValue(ins)
-		if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) {
-			return nil, err
+		if err := decodeEntry([]byte(cmd.Val()), ins.Interface()); err != nil {
+			continue
 		}
 		m.SetMapIndex(reflect.ValueOf(key), ins.Elem())
 	}
@@ -198,8 +265,7 @@ func (c RedisCache) PutInt(key string, value int, config *Options) error {
 }
 
 func (c RedisCache) PutValue(key string, value any, config *Options) error {
-	var buf bytes.Buffer
-	err := gob.NewEncoder(&buf).Encode(value)
+	data, err := encodeEntry(c.codec, value)
 	if err != nil {
 		return err
 	}
@@ -208,10 +274,146 @@ func (c RedisCache) PutValue(key string, value any, config *Options) error {
 	if config != nil {
 		exp = config.Expiration
 	}
-	_, err = c.client.Set(c.ctx, key, buf.String(), exp).Result()
+	tags := config.tags()
+	if len(tags) == 0 {
+		return c.client.Set(c.ctx, key, data, exp).Err()
+	}
+
+	keys := make([]string, 0, len(tags)+1)
+	keys = append(keys, key)
+	for _, tag := range tags {
+		keys = append(keys, redisTagKey(tag))
+	}
+	return putTaggedScript.Run(c.ctx, c.client, keys, data, exp.Milliseconds()).Err()
+}
+
+func redisTagKey(tag string) string {
+	return "cache_tag:" + tag
+}
+
+func (c RedisCache) InvalidateTags(tags ...string) error {
+	_, err := c.InvalidateTaggedKeys(tags...)
 	return err
 }
 
+// InvalidateTaggedKeys deletes the keys written with any of the tags and
+// returns them.
+func (c RedisCache) InvalidateTaggedKeys(tags ...string) ([]string, error) {
+	if len(tags) == 0 {
+		return nil, nil
+	}
+	sets := make([]string, len(tags))
+	for i, tag := range tags {
+		sets[i] = redisTagKey(tag)
+	}
+	return invalidateTagsScript.Run(c.ctx, c.client, sets).StringSlice()
+}
+
+func (c RedisCache) Increment(key string, config *Options) (int64, error) {
+	return c.IncrementBy(key, 1, config)
+}
+
+func (c RedisCache) IncrementBy(key string, delta int64, config *Options) (int64, error) {
+	exp := int64(0)
+	if config != nil {
+		exp = config.Expiration.Milliseconds()
+	}
+	return incrementScript.Run(c.ctx, c.client, []string{key}, delta, exp).Int64()
+}
+
+func (c RedisCache) SetNX(key string, value any, config *Options) (bool, error) {
+	data, err := encodeEntry(c.codec, value)
+	if err != nil {
+		return false, err
+	}
+	exp := time.Duration(0)
+	if config != nil {
+		exp = config.Expiration
+	}
+	return c.client.SetNX(c.ctx, key, data, exp).Result()
+}
+
+func (c RedisCache) CompareAndSwap(key string, old []byte, new []byte, config *Options) (bool, error) {
+	if old == nil {
+		return c.SetNX(key, new, config)
+	}
+
+	data, err := encodeEntry(c.codec, new)
+	if err != nil {
+		return false, err
+	}
+	exp := time.Duration(0)
+	if config != nil {
+		exp = config.Expiration
+	}
+
+	//	The current value is compared decoded, since it may have been written
+	//	by another codec, so the swap is guarded by WATCH instead of a script.
+	swapped := false
+	err = c.client.Watch(c.ctx, func(tx *redis.Tx) error {
+		v, err := tx.Get(c.ctx, key).Bytes()
+		if errors.Is(err, redis.Nil) {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		var current []byte
+		if err := decodeEntry(v, &current); err != nil || !bytes.Equal(current, old) {
+			return nil
+		}
+
+		_, err = tx.TxPipelined(c.ctx, func(pipe redis.Pipeliner) error {
+			pipe.Set(c.ctx, key, data, exp)
+			return nil
+		})
+		swapped = err == nil
+		return err
+	}, key)
+	if errors.Is(err, redis.TxFailedErr) {
+		//	Another client changed the key in the meantime.
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+	return swapped, nil
+}
+
+func (c RedisCache) TTL(key string) (time.Duration, error) {
+	d, err := c.client.PTTL(c.ctx, key).Result()
+	if err != nil {
+		return 0, err
+	}
+	//	PTTL replies -2 for missing keys and -1 for keys without expiration,
+	//	which the client returns as is.
+	switch d {
+	case -2:
+		return 0, ErrKeyNotFound
+	case -1:
+		return 0, nil
+	}
+	return d, nil
+}
+
+func (c RedisCache) Expire(key string, expiration time.Duration) error {
+	var exist bool
+	var err error
+	if expiration > 0 {
+		exist, err = c.client.PExpire(c.ctx, key, expiration).Result()
+	} else {
+		//	PERSIST also replies 0 for keys without expiration.
+		if _, err = c.client.Persist(c.ctx, key).Result(); err == nil {
+			exist, err = c.Has(key)
+		}
+	}
+	if err != nil {
+		return err
+	}
+	if !exist {
+		return ErrKeyNotFound
+	}
+	return nil
+}
+
 func (c RedisCache) Delete(key string) error {
 	res := c.client.Del(c.ctx, key)
 	if res.Err() != nil && !errors.Is(res.Err(), redis.Nil) {
@@ -0,0 +1,445 @@
+package cache
+
+import (
+	"bytes"
+	"container/list"
+	"encoding/gob"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/oklog/ulid/v2"
+	"golang.org/x/sync/singleflight"
+)
+
+// Invalidation tells the other instances to drop keys from their local tier.
+type Invalidation struct {
+	// Origin is the instance that changed the keys.
+	Origin string
+	Keys   []string
+	// Flush drops every entry of the local tier.
+	Flush bool
+}
+
+type TieredOptions struct {
+	// Size is the maximum number of entries of the local tier.
+	Size int
+	// TTL is how long an entry is kept in the local tier at most, which bounds
+	// how stale it can be if an invalidation is missed.
+	TTL time.Duration
+	// Bypass are the key prefixes that are never kept in the local tier, such
+	// as counters that change on every request.
+	Bypass []string
+}
+
+var DefaultTieredOptions = TieredOptions{
+	Size:   10000,
+	TTL:    time.Minute,
+	Bypass: []string{"rl:", "lockout:", "auth:"},
+}
+
+// TieredCache keeps recently read values in a bounded in-process LRU in front
+// of another engine. Concurrent misses of a key are coalesced into one read of
+// the engine. Writes go to the engine and drop the key from the local tier of
+// every instance through Broadcast.
+type TieredCache struct {
+	l2      Cache
+	options TieredOptions
+	origin  string
+
+	mu      sync.Mutex
+	items   map[string]*list.Element
+	order   *list.List
+	version uint64
+
+	group singleflight.Group
+
+	// Broadcast publishes invalidations to the other instances. Without it
+	// the local tiers of other instances only expire by TTL.
+	Broadcast func(Invalidation) error
+	// Log reports invalidations that could not be broadcast. It is optional.
+	Log func(message string)
+}
+
+type tieredEntry struct {
+	key        string
+	data       []byte
+	expiration time.Time
+}
+
+// NewTieredCache returns a TieredCache in front of l2, using
+// DefaultTieredOptions for every option left empty.
+func NewTieredCache(l2 Cache, options TieredOptions) *TieredCache {
+	if options.Size <= 0 {
+		options.Size = DefaultTieredOptions.Size
+	}
+	if options.TTL <= 0 {
+		options.TTL = DefaultTieredOptions.TTL
+	}
+	if options.Bypass == nil {
+		options.Bypass = DefaultTieredOptions.Bypass
+	}
+	return &TieredCache{
+		l2:      l2,
+		options: options,
+		origin:  ulid.Make().String(),
+		items:   map[string]*list.Element{},
+		order:   list.New(),
+	}
+}
+
+func (c *TieredCache) bypassed(key string) bool {
+	for _, prefix := range c.options.Bypass {
+		if strings.HasPrefix(key, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+func (c *TieredCache) lookup(key string) ([]byte, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	el, ok := c.items[key]
+	if !ok {
+		return nil, false
+	}
+	entry := el.Value.(*tieredEntry)
+	if entry.expiration.Before(time.Now()) {
+		c.order.Remove(el)
+		delete(c.items, key)
+		return nil, false
+	}
+	c.order.MoveToFront(el)
+	return entry.data, true
+}
+
+// store keeps the value in the local tier unless the local tier has been
+// invalidated since version was read, in which case the value may be stale.
+func (c *TieredCache) store(key string, data []byte, ttl time.Duration, version uint64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.version != version {
+		return
+	}
+	entry := &tieredEntry{key: key, data: data, expiration: time.Now().Add(ttl)}
+	if el, ok := c.items[key]; ok {
+		el.Value = entry
+		c.order.MoveToFront(el)
+		return
+	}
+	c.items[key] = c.order.PushFront(entry)
+	for c.order.Len() > c.options.Size {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.items, oldest.Value.(*tieredEntry).key)
+	}
+}
+
+// get decodes the value of key into ptr from the local tier, or from fetch on
+// a miss. Concurrent misses of the same key and type share one fetch.
+func (c *TieredCache) get(key string, ptr any, fetch func() (any, error)) error {
+	if c.bypassed(key) {
+		value, err := fetch()
+		if err != nil {
+			return err
+		}
+		reflect.ValueOf(ptr).Elem().Set(reflect.ValueOf(value))
+		return nil
+	}
+
+	if data, ok := c.lookup(key); ok {
+		if err := gob.NewDecoder(bytes.NewReader(data)).Decode(ptr); err == nil {
+			return nil
+		}
+		//	The entry was read as another type; read the engine instead.
+	}
+
+	data, err, _ := c.group.Do(key+"\x00"+reflect.TypeOf(ptr).String(), func() (any, error) {
+		c.mu.Lock()
+		version := c.version
+		c.mu.Unlock()
+
+		value, err := fetch()
+		if err != nil {
+			return nil, err
+		}
+		//	The local tier never leaves the process, so it keeps gob.
+		data, err := GobCodec{}.Marshal(value)
+		if err != nil {
+			return nil, err
+		}
+
+		ttl := c.options.TTL
+		if remaining, err := c.l2.TTL(key); err != nil {
+			return data, nil
+		} else if remaining > 0 {
+			ttl = min(ttl, remaining)
+		}
+		c.store(key, data, ttl, version)
+		return data, nil
+	})
+	if err != nil {
+		return err
+	}
+	return gob.NewDecoder(bytes.NewReader(data.([]byte))).Decode(ptr)
+}
+
+// InvalidateLocal drops keys from the local tier without broadcasting.
+func (c *TieredCache) InvalidateLocal(inv Invalidation) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.version++
+	if inv.Flush {
+		c.items = map[string]*list.Element{}
+		c.order.Init()
+		return
+	}
+	for _, key := range inv.Keys {
+		if el, ok := c.items[key]; ok {
+			c.order.Remove(el)
+			delete(c.items, key)
+		}
+	}
+}
+
+// HandleInvalidation applies an invalidation broadcast by another instance.
+func (c *TieredCache) HandleInvalidation(inv Invalidation) {
+	if inv.Origin == c.origin {
+		return
+	}
+	c.InvalidateLocal(inv)
+}
+
+// invalidate drops the keys from the local tier and broadcasts it. It is
+// called once the engine has been written, so a failed broadcast is logged
+// rather than returned: the write stands and the other instances catch up
+// when their entries expire.
+func (c *TieredCache) invalidate(inv Invalidation) {
+	inv.Origin = c.origin
+	c.InvalidateLocal(inv)
+	if c.Broadcast == nil {
+		return
+	}
+	if err := c.Broadcast(inv); err != nil && c.Log != nil {
+		c.Log(fmt.Sprintf("[TieredCache.invalidate] broadcast of %v: %v", inv.Keys, err))
+	}
+}
+
+func (c *TieredCache) invalidateKey(key string) {
+	if c.bypassed(key) {
+		return
+	}
+	c.invalidate(Invalidation{Keys: []string{key}})
+}
+
+func (c *TieredCache) Has(key string) (bool, error) {
+	if !c.bypassed(key) {
+		if _, ok := c.lookup(key); ok {
+			return true, nil
+		}
+	}
+	return c.l2.Has(key)
+}
+
+func (c *TieredCache) Get(key string) ([]byte, error) {
+	var b []byte
+	err := c.get(key, &b, func() (any, error) {
+		return c.l2.Get(key)
+	})
+	return b, err
+}
+
+func (c *TieredCache) GetString(key string) (string, error) {
+	var s string
+	err := c.get(key, &s, func() (any, error) {
+		return c.l2.GetString(key)
+	})
+	return s, err
+}
+
+func (c *TieredCache) GetInt(key string) (int, error) {
+	var i int
+	err := c.get(key, &i, func() (any, error) {
+		return c.l2.GetInt(key)
+	})
+	return i, err
+}
+
+func (c *TieredCache) GetValue(key string, ptr any) (any, error) {
+	elemType := reflect.TypeOf(ptr).Elem()
+	err := c.get(key, ptr, func() (any, error) {
+		v := reflect.New(elemType)
+		if _, err := c.l2.GetValue(key, v.Interface()); err != nil {
+			return nil, err
+		}
+		return v.Elem().Interface(), nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return ptr, nil
+}
+
+func (c *TieredCache) GetKeysWithPrefix(prefix string) ([]string, error) {
+	return c.l2.GetKeysWithPrefix(prefix)
+}
+
+func (c *TieredCache) GetValuesWithPrefix(prefix string, arrPtr any) (any, error) {
+	return c.l2.GetValuesWithPrefix(prefix, arrPtr)
+}
+
+func (c *TieredCache) GetItemsWithPrefix(prefix string, mapPtr any) (any, error) {
+	return c.l2.GetItemsWithPrefix(prefix, mapPtr)
+}
+
+func (c *TieredCache) Put(key string, value []byte, config *Options) error {
+	if err := c.l2.Put(key, value, config); err != nil {
+		return err
+	}
+	c.invalidateKey(key)
+	return nil
+}
+
+func (c *TieredCache) PutString(key string, value string, config *Options) error {
+	if err := c.l2.PutString(key, value, config); err != nil {
+		return err
+	}
+	c.invalidateKey(key)
+	return nil
+}
+
+func (c *TieredCache) PutInt(key string, value int, config *Options) error {
+	if err := c.l2.PutInt(key, value, config); err != nil {
+		return err
+	}
+	c.invalidateKey(key)
+	return nil
+}
+
+func (c *TieredCache) PutValue(key string, value any, config *Options) error {
+	if err := c.l2.PutValue(key, value, config); err != nil {
+		return err
+	}
+	c.invalidateKey(key)
+	return nil
+}
+
+func (c *TieredCache) Increment(key string, config *Options) (int64, error) {
+	return c.IncrementBy(key, 1, config)
+}
+
+func (c *TieredCache) IncrementBy(key string, delta int64, config *Options) (int64, error) {
+	n, err := c.l2.IncrementBy(key, delta, config)
+	if err != nil {
+		return 0, err
+	}
+	c.invalidateKey(key)
+	return n, nil
+}
+
+func (c *TieredCache) SetNX(key string, value any, config *Options) (bool, error) {
+	set, err := c.l2.SetNX(key, value, config)
+	if err != nil || !set {
+		return set, err
+	}
+	c.invalidateKey(key)
+	return true, nil
+}
+
+func (c *TieredCache) CompareAndSwap(key string, old []byte, new []byte, config *Options) (bool, error) {
+	swapped, err := c.l2.CompareAndSwap(key, old, new, config)
+	if err != nil || !swapped {
+		return swapped, err
+	}
+	c.invalidateKey(key)
+	return true, nil
+}
+
+func (c *TieredCache) TTL(key string) (time.Duration, error) {
+	return c.l2.TTL(key)
+}
+
+func (c *TieredCache) Expire(key string, expiration time.Duration) error {
+	if err := c.l2.Expire(key, expiration); err != nil {
+		return err
+	}
+	c.invalidateKey(key)
+	return nil
+}
+
+func (c *TieredCache) Delete(key string) error {
+	if err := c.l2.Delete(key); err != nil {
+		return err
+	}
+	c.invalidateKey(key)
+	return nil
+}
+
+// InvalidateTags drops the keys written with the tags from the local tier of
+// every instance. The local tier does not know the tags of the entries it
+// loaded, so the keys are taken from the engine, and the whole local tier is
+// dropped if the engine is not a TagInvalidator.
+func (c *TieredCache) InvalidateTags(tags ...string) error {
+	invalidator, ok := c.l2.(TagInvalidator)
+	if !ok {
+		if err := c.l2.InvalidateTags(tags...); err != nil {
+			return err
+		}
+		c.invalidate(Invalidation{Flush: true})
+		return nil
+	}
+
+	keys, err := invalidator.InvalidateTaggedKeys(tags...)
+	if err != nil {
+		return err
+	}
+	if len(keys) > 0 {
+		c.invalidate(Invalidation{Keys: keys})
+	}
+	return nil
+}
+
+// PruneExpiredKeys drops expired entries from the local tier and prunes the
+// engine if it needs pruning.
+func (c *TieredCache) PruneExpiredKeys() error {
+	c.mu.Lock()
+	now := time.Now()
+	for key, el := range c.items {
+		if el.Value.(*tieredEntry).expiration.Before(now) {
+			c.order.Remove(el)
+			delete(c.items, key)
+		}
+	}
+	c.mu.Unlock()
+
+	if pruner, ok := c.l2.(Pruner); ok {
+		return pruner.PruneExpiredKeys()
+	}
+	return nil
+}
+
+func (c *TieredCache) Flush() error {
+	if err := c.l2.Flush(); err != nil {
+		return err
+	}
+	c.invalidate(Invalidation{Flush: true})
+	return nil
+}
+
+func (c *TieredCache) Close() error {
+	return c.l2.Close()
+}
+
+// Engine returns the engine behind c if c is a TieredCache, or c itself.
+func Engine(c Cache) Cache {
+	if tiered, ok := c.(*TieredCache); ok {
+		return tiered.l2
+	}
+	return c
+}
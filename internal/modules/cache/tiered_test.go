@@ -0,0 +1,213 @@
+package cache
+
+import (
+	"errors"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// countingCache counts the reads that reach the engine.
+type countingCache struct {
+	Cache
+	reads atomic.Int32
+	delay time.Duration
+}
+
+func (c *countingCache) GetValue(key string, ptr any) (any, error) {
+	c.reads.Add(1)
+	time.Sleep(c.delay)
+	return c.Cache.GetValue(key, ptr)
+}
+
+func TestTieredCacheServesReadsLocally(t *testing.T) {
+	l2 := &countingCache{Cache: NewInMemoryCache(nil)}
+	c := NewTieredCache(l2, TieredOptions{})
+
+	if err := c.PutValue("key", "value", nil); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 3; i++ {
+		var s string
+		if _, err := c.GetValue("key", &s); err != nil || s != "value" {
+			t.Fatalf("expected value, got %q %v", s, err)
+		}
+	}
+	if n := l2.reads.Load(); n != 1 {
+		t.Errorf("expected 1 read of the engine, got %d", n)
+	}
+
+	if err := c.PutValue("key", "changed", nil); err != nil {
+		t.Fatal(err)
+	}
+	var s string
+	if _, err := c.GetValue("key", &s); err != nil || s != "changed" {
+		t.Errorf("expected a write to drop the local entry, got %q %v", s, err)
+	}
+}
+
+func TestTieredCacheCoalescesMisses(t *testing.T) {
+	l2 := &countingCache{Cache: NewInMemoryCache(nil), delay: 50 * time.Millisecond}
+	c := NewTieredCache(l2, TieredOptions{})
+	if err := l2.PutValue("key", "value", nil); err != nil {
+		t.Fatal(err)
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			var s string
+			if _, err := c.GetValue("key", &s); err != nil || s != "value" {
+				t.Errorf("expected value, got %q %v", s, err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if n := l2.reads.Load(); n != 1 {
+		t.Errorf("expected concurrent misses to read the engine once, got %d", n)
+	}
+}
+
+func TestTieredCacheBroadcastsInvalidations(t *testing.T) {
+	l2 := NewInMemoryCache(nil)
+	a := NewTieredCache(l2, TieredOptions{})
+	b := NewTieredCache(l2, TieredOptions{})
+	a.Broadcast = func(inv Invalidation) error {
+		b.HandleInvalidation(inv)
+		return nil
+	}
+
+	if err := a.PutString("key", "first", nil); err != nil {
+		t.Fatal(err)
+	}
+	if s, _ := b.GetString("key"); s != "first" {
+		t.Fatalf("expected first, got %q", s)
+	}
+
+	if err := a.PutString("key", "second", nil); err != nil {
+		t.Fatal(err)
+	}
+	if s, _ := b.GetString("key"); s != "second" {
+		t.Errorf("expected the other instance to read second, got %q", s)
+	}
+}
+
+func TestTieredCacheEvictsLeastRecentlyUsed(t *testing.T) {
+	l2 := &countingCache{Cache: NewInMemoryCache(nil)}
+	c := NewTieredCache(l2, TieredOptions{Size: 2})
+	for _, key := range []string{"a", "b", "c"} {
+		if err := l2.PutValue(key, key, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var s string
+	for _, key := range []string{"a", "b", "a", "c"} {
+		if _, err := c.GetValue(key, &s); err != nil {
+			t.Fatal(err)
+		}
+	}
+	l2.reads.Store(0)
+
+	//	b was the least recently used entry when c was loaded.
+	for _, key := range []string{"a", "c", "b"} {
+		if _, err := c.GetValue(key, &s); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if n := l2.reads.Load(); n != 1 {
+		t.Errorf("expected only the evicted key to be read, got %d reads", n)
+	}
+}
+
+func TestTieredCacheBypassesAuthKeys(t *testing.T) {
+	l2 := NewInMemoryCache(nil)
+	a := NewTieredCache(l2, TieredOptions{})
+	b := NewTieredCache(l2, TieredOptions{})
+
+	if err := a.PutString("auth:revoked_token", "1", nil); err != nil {
+		t.Fatal(err)
+	}
+	if s, _ := b.GetString("auth:revoked_token"); s != "1" {
+		t.Fatalf("expected 1, got %q", s)
+	}
+	//	Without a broadcast, b only sees the change if it does not keep the
+	//	key in process.
+	if err := a.PutString("auth:revoked_token", "2", nil); err != nil {
+		t.Fatal(err)
+	}
+	if s, _ := b.GetString("auth:revoked_token"); s != "2" {
+		t.Errorf("expected auth keys to bypass the local tier, got %q", s)
+	}
+}
+
+func TestTieredCacheLogsFailedBroadcasts(t *testing.T) {
+	l2 := NewInMemoryCache(nil)
+	c := NewTieredCache(l2, TieredOptions{})
+	c.Broadcast = func(Invalidation) error {
+		return errors.New("bus is down")
+	}
+	var logged []string
+	c.Log = func(message string) {
+		logged = append(logged, message)
+	}
+
+	if err := c.PutString("key", "value", nil); err != nil {
+		t.Errorf("expected the write to succeed, got %v", err)
+	}
+	if s, _ := l2.GetString("key"); s != "value" {
+		t.Errorf("expected the engine to be written, got %q", s)
+	}
+	if len(logged) != 1 || !strings.Contains(logged[0], "bus is down") {
+		t.Errorf("expected the failed broadcast to be logged, got %v", logged)
+	}
+}
+
+func TestTieredCacheBroadcastsTaggedKeys(t *testing.T) {
+	l2 := NewInMemoryCache(nil)
+	a := NewTieredCache(l2, TieredOptions{})
+	b := NewTieredCache(l2, TieredOptions{})
+	var broadcast []Invalidation
+	a.Broadcast = func(inv Invalidation) error {
+		broadcast = append(broadcast, inv)
+		b.HandleInvalidation(inv)
+		return nil
+	}
+
+	if err := a.PutString("tagged", "first", &Options{Tags: []string{"brand:1"}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := a.PutString("other", "first", nil); err != nil {
+		t.Fatal(err)
+	}
+	for _, key := range []string{"tagged", "other"} {
+		if s, _ := b.GetString(key); s != "first" {
+			t.Fatalf("expected first, got %q", s)
+		}
+	}
+
+	broadcast = nil
+	if err := a.InvalidateTags("brand:1"); err != nil {
+		t.Fatal(err)
+	}
+	if len(broadcast) != 1 || broadcast[0].Flush || !slices.Equal(broadcast[0].Keys, []string{"tagged"}) {
+		t.Fatalf("expected only the tagged key to be broadcast, got %+v", broadcast)
+	}
+	if _, err := b.GetString("tagged"); !errors.Is(err, ErrKeyNotFound) {
+		t.Errorf("expected the tagged key to be dropped, got %v", err)
+	}
+
+	//	The untagged key is still served by the local tier of b.
+	if err := l2.PutString("other", "second", nil); err != nil {
+		t.Fatal(err)
+	}
+	if s, _ := b.GetString("other"); s != "first" {
+		t.Errorf("expected the local tier of b to keep other, got %q", s)
+	}
+}
@@ -0,0 +1,191 @@
+package keyring
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/lestrrat-go/jwx/v2/jwa"
+	"github.com/lestrrat-go/jwx/v2/jwk"
+	"github.com/lestrrat-go/jwx/v2/jws"
+	"github.com/lestrrat-go/jwx/v2/jwt"
+	"github.com/oklog/ulid/v2"
+)
+
+var (
+	ErrNoActiveKey       = errors.New("keyring: no active signing key")
+	ErrMultipleActiveKey = errors.New("keyring: more than one active signing key")
+	ErrRetiredActiveKey  = errors.New("keyring: active signing key cannot be retired")
+	ErrDuplicateKeyID    = errors.New("keyring: duplicate key id")
+	ErrNotRSAPrivateKey  = errors.New("keyring: signing key must be an RSA private key")
+)
+
+// Key is a single RSA signing key known to the KeyRing.
+type Key struct {
+	ID        string
+	Private   jwk.RSAPrivateKey
+	Public    jwk.RSAPublicKey
+	Active    bool
+	RetiredAt time.Time
+}
+
+// KeyRing holds every signing key the application knows about. Exactly one key
+// is active and used to sign new tokens, while every key that is still
+// published can be used to verify them. A retired key stays published for the
+// retention period after its retirement so that tokens and signed URLs issued
+// before the rotation remain valid until they expire.
+type KeyRing struct {
+	keys      []*Key
+	active    *Key
+	retention time.Duration
+	now       func() time.Time
+}
+
+// ParseKey parses a JWK encoded RSA private key. If the JWK has no key ID, its
+// SHA-256 thumbprint is used so that every key in the ring can be addressed.
+func ParseKey(raw string, active bool, retiredAt time.Time) (*Key, error) {
+	parsed, err := jwk.ParseKey([]byte(raw))
+	if err != nil {
+		return nil, fmt.Errorf("[keyring.ParseKey][ParseKey]%w", err)
+	}
+	private, ok := parsed.(jwk.RSAPrivateKey)
+	if !ok {
+		return nil, ErrNotRSAPrivateKey
+	}
+
+	if private.KeyID() == "" {
+		thumbprint, err := private.Thumbprint(crypto.SHA256)
+		if err != nil {
+			return nil, fmt.Errorf("[keyring.ParseKey][Thumbprint]%w", err)
+		}
+		if err := private.Set(jwk.KeyIDKey, base64.RawURLEncoding.EncodeToString(thumbprint)); err != nil {
+			return nil, fmt.Errorf("[keyring.ParseKey][Set]%w", err)
+		}
+	}
+	if err := private.Set(jwk.AlgorithmKey, jwa.RS256); err != nil {
+		return nil, fmt.Errorf("[keyring.ParseKey][Set]%w", err)
+	}
+	if err := private.Set(jwk.KeyUsageKey, jwk.ForSignature); err != nil {
+		return nil, fmt.Errorf("[keyring.ParseKey][Set]%w", err)
+	}
+
+	public, err := private.PublicKey()
+	if err != nil {
+		return nil, fmt.Errorf("[keyring.ParseKey][PublicKey]%w", err)
+	}
+
+	return &Key{
+		ID:        private.KeyID(),
+		Private:   private,
+		Public:    public.(jwk.RSAPublicKey),
+		Active:    active,
+		RetiredAt: retiredAt,
+	}, nil
+}
+
+// Generate creates a new RSA signing key with a fresh key ID, ready to be
+// added to the configuration.
+func Generate(bits int) (jwk.RSAPrivateKey, error) {
+	raw, err := rsa.GenerateKey(rand.Reader, bits)
+	if err != nil {
+		return nil, fmt.Errorf("[keyring.Generate][GenerateKey]%w", err)
+	}
+
+	key, err := jwk.FromRaw(raw)
+	if err != nil {
+		return nil, fmt.Errorf("[keyring.Generate][FromRaw]%w", err)
+	}
+	private := key.(jwk.RSAPrivateKey)
+
+	if err := private.Set(jwk.KeyIDKey, "sig_"+ulid.Make().String()); err != nil {
+		return nil, fmt.Errorf("[keyring.Generate][Set]%w", err)
+	}
+	if err := private.Set(jwk.AlgorithmKey, jwa.RS256); err != nil {
+		return nil, fmt.Errorf("[keyring.Generate][Set]%w", err)
+	}
+	if err := private.Set(jwk.KeyUsageKey, jwk.ForSignature); err != nil {
+		return nil, fmt.Errorf("[keyring.Generate][Set]%w", err)
+	}
+	return private, nil
+}
+
+// New creates a KeyRing from the provided keys. Exactly one of them must be
+// active and key IDs must be unique.
+func New(keys []*Key, retention time.Duration) (*KeyRing, error) {
+	kr := &KeyRing{
+		retention: retention,
+		now:       time.Now,
+	}
+
+	seen := make(map[string]bool)
+	for _, k := range keys {
+		if seen[k.ID] {
+			return nil, fmt.Errorf("%w: %s", ErrDuplicateKeyID, k.ID)
+		}
+		seen[k.ID] = true
+
+		if k.Active {
+			if kr.active != nil {
+				return nil, ErrMultipleActiveKey
+			}
+			if !k.RetiredAt.IsZero() {
+				return nil, ErrRetiredActiveKey
+			}
+			kr.active = k
+		}
+		kr.keys = append(kr.keys, k)
+	}
+
+	if kr.active == nil {
+		return nil, ErrNoActiveKey
+	}
+	return kr, nil
+}
+
+// Active returns the key used to sign new tokens.
+func (kr *KeyRing) Active() *Key {
+	return kr.active
+}
+
+// SigningKey returns the private part of the active key.
+func (kr *KeyRing) SigningKey() jwk.RSAPrivateKey {
+	return kr.active.Private
+}
+
+// Keys returns every key in the ring, including the ones no longer published.
+func (kr *KeyRing) Keys() []*Key {
+	return kr.keys
+}
+
+// IsPublished reports whether the key can still be used for verification.
+func (kr *KeyRing) IsPublished(k *Key) bool {
+	if k.RetiredAt.IsZero() {
+		return true
+	}
+	return kr.now().Before(k.RetiredAt.Add(kr.retention))
+}
+
+// PublicKeySet returns the public keys that can currently be used to verify
+// tokens, with the active key first.
+func (kr *KeyRing) PublicKeySet() jwk.Set {
+	set := jwk.NewSet()
+	_ = set.AddKey(kr.active.Public)
+	for _, k := range kr.keys {
+		if k == kr.active || !kr.IsPublished(k) {
+			continue
+		}
+		_ = set.AddKey(k.Public)
+	}
+	return set
+}
+
+// VerifyOption returns the parse option that verifies a token against every
+// published key. Tokens without a key ID are tried against all of them, which
+// keeps tokens signed before key IDs were introduced working.
+func (kr *KeyRing) VerifyOption() jwt.ParseOption {
+	return jwt.WithKeySet(kr.PublicKeySet(), jws.WithRequireKid(false))
+}
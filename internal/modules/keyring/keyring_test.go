@@ -0,0 +1,140 @@
+package keyring
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/lestrrat-go/jwx/v2/jwa"
+	"github.com/lestrrat-go/jwx/v2/jwk"
+	"github.com/lestrrat-go/jwx/v2/jwt"
+)
+
+func newTestKey(t *testing.T, active bool, retiredAt time.Time) *Key {
+	t.Helper()
+	private, err := Generate(1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+	raw, err := json.Marshal(private)
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, err := ParseKey(string(raw), active, retiredAt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key
+}
+
+func signWith(t *testing.T, key jwk.RSAPrivateKey) []byte {
+	t.Helper()
+	token, err := jwt.NewBuilder().Subject("admins:test").Expiration(time.Now().Add(time.Hour)).Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	signed, err := jwt.Sign(token, jwt.WithKey(jwa.RS256, key))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signed
+}
+
+func TestNew(t *testing.T) {
+	t.Run("requires an active key", func(t *testing.T) {
+		_, err := New([]*Key{newTestKey(t, false, time.Time{})}, time.Hour)
+		if !errors.Is(err, ErrNoActiveKey) {
+			t.Errorf("want %v; got %v", ErrNoActiveKey, err)
+		}
+	})
+
+	t.Run("rejects more than one active key", func(t *testing.T) {
+		_, err := New([]*Key{newTestKey(t, true, time.Time{}), newTestKey(t, true, time.Time{})}, time.Hour)
+		if !errors.Is(err, ErrMultipleActiveKey) {
+			t.Errorf("want %v; got %v", ErrMultipleActiveKey, err)
+		}
+	})
+
+	t.Run("rejects duplicate key ids", func(t *testing.T) {
+		key := newTestKey(t, true, time.Time{})
+		_, err := New([]*Key{key, key}, time.Hour)
+		if !errors.Is(err, ErrDuplicateKeyID) {
+			t.Errorf("want %v; got %v", ErrDuplicateKeyID, err)
+		}
+	})
+}
+
+func TestParseKey(t *testing.T) {
+	t.Run("assigns a thumbprint key id when none is provided", func(t *testing.T) {
+		private, err := Generate(1024)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_ = private.Remove(jwk.KeyIDKey)
+		raw, _ := json.Marshal(private)
+
+		key, err := ParseKey(string(raw), true, time.Time{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if key.ID == "" || key.Public.KeyID() != key.ID {
+			t.Errorf("want matching non-empty key id; got %q and %q", key.ID, key.Public.KeyID())
+		}
+	})
+}
+
+func TestKeyRing(t *testing.T) {
+	active := newTestKey(t, true, time.Time{})
+	retiring := newTestKey(t, false, time.Now().Add(-time.Hour))
+	expired := newTestKey(t, false, time.Now().Add(-48*time.Hour))
+
+	kr, err := New([]*Key{retiring, active, expired}, 24*time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("signs with the active key", func(t *testing.T) {
+		if kr.SigningKey().KeyID() != active.ID {
+			t.Errorf("want %v; got %v", active.ID, kr.SigningKey().KeyID())
+		}
+	})
+
+	t.Run("publishes keys within the retention period", func(t *testing.T) {
+		set := kr.PublicKeySet()
+		if set.Len() != 2 {
+			t.Fatalf("want %v; got %v", 2, set.Len())
+		}
+		if _, ok := set.LookupKeyID(retiring.ID); !ok {
+			t.Errorf("want retiring key %v to be published", retiring.ID)
+		}
+		if _, ok := set.LookupKeyID(expired.ID); ok {
+			t.Errorf("want expired key %v not to be published", expired.ID)
+		}
+	})
+
+	t.Run("verifies tokens signed by any published key", func(t *testing.T) {
+		for _, k := range []*Key{active, retiring} {
+			if _, err := jwt.Parse(signWith(t, k.Private), kr.VerifyOption()); err != nil {
+				t.Errorf("want %v; got %v", nil, err)
+			}
+		}
+	})
+
+	t.Run("rejects tokens signed by a key past its retention", func(t *testing.T) {
+		if _, err := jwt.Parse(signWith(t, expired.Private), kr.VerifyOption()); err == nil {
+			t.Errorf("want error; got %v", err)
+		}
+	})
+
+	t.Run("verifies tokens without a key id", func(t *testing.T) {
+		bare, err := active.Private.Clone()
+		if err != nil {
+			t.Fatal(err)
+		}
+		_ = bare.Remove(jwk.KeyIDKey)
+		if _, err := jwt.Parse(signWith(t, bare.(jwk.RSAPrivateKey)), kr.VerifyOption()); err != nil {
+			t.Errorf("want %v; got %v", nil, err)
+		}
+	})
+}
@@ -0,0 +1,185 @@
+// Package lockout counts failed login attempts per key and locks a key out
+// once too many attempts fail. Every lockout of a key doubles the lockout
+// duration until MaxDuration is reached.
+package lockout
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"be20250107/internal/modules/cache"
+	"be20250107/internal/modules/ratelimiter"
+)
+
+// levelTTL is how long the number of previous lockouts of a key is remembered.
+const levelTTL = 24 * time.Hour
+
+// LockedError is returned for a key that is locked out. It wraps
+// ratelimiter.ErrRateLimited so that it is answered with 429.
+type LockedError struct {
+	Key   string
+	Until time.Time
+}
+
+func (e *LockedError) Error() string {
+	return fmt.Sprintf("%s is locked out until %s", e.Key, e.Until.Format(time.RFC3339))
+}
+
+func (e *LockedError) Unwrap() error {
+	return ratelimiter.ErrRateLimited
+}
+
+// RetryAfter returns how long until the lockout ends.
+func (e *LockedError) RetryAfter() time.Duration {
+	d := time.Until(e.Until)
+	if d < 0 {
+		return 0
+	}
+	return d
+}
+
+type Options struct {
+	// MaxAttempts is the number of failures within Window that locks a key.
+	MaxAttempts int
+	Window      time.Duration
+	// Duration is the length of the first lockout.
+	Duration    time.Duration
+	MaxDuration time.Duration
+}
+
+var DefaultOptions = Options{
+	MaxAttempts: 5,
+	Window:      15 * time.Minute,
+	Duration:    time.Minute,
+	MaxDuration: time.Hour,
+}
+
+// CredentialKey returns the key of a login credential.
+func CredentialKey(medium string, credential string) string {
+	return medium + ":" + credential
+}
+
+type Guard struct {
+	cache     cache.Cache
+	namespace string
+	options   Options
+}
+
+// New returns a Guard storing its state under the namespace, using
+// DefaultOptions for every option left empty.
+func New(c cache.Cache, namespace string, options Options) *Guard {
+	if options.MaxAttempts <= 0 {
+		options.MaxAttempts = DefaultOptions.MaxAttempts
+	}
+	if options.Window <= 0 {
+		options.Window = DefaultOptions.Window
+	}
+	if options.Duration <= 0 {
+		options.Duration = DefaultOptions.Duration
+	}
+	if options.MaxDuration < options.Duration {
+		options.MaxDuration = max(DefaultOptions.MaxDuration, options.Duration)
+	}
+	return &Guard{cache: c, namespace: namespace, options: options}
+}
+
+func (g *Guard) failuresKey(key string) string {
+	return fmt.Sprintf("lockout:%s:failures_%s", g.namespace, key)
+}
+
+func (g *Guard) untilKey(key string) string {
+	return fmt.Sprintf("lockout:%s:until_%s", g.namespace, key)
+}
+
+func (g *Guard) levelKey(key string) string {
+	return fmt.Sprintf("lockout:%s:level_%s", g.namespace, key)
+}
+
+func (g *Guard) getInt(key string) (int, error) {
+	v, err := g.cache.GetInt(key)
+	if errors.Is(err, cache.ErrKeyNotFound) {
+		return 0, nil
+	}
+	return v, err
+}
+
+func (g *Guard) delete(key string) error {
+	if err := g.cache.Delete(key); err != nil && !errors.Is(err, cache.ErrKeyNotFound) {
+		return err
+	}
+	return nil
+}
+
+// Check returns a *LockedError if the key is locked out.
+func (g *Guard) Check(key string) error {
+	until, err := g.getInt(g.untilKey(key))
+	if err != nil {
+		return fmt.Errorf("[Guard.Check][GetInt]%w", err)
+	}
+	if until == 0 || time.Unix(int64(until), 0).Before(time.Now()) {
+		return nil
+	}
+	return &LockedError{Key: key, Until: time.Unix(int64(until), 0)}
+}
+
+// Fail records a failed attempt for the key. If the attempt locks the key out,
+// the lockout is returned.
+func (g *Guard) Fail(key string) (*LockedError, error) {
+	failures, err := g.cache.Increment(g.failuresKey(key), &cache.Options{Expiration: g.options.Window})
+	if err != nil {
+		return nil, fmt.Errorf("[Guard.Fail][Increment]%w", err)
+	}
+	if failures < int64(g.options.MaxAttempts) {
+		return nil, nil
+	}
+
+	level, err := g.getInt(g.levelKey(key))
+	if err != nil {
+		return nil, fmt.Errorf("[Guard.Fail][GetInt]%w", err)
+	}
+
+	duration := g.options.Duration
+	for i := 0; i < level && duration < g.options.MaxDuration; i++ {
+		duration *= 2
+	}
+	duration = min(duration, g.options.MaxDuration)
+	until := time.Now().Add(duration).Truncate(time.Second).Add(time.Second)
+
+	err = g.cache.PutInt(g.untilKey(key), int(until.Unix()), &cache.Options{Expiration: time.Until(until)})
+	if err != nil {
+		return nil, fmt.Errorf("[Guard.Fail][PutInt]%w", err)
+	}
+	err = g.cache.PutInt(g.levelKey(key), level+1, &cache.Options{Expiration: levelTTL})
+	if err != nil {
+		return nil, fmt.Errorf("[Guard.Fail][PutInt]%w", err)
+	}
+	if err = g.delete(g.failuresKey(key)); err != nil {
+		return nil, fmt.Errorf("[Guard.Fail][Delete]%w", err)
+	}
+
+	return &LockedError{Key: key, Until: until}, nil
+}
+
+// Reset forgets the failed attempts and previous lockouts of the key after a
+// successful login. It does not lift an active lockout.
+func (g *Guard) Reset(key string) error {
+	if err := g.delete(g.failuresKey(key)); err != nil {
+		return fmt.Errorf("[Guard.Reset][Delete]%w", err)
+	}
+	if err := g.delete(g.levelKey(key)); err != nil {
+		return fmt.Errorf("[Guard.Reset][Delete]%w", err)
+	}
+	return nil
+}
+
+// Clear lifts the lockout of the key and forgets its failed attempts.
+func (g *Guard) Clear(key string) error {
+	if err := g.Reset(key); err != nil {
+		return err
+	}
+	if err := g.delete(g.untilKey(key)); err != nil {
+		return fmt.Errorf("[Guard.Clear][Delete]%w", err)
+	}
+	return nil
+}
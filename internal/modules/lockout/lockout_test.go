@@ -0,0 +1,82 @@
+package lockout
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"be20250107/internal/modules/cache"
+	"be20250107/internal/modules/ratelimiter"
+)
+
+func TestFailLocksKey(t *testing.T) {
+	guard := New(cache.NewInMemoryCache(nil), "test", Options{MaxAttempts: 3, Duration: time.Minute})
+
+	for i := 0; i < 2; i++ {
+		locked, err := guard.Fail("ip:127.0.0.1")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if locked != nil {
+			t.Fatalf("expected no lockout after %d failures", i+1)
+		}
+	}
+	if err := guard.Check("ip:127.0.0.1"); err != nil {
+		t.Fatalf("expected key not to be locked, got %v", err)
+	}
+
+	locked, err := guard.Fail("ip:127.0.0.1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if locked == nil {
+		t.Fatal("expected lockout after max attempts")
+	}
+	if d := locked.RetryAfter(); d < 59*time.Second || d > 61*time.Second {
+		t.Errorf("expected retry after about a minute, got %s", d)
+	}
+
+	err = guard.Check("ip:127.0.0.1")
+	if !errors.Is(err, ratelimiter.ErrRateLimited) {
+		t.Errorf("expected locked key to be rate-limited, got %v", err)
+	}
+	if err := guard.Check("ip:127.0.0.2"); err != nil {
+		t.Errorf("expected other key not to be locked, got %v", err)
+	}
+}
+
+func TestLockoutBackoff(t *testing.T) {
+	guard := New(cache.NewInMemoryCache(nil), "test", Options{MaxAttempts: 1, Duration: time.Minute, MaxDuration: 3 * time.Minute})
+
+	for _, expected := range []time.Duration{time.Minute, 2 * time.Minute, 3 * time.Minute, 3 * time.Minute} {
+		locked, err := guard.Fail("user")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if d := locked.RetryAfter(); d < expected-time.Second || d > expected+time.Second {
+			t.Errorf("expected lockout of %s, got %s", expected, d)
+		}
+	}
+}
+
+func TestClear(t *testing.T) {
+	guard := New(cache.NewInMemoryCache(nil), "test", Options{MaxAttempts: 1})
+
+	if _, err := guard.Fail("user"); err != nil {
+		t.Fatal(err)
+	}
+	if err := guard.Clear("user"); err != nil {
+		t.Fatal(err)
+	}
+	if err := guard.Check("user"); err != nil {
+		t.Errorf("expected lockout to be cleared, got %v", err)
+	}
+
+	locked, err := guard.Fail("user")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d := locked.RetryAfter(); d > DefaultOptions.Duration+time.Second {
+		t.Errorf("expected backoff to restart after clear, got %s", d)
+	}
+}
@@ -0,0 +1,89 @@
+package mq
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Message is a message delivered to a Handler.
+type Message struct {
+	Topic string
+	Body  []byte
+	// Attempts is how many times the message has been delivered, including
+	// this delivery.
+	Attempts uint16
+}
+
+// Handler handles a message. Returning an error requeues the message, after
+// the delay of a *RetryError or after the delay of the driver otherwise.
+type Handler func(m *Message) error
+
+// RetryError requeues the message it is returned for after Delay.
+type RetryError struct {
+	Err   error
+	Delay time.Duration
+}
+
+func (e *RetryError) Error() string {
+	return fmt.Sprintf("retry in %s: %v", e.Delay, e.Err)
+}
+
+func (e *RetryError) Unwrap() error {
+	return e.Err
+}
+
+type SubscribeOptions struct {
+	// MaxInFlight is how many messages the nsq driver receives before any of
+	// them is handled.
+	MaxInFlight int
+	// Concurrency is how many messages are handled at a time. It defaults to
+	// one.
+	Concurrency int
+	// MaxAttempts is how many times a message is delivered while its handler
+	// fails. Zero uses the default of the driver.
+	MaxAttempts uint16
+}
+
+type Publisher interface {
+	Publish(topic string, body []byte) error
+}
+
+type Subscriber interface {
+	// Subscribe delivers the messages of the topic to handler. Every channel
+	// receives every message of the topic, while the subscribers of the same
+	// channel share them.
+	Subscribe(topic string, channel string, handler Handler, options SubscribeOptions) error
+}
+
+// Bus publishes and delivers messages through a driver.
+type Bus interface {
+	Publisher
+	Subscriber
+	// Stop stops delivering messages, waits for the handlers in progress and
+	// releases the connections of the driver.
+	Stop()
+}
+
+var ErrStopped = errors.New("message bus is stopped")
+
+// BusOptions configures the drivers of NewBus.
+type BusOptions struct {
+	// Driver is nsq (default), channel or nop.
+	Driver      string
+	NsqdHost    string
+	LookupdHost string
+}
+
+// NewBus returns the bus of the driver.
+func NewBus(options BusOptions) (Bus, error) {
+	switch options.Driver {
+	case "", "nsq":
+		return NewNSQBus(options.NsqdHost, options.LookupdHost)
+	case "channel":
+		return NewChannelBus(), nil
+	case "nop":
+		return NopBus{}, nil
+	}
+	return nil, fmt.Errorf("unsupported message bus driver: %s", options.Driver)
+}
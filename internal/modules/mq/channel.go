@@ -0,0 +1,149 @@
+package mq
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+var ErrQueueFull = errors.New("message bus queue is full")
+
+// channelQueueSize is how many undelivered messages a channel holds before
+// publishing to its topic fails.
+const channelQueueSize = 1024
+
+// ChannelBus delivers messages in process, for tests and single-node setups.
+// Messages published to a topic without subscriptions are dropped, and
+// undelivered messages are lost when the bus stops.
+type ChannelBus struct {
+	// MaxAttempts is how many times a message is delivered while its handler
+	// fails, for subscriptions that do not set it.
+	MaxAttempts uint16
+	// RequeueDelay is how long a failed message waits before it is delivered
+	// again, multiplied by the attempts so far, unless the handler returns a
+	// *RetryError.
+	RequeueDelay time.Duration
+	// Log reports the retries dropped because the queue was full. It is
+	// optional.
+	Log func(message string)
+
+	mu       sync.Mutex
+	channels map[string]map[string]chan *Message
+	stopped  bool
+	stop     chan struct{}
+	workers  sync.WaitGroup
+}
+
+func NewChannelBus() *ChannelBus {
+	return &ChannelBus{
+		MaxAttempts:  5,
+		RequeueDelay: 100 * time.Millisecond,
+		channels:     map[string]map[string]chan *Message{},
+		stop:         make(chan struct{}),
+	}
+}
+
+// Publish enqueues the message on every channel of the topic, or on none of
+// them if any is full, so that a failed publish can be retried without
+// delivering the message twice.
+func (b *ChannelBus) Publish(topic string, body []byte) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.stopped {
+		return ErrStopped
+	}
+	//	Messages are only enqueued under the lock, so a queue with room now
+	//	still has room below.
+	for _, queue := range b.channels[topic] {
+		if len(queue) == cap(queue) {
+			return ErrQueueFull
+		}
+	}
+	for _, queue := range b.channels[topic] {
+		queue <- &Message{Topic: topic, Body: body, Attempts: 1}
+	}
+	return nil
+}
+
+func (b *ChannelBus) Subscribe(topic string, channel string, handler Handler, options SubscribeOptions) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.stopped {
+		return ErrStopped
+	}
+	if b.channels[topic] == nil {
+		b.channels[topic] = map[string]chan *Message{}
+	}
+	queue, ok := b.channels[topic][channel]
+	if !ok {
+		queue = make(chan *Message, channelQueueSize)
+		b.channels[topic][channel] = queue
+	}
+
+	maxAttempts := options.MaxAttempts
+	if maxAttempts == 0 {
+		maxAttempts = b.MaxAttempts
+	}
+	for i := 0; i < max(options.Concurrency, 1); i++ {
+		b.workers.Add(1)
+		go b.work(queue, handler, maxAttempts)
+	}
+	return nil
+}
+
+func (b *ChannelBus) work(queue chan *Message, handler Handler, maxAttempts uint16) {
+	defer b.workers.Done()
+	for {
+		select {
+		case <-b.stop:
+			return
+		case m := <-queue:
+			err := handler(m)
+			if err == nil || m.Attempts >= maxAttempts {
+				continue
+			}
+
+			delay := time.Duration(m.Attempts) * b.RequeueDelay
+			var retry *RetryError
+			if errors.As(err, &retry) {
+				delay = retry.Delay
+			}
+			b.requeue(queue, m, delay)
+		}
+	}
+}
+
+func (b *ChannelBus) requeue(queue chan *Message, m *Message, delay time.Duration) {
+	m.Attempts++
+	time.AfterFunc(delay, func() {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		if b.stopped {
+			return
+		}
+		select {
+		case queue <- m:
+		default:
+			if b.Log != nil {
+				b.Log(fmt.Sprintf("[ChannelBus] dropping retry %d of %s: %v", m.Attempts, m.Topic, ErrQueueFull))
+			}
+		}
+	})
+}
+
+// Stop waits for the handlers in progress. Messages still queued are dropped.
+func (b *ChannelBus) Stop() {
+	b.mu.Lock()
+	if b.stopped {
+		b.mu.Unlock()
+		return
+	}
+	b.stopped = true
+	close(b.stop)
+	b.mu.Unlock()
+
+	b.workers.Wait()
+}
@@ -0,0 +1,143 @@
+package mq
+
+import (
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestChannelBusDeliversToEveryChannel(t *testing.T) {
+	bus := NewChannelBus()
+	defer bus.Stop()
+
+	var mu sync.Mutex
+	received := map[string]int{}
+	done := make(chan struct{}, 4)
+	handler := func(channel string) Handler {
+		return func(m *Message) error {
+			mu.Lock()
+			received[channel]++
+			mu.Unlock()
+			done <- struct{}{}
+			return nil
+		}
+	}
+
+	//	Both subscribers of "shared" share its messages.
+	for _, channel := range []string{"a", "shared", "shared"} {
+		if err := bus.Subscribe("topic", channel, handler(channel), SubscribeOptions{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for i := 0; i < 2; i++ {
+		if err := PublishMessage(bus, "topic", CacheInvalidatedMsg{Flush: true}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for i := 0; i < 4; i++ {
+		select {
+		case <-done:
+		case <-time.After(time.Second):
+			t.Fatal("timed out waiting for messages")
+		}
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if received["a"] != 2 || received["shared"] != 2 {
+		t.Errorf("expected every channel to receive both messages once, got %v", received)
+	}
+}
+
+func TestChannelBusRetriesFailedMessages(t *testing.T) {
+	bus := NewChannelBus()
+	bus.MaxAttempts = 3
+	bus.RequeueDelay = time.Millisecond
+	defer bus.Stop()
+
+	attempts := make(chan uint16, 5)
+	err := bus.Subscribe("topic", "channel", func(m *Message) error {
+		attempts <- m.Attempts
+		return errors.New("failed")
+	}, SubscribeOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := bus.Publish("topic", []byte("{}")); err != nil {
+		t.Fatal(err)
+	}
+
+	for want := uint16(1); want <= 3; want++ {
+		select {
+		case got := <-attempts:
+			if got != want {
+				t.Fatalf("expected attempt %d, got %d", want, got)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("timed out waiting for attempt %d", want)
+		}
+	}
+	select {
+	case got := <-attempts:
+		t.Errorf("expected no more than 3 attempts, got attempt %d", got)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestStoppedChannelBusRejectsMessages(t *testing.T) {
+	bus := NewChannelBus()
+	bus.Stop()
+
+	if err := bus.Publish("topic", nil); !errors.Is(err, ErrStopped) {
+		t.Errorf("expected ErrStopped, got %v", err)
+	}
+}
+
+func TestChannelBusPublishesToAllChannelsOrNone(t *testing.T) {
+	bus := NewChannelBus()
+	defer bus.Stop()
+
+	//	Queues without workers, so that they fill up.
+	full, free := make(chan *Message, 1), make(chan *Message, 2)
+	bus.channels["topic"] = map[string]chan *Message{"full": full, "free": free}
+	full <- &Message{}
+
+	if err := bus.Publish("topic", []byte("{}")); !errors.Is(err, ErrQueueFull) {
+		t.Fatalf("expected ErrQueueFull, got %v", err)
+	}
+	if len(free) != 0 {
+		t.Errorf("expected no channel to receive the message, got %d queued", len(free))
+	}
+
+	<-full
+	if err := bus.Publish("topic", []byte("{}")); err != nil {
+		t.Fatal(err)
+	}
+	if len(full) != 1 || len(free) != 1 {
+		t.Errorf("expected every channel to receive the message, got %d and %d queued", len(full), len(free))
+	}
+}
+
+func TestChannelBusLogsDroppedRetries(t *testing.T) {
+	bus := NewChannelBus()
+	defer bus.Stop()
+	logged := make(chan string, 1)
+	bus.Log = func(message string) {
+		logged <- message
+	}
+
+	queue := make(chan *Message, 1)
+	queue <- &Message{}
+	bus.requeue(queue, &Message{Topic: "topic", Attempts: 1}, 0)
+
+	select {
+	case message := <-logged:
+		if !strings.Contains(message, "topic") {
+			t.Errorf("expected the topic to be logged, got %q", message)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("expected the dropped retry to be logged")
+	}
+}
@@ -3,6 +3,7 @@ package mq
 const (
 	AddressAssistanceRequestUpdatedTopic = "address_assistance_request_updated"
 	AdminUpdatedTopic                    = "admin_updated"
+	AdminNewDeviceTopic                  = "admin_new_device"
 	DriverTopupUpdatedTopic              = "driver_topup_updated"
 	DriverWithdrawalUpdatedTopic         = "driver_withdrawal_updated"
 	MerchantWithdrawalUpdatedTopic       = "merchant_withdrawal_updated"
@@ -12,4 +13,15 @@ const (
 	MerchantUpdatedTopic                 = "merchant_updated"
 	StoreUpdatedTopic                    = "store_updated"
 	OrderUpdatedTopic                    = "order_updated"
+	TokenRevokedTopic                    = "token_revoked"
+	CacheInvalidatedTopic                = "cache_invalidated"
+	DeadLetterTopic                      = "dead_letter"
+
+	CatalogueCreatedTopic      = "catalogue_created"
+	CatalogueUpdatedTopic      = "catalogue_updated"
+	CatalogueDeletedTopic      = "catalogue_deleted"
+	CataloguePublishedTopic    = "catalogue_published"
+	CataloguePriceChangedTopic = "catalogue_price_changed"
+	BrandUpdatedTopic          = "brand_updated"
+	CategoryUpdatedTopic       = "category_updated"
 )
@@ -0,0 +1,179 @@
+package mq
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"sync"
+	"time"
+)
+
+// ErrMalformedMessage is returned for messages that cannot be decoded. They
+// are dead-lettered right away since they will not decode on retry either.
+var ErrMalformedMessage = errors.New("malformed message")
+
+type ConsumerOptions struct {
+	SubscribeOptions
+	// MinBackoff is the delay before the first retry of a failed message,
+	// which doubles with every attempt up to MaxBackoff.
+	MinBackoff time.Duration
+	MaxBackoff time.Duration
+}
+
+var DefaultConsumerOptions = ConsumerOptions{
+	SubscribeOptions: SubscribeOptions{MaxInFlight: 1, Concurrency: 1, MaxAttempts: 5},
+	MinBackoff:       time.Second,
+	MaxBackoff:       10 * time.Minute,
+}
+
+// backoff returns the delay before the next delivery of a message that failed
+// its attempt.
+func (o ConsumerOptions) backoff(attempt uint16) time.Duration {
+	delay := o.MinBackoff
+	for i := uint16(1); i < attempt && delay < o.MaxBackoff; i++ {
+		delay *= 2
+	}
+	return min(delay, o.MaxBackoff)
+}
+
+// Consumers subscribes handlers to the bus. A message whose handler fails is
+// retried with backoff until it has been attempted MaxAttempts times, after
+// which it is published to DeadLetterTopic.
+type Consumers struct {
+	bus      Bus
+	defaults ConsumerOptions
+	options  map[string]ConsumerOptions
+
+	mu       sync.Mutex
+	handlers []consumerHandler
+	started  bool
+
+	// Log reports the messages that are dead-lettered.
+	Log func(message string)
+}
+
+type consumerHandler struct {
+	topic   string
+	channel string
+	handle  Handler
+}
+
+// NewConsumers returns Consumers using the options of the topic, falling back
+// to defaults for every option left empty, and to DefaultConsumerOptions for
+// every default left empty.
+func NewConsumers(bus Bus, defaults ConsumerOptions, options map[string]ConsumerOptions) *Consumers {
+	return &Consumers{
+		bus:      bus,
+		defaults: mergeConsumerOptions(defaults, DefaultConsumerOptions),
+		options:  options,
+	}
+}
+
+func mergeConsumerOptions(o ConsumerOptions, defaults ConsumerOptions) ConsumerOptions {
+	if o.MaxInFlight <= 0 {
+		o.MaxInFlight = defaults.MaxInFlight
+	}
+	if o.Concurrency <= 0 {
+		o.Concurrency = defaults.Concurrency
+	}
+	if o.MaxAttempts == 0 {
+		o.MaxAttempts = defaults.MaxAttempts
+	}
+	if o.MinBackoff <= 0 {
+		o.MinBackoff = defaults.MinBackoff
+	}
+	if o.MaxBackoff <= 0 {
+		o.MaxBackoff = defaults.MaxBackoff
+	}
+	return o
+}
+
+// Options returns the options the handlers of the topic run with.
+func (c *Consumers) Options(topic string) ConsumerOptions {
+	return mergeConsumerOptions(c.options[topic], c.defaults)
+}
+
+// Handle registers handler for the messages of the topic on the channel,
+// decoded from JSON into T. Handlers registered after Start are subscribed
+// right away.
+func Handle[T any](c *Consumers, topic string, channel string, handler func(msg T) error) error {
+	return c.register(consumerHandler{topic: topic, channel: channel, handle: func(m *Message) error {
+		var msg T
+		if err := json.Unmarshal(m.Body, &msg); err != nil {
+			return fmt.Errorf("%w: %v", ErrMalformedMessage, err)
+		}
+		return handler(msg)
+	}})
+}
+
+func (c *Consumers) register(h consumerHandler) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.handlers = append(c.handlers, h)
+	if c.started {
+		return c.subscribe(h)
+	}
+	return nil
+}
+
+// Start subscribes the registered handlers. Messages are drained by stopping
+// the bus.
+func (c *Consumers) Start() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.started {
+		return nil
+	}
+	c.started = true
+
+	var errs []error
+	for _, h := range c.handlers {
+		errs = append(errs, c.subscribe(h))
+	}
+	return errors.Join(errs...)
+}
+
+func (c *Consumers) subscribe(h consumerHandler) error {
+	options := c.Options(h.topic)
+	subscribeOptions := options.SubscribeOptions
+	//	Attempts are counted here so that the driver never drops a message
+	//	before it is dead-lettered.
+	subscribeOptions.MaxAttempts = math.MaxUint16
+
+	err := c.bus.Subscribe(h.topic, h.channel, func(m *Message) error {
+		err := h.handle(m)
+		if err == nil {
+			return nil
+		}
+		if !errors.Is(err, ErrMalformedMessage) && m.Attempts < options.MaxAttempts {
+			return &RetryError{Err: err, Delay: options.backoff(m.Attempts)}
+		}
+
+		if dlErr := c.deadLetter(h, m, err); dlErr != nil {
+			//	Keep the message rather than losing it.
+			return &RetryError{Err: dlErr, Delay: options.MaxBackoff}
+		}
+		return nil
+	}, subscribeOptions)
+	if err != nil {
+		return fmt.Errorf("[Consumers.subscribe] %s/%s: %w", h.topic, h.channel, err)
+	}
+	return nil
+}
+
+func (c *Consumers) deadLetter(h consumerHandler, m *Message, cause error) error {
+	if c.Log != nil {
+		c.Log(fmt.Sprintf("[Consumers] dead-lettering %s/%s after %d attempts: %v", h.topic, h.channel, m.Attempts, cause))
+	}
+	return PublishMessage(c.bus, DeadLetterTopic, DeadLetterMsg{
+		Topic:    h.topic,
+		Channel:  h.channel,
+		Body:     m.Body,
+		Attempts: m.Attempts,
+		Error:    cause.Error(),
+		FailedAt: time.Now().Unix(),
+	})
+}
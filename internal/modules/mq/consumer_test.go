@@ -0,0 +1,129 @@
+package mq
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+)
+
+func newTestConsumers(t *testing.T) (*Consumers, <-chan DeadLetterMsg) {
+	bus := NewChannelBus()
+	t.Cleanup(bus.Stop)
+
+	deadLetters := make(chan DeadLetterMsg, 10)
+	err := bus.Subscribe(DeadLetterTopic, "test", func(m *Message) error {
+		var msg DeadLetterMsg
+		if err := json.Unmarshal(m.Body, &msg); err != nil {
+			t.Error(err)
+		}
+		deadLetters <- msg
+		return nil
+	}, SubscribeOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c := NewConsumers(bus, ConsumerOptions{MinBackoff: time.Millisecond, MaxBackoff: 5 * time.Millisecond}, map[string]ConsumerOptions{
+		"flaky": {SubscribeOptions: SubscribeOptions{MaxAttempts: 3}},
+	})
+	return c, deadLetters
+}
+
+func TestConsumersRetryUntilHandled(t *testing.T) {
+	c, deadLetters := newTestConsumers(t)
+
+	handled := make(chan string, 5)
+	attempts := 0
+	err := Handle(c, "flaky", "test", func(msg AdminUpdatedMsg) error {
+		attempts++
+		if attempts < 3 {
+			return errors.New("not yet")
+		}
+		handled <- msg.AdminID
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Start(); err != nil {
+		t.Fatal(err)
+	}
+	if err := PublishMessage(c.bus, "flaky", AdminUpdatedMsg{AdminID: "a"}); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case id := <-handled:
+		if id != "a" {
+			t.Errorf("expected the decoded message, got %q", id)
+		}
+	case msg := <-deadLetters:
+		t.Fatalf("expected the message to be handled on its third attempt, got dead letter %+v", msg)
+	case <-time.After(time.Second):
+		t.Fatal("timed out waiting for the message")
+	}
+}
+
+func TestConsumersDeadLetterExhaustedMessages(t *testing.T) {
+	c, deadLetters := newTestConsumers(t)
+
+	err := Handle(c, "flaky", "test", func(msg AdminUpdatedMsg) error {
+		return errors.New("always")
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Start(); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.bus.Publish("flaky", []byte(`{"AdminID":"a"}`)); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case msg := <-deadLetters:
+		if msg.Topic != "flaky" || msg.Channel != "test" || msg.Attempts != 3 || msg.Error != "always" {
+			t.Errorf("unexpected dead letter %+v", msg)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("timed out waiting for the dead letter")
+	}
+}
+
+func TestConsumersDeadLetterMalformedMessages(t *testing.T) {
+	c, deadLetters := newTestConsumers(t)
+
+	if err := c.Start(); err != nil {
+		t.Fatal(err)
+	}
+	//	Handlers registered after Start are subscribed right away.
+	err := Handle(c, "strict", "test", func(msg AdminUpdatedMsg) error {
+		t.Error("expected the malformed message not to be handled")
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.bus.Publish("strict", []byte("not json")); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case msg := <-deadLetters:
+		if msg.Attempts != 1 || string(msg.Body) != "not json" {
+			t.Errorf("expected the malformed message to be dead-lettered at once, got %+v", msg)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("timed out waiting for the dead letter")
+	}
+}
+
+func TestConsumerBackoff(t *testing.T) {
+	o := ConsumerOptions{MinBackoff: time.Second, MaxBackoff: 5 * time.Second}
+	for attempt, expected := range map[uint16]time.Duration{1: time.Second, 2: 2 * time.Second, 3: 4 * time.Second, 4: 5 * time.Second, 60: 5 * time.Second} {
+		if d := o.backoff(attempt); d != expected {
+			t.Errorf("attempt %d: expected %s, got %s", attempt, expected, d)
+		}
+	}
+}
@@ -0,0 +1,103 @@
+package mq
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/nsqio/go-nsq"
+)
+
+// NSQBus publishes to nsqd and subscribes through nsqlookupd, or directly to
+// nsqd without a lookupd host.
+type NSQBus struct {
+	producer    *nsq.Producer
+	nsqdHost    string
+	lookupdHost string
+
+	mu        sync.Mutex
+	consumers []*nsq.Consumer
+}
+
+// NewNSQBus returns an NSQBus. It does not connect until the first publish or
+// subscription, so nsqd does not need to be up.
+func NewNSQBus(nsqdHost string, lookupdHost string) (*NSQBus, error) {
+	producer, err := nsq.NewProducer(nsqdHost, nsq.NewConfig())
+	if err != nil {
+		return nil, fmt.Errorf("[NewNSQBus][NewProducer]%w", err)
+	}
+	producer.SetLoggerLevel(nsq.LogLevelWarning)
+
+	return &NSQBus{
+		producer:    producer,
+		nsqdHost:    nsqdHost,
+		lookupdHost: lookupdHost,
+	}, nil
+}
+
+func (b *NSQBus) Publish(topic string, body []byte) error {
+	return b.producer.Publish(topic, body)
+}
+
+func (b *NSQBus) Subscribe(topic string, channel string, handler Handler, options SubscribeOptions) error {
+	cfg := nsq.NewConfig()
+	if options.MaxInFlight > 0 {
+		cfg.MaxInFlight = options.MaxInFlight
+	}
+	if options.MaxAttempts > 0 {
+		cfg.MaxAttempts = options.MaxAttempts
+	}
+	consumer, err := nsq.NewConsumer(topic, channel, cfg)
+	if err != nil {
+		return fmt.Errorf("[NSQBus.Subscribe][NewConsumer]%w", err)
+	}
+	consumer.SetLoggerLevel(nsq.LogLevelWarning)
+	consumer.AddConcurrentHandlers(nsq.HandlerFunc(func(m *nsq.Message) error {
+		m.DisableAutoResponse()
+		err := handler(&Message{Topic: topic, Body: m.Body, Attempts: m.Attempts})
+
+		var retry *RetryError
+		switch {
+		case err == nil:
+			m.Finish()
+		case errors.As(err, &retry):
+			//	The handler chose the delay, so the consumer does not need to
+			//	back off as a whole.
+			m.RequeueWithoutBackoff(retry.Delay)
+		default:
+			m.Requeue(-1)
+		}
+		return nil
+	}), max(options.Concurrency, 1))
+
+	if b.lookupdHost != "" {
+		err = consumer.ConnectToNSQLookupd(b.lookupdHost)
+	} else {
+		err = consumer.ConnectToNSQD(b.nsqdHost)
+	}
+	if err != nil {
+		return fmt.Errorf("[NSQBus.Subscribe][Connect]%w", err)
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.consumers = append(b.consumers, consumer)
+	return nil
+}
+
+// Stop stops the consumers, waiting for their in-flight messages, before it
+// stops the producer, so that handlers can still publish while draining.
+func (b *NSQBus) Stop() {
+	b.mu.Lock()
+	consumers := b.consumers
+	b.consumers = nil
+	b.mu.Unlock()
+
+	for _, consumer := range consumers {
+		consumer.Stop()
+	}
+	for _, consumer := range consumers {
+		<-consumer.StopChan
+	}
+	b.producer.Stop()
+}
@@ -8,6 +8,12 @@ type AdminUpdatedMsg struct {
 	AdminID string
 }
 
+type AdminNewDeviceMsg struct {
+	AdminID string `json:"admin_id"`
+	// LoginLogID is empty if the login could not be recorded.
+	LoginLogID string `json:"login_log_id"`
+}
+
 type DriverTopupUpdatedMsg struct {
 	DriverTopupID string `json:"driver_topup_id"`
 }
@@ -44,3 +50,109 @@ type StoreUpdatedMsg struct {
 type OrderUpdatedMsg struct {
 	OrderID string `json:"order_id"`
 }
+
+type TokenRevokedMsg struct {
+	TokenID string `json:"token_id"`
+	// ExpiredAt is the unix time the token expires, nil if it never does.
+	ExpiredAt *int64 `json:"expired_at"`
+}
+
+type CacheInvalidatedMsg struct {
+	// Origin is the instance that invalidated the keys.
+	Origin string   `json:"origin"`
+	Keys   []string `json:"keys"`
+	Flush  bool     `json:"flush"`
+}
+
+// DeadLetterMsg carries a message that could not be handled.
+type DeadLetterMsg struct {
+	Topic    string `json:"topic"`
+	Channel  string `json:"channel"`
+	Body     []byte `json:"body"`
+	Attempts uint16 `json:"attempts"`
+	Error    string `json:"error"`
+	// FailedAt is the unix time of the last attempt.
+	FailedAt int64 `json:"failed_at"`
+}
+
+// CatalogueEventVersion is the Version of the catalogue event payloads below.
+// It is incremented when a field is removed or changes meaning, so consumers
+// can tell payloads they do not understand. Added fields keep the version.
+const CatalogueEventVersion = 1
+
+// CatalogueCreatedMsg is published on CatalogueCreatedTopic once a catalogue
+// is created.
+type CatalogueCreatedMsg struct {
+	Version     int     `json:"version"`
+	CatalogueID int     `json:"catalogue_id"`
+	Name        string  `json:"name"`
+	BrandID     int     `json:"brand_id"`
+	CategoryID  int     `json:"category_id"`
+	Price       float64 `json:"price"`
+	CreatedBy   string  `json:"created_by"`
+	// OccurredAt is the unix time of the change.
+	OccurredAt int64 `json:"occurred_at"`
+}
+
+// CatalogueUpdatedMsg is published on CatalogueUpdatedTopic once a catalogue
+// is updated, with its values after the update.
+type CatalogueUpdatedMsg struct {
+	Version     int     `json:"version"`
+	CatalogueID int     `json:"catalogue_id"`
+	Name        string  `json:"name"`
+	BrandID     int     `json:"brand_id"`
+	Price       float64 `json:"price"`
+	// PublishedAt is the unix time the catalogue is published, nil if it is
+	// not.
+	PublishedAt *int64 `json:"published_at"`
+	OccurredAt  int64  `json:"occurred_at"`
+}
+
+// CatalogueDeletedMsg is published on CatalogueDeletedTopic once a catalogue
+// is deleted.
+type CatalogueDeletedMsg struct {
+	Version     int   `json:"version"`
+	CatalogueID int   `json:"catalogue_id"`
+	OccurredAt  int64 `json:"occurred_at"`
+}
+
+// CataloguePublishedMsg is published on CataloguePublishedTopic once an
+// unpublished catalogue is given a publication time, in addition to the
+// updated message.
+type CataloguePublishedMsg struct {
+	Version     int   `json:"version"`
+	CatalogueID int   `json:"catalogue_id"`
+	PublishedAt int64 `json:"published_at"`
+	OccurredAt  int64 `json:"occurred_at"`
+}
+
+// CataloguePriceChangedMsg is published on CataloguePriceChangedTopic once the
+// price of a catalogue changes, in addition to the updated message.
+type CataloguePriceChangedMsg struct {
+	Version     int     `json:"version"`
+	CatalogueID int     `json:"catalogue_id"`
+	OldPrice    float64 `json:"old_price"`
+	NewPrice    float64 `json:"new_price"`
+	OccurredAt  int64   `json:"occurred_at"`
+}
+
+// BrandUpdatedMsg is published on BrandUpdatedTopic once a brand is updated
+// or deleted.
+type BrandUpdatedMsg struct {
+	Version    int    `json:"version"`
+	BrandID    int    `json:"brand_id"`
+	Name       string `json:"name"`
+	Deleted    bool   `json:"deleted"`
+	OccurredAt int64  `json:"occurred_at"`
+}
+
+// CategoryUpdatedMsg is published on CategoryUpdatedTopic once a category is
+// updated or deleted.
+type CategoryUpdatedMsg struct {
+	Version     int    `json:"version"`
+	CategoryID  int    `json:"category_id"`
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Deleted     bool   `json:"deleted"`
+	OccurredAt  int64  `json:"occurred_at"`
+}
@@ -0,0 +1,146 @@
+// Package otp generates one-time login codes, stores them in the cache with a
+// TTL and limits the number of verification attempts.
+package otp
+
+import (
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"time"
+
+	"be20250107/internal/modules/cache"
+	"be20250107/internal/modules/ratelimiter"
+	"be20250107/utils/random"
+)
+
+var (
+	ErrInvalidCode     = errors.New("code is invalid or has expired")
+	ErrTooManyAttempts = fmt.Errorf("%w: too many invalid codes", ratelimiter.ErrRateLimited)
+	ErrCooldown        = fmt.Errorf("%w: a code has been sent recently", ratelimiter.ErrRateLimited)
+)
+
+type Options struct {
+	Length      int
+	TTL         time.Duration
+	Cooldown    time.Duration
+	MaxAttempts int
+}
+
+var DefaultOptions = Options{
+	Length:      6,
+	TTL:         5 * time.Minute,
+	Cooldown:    time.Minute,
+	MaxAttempts: 5,
+}
+
+type Store struct {
+	cache   cache.Cache
+	options Options
+}
+
+// New returns a Store, using DefaultOptions for every option left empty. A
+// negative Cooldown disables it.
+func New(c cache.Cache, options Options) *Store {
+	if options.Length <= 0 {
+		options.Length = DefaultOptions.Length
+	}
+	if options.TTL <= 0 {
+		options.TTL = DefaultOptions.TTL
+	}
+	if options.Cooldown == 0 {
+		options.Cooldown = DefaultOptions.Cooldown
+	} else if options.Cooldown < 0 {
+		options.Cooldown = 0
+	}
+	if options.MaxAttempts <= 0 {
+		options.MaxAttempts = DefaultOptions.MaxAttempts
+	}
+	return &Store{cache: c, options: options}
+}
+
+func (s *Store) TTL() time.Duration {
+	return s.options.TTL
+}
+
+func codeKey(medium string, credential string) string {
+	return fmt.Sprintf("auth:code_%s_%s", medium, credential)
+}
+
+func attemptsKey(medium string, credential string) string {
+	return fmt.Sprintf("auth:code_attempts_%s_%s", medium, credential)
+}
+
+func cooldownKey(medium string, credential string) string {
+	return fmt.Sprintf("auth:code_cooldown_%s_%s", medium, credential)
+}
+
+// Generate creates a new code for the credential, replacing any previous one.
+// It fails with ErrCooldown if a code was generated within the cooldown.
+func (s *Store) Generate(medium string, credential string) (string, error) {
+	if s.options.Cooldown > 0 {
+		fresh, err := s.cache.SetNX(cooldownKey(medium, credential), true, &cache.Options{Expiration: s.options.Cooldown})
+		if err != nil {
+			return "", err
+		} else if !fresh {
+			return "", ErrCooldown
+		}
+	}
+
+	code := random.GenerateString(s.options.Length, random.NumericCharset)
+	if err := s.cache.Put(codeKey(medium, credential), []byte(code), &cache.Options{Expiration: s.options.TTL}); err != nil {
+		return "", err
+	}
+	if err := s.cache.Delete(attemptsKey(medium, credential)); err != nil && !errors.Is(err, cache.ErrKeyNotFound) {
+		return "", err
+	}
+	return code, nil
+}
+
+// Verify checks the code of the credential. A valid code can only be used
+// once. After MaxAttempts invalid codes the stored code is discarded and
+// ErrTooManyAttempts is returned until a new code is generated.
+//
+// The attempt is counted before the code is compared, so that concurrent
+// guesses cannot all pass the limit, and a valid code is consumed with a
+// compare-and-swap, so that concurrent requests cannot both redeem it.
+func (s *Store) Verify(medium string, credential string, code string) error {
+	attempts, err := s.cache.Increment(attemptsKey(medium, credential), &cache.Options{Expiration: s.options.TTL})
+	if err != nil {
+		return err
+	}
+	if attempts > int64(s.options.MaxAttempts) {
+		return ErrTooManyAttempts
+	}
+
+	expected, err := s.cache.Get(codeKey(medium, credential))
+	if errors.Is(err, cache.ErrKeyNotFound) {
+		return ErrInvalidCode
+	} else if err != nil {
+		return err
+	}
+
+	if len(expected) > 0 && subtle.ConstantTimeCompare(expected, []byte(code)) == 1 {
+		//	A consumed code is replaced by an empty one until it expires.
+		swapped, err := s.cache.CompareAndSwap(codeKey(medium, credential), expected, []byte{}, &cache.Options{Expiration: s.options.TTL})
+		if err != nil {
+			return err
+		}
+		if !swapped {
+			return ErrInvalidCode
+		}
+		if err := s.cache.Delete(attemptsKey(medium, credential)); err != nil && !errors.Is(err, cache.ErrKeyNotFound) {
+			return err
+		}
+		return nil
+	}
+
+	if attempts >= int64(s.options.MaxAttempts) {
+		//	Keep the attempt count so that the credential stays locked until a
+		//	new code is generated.
+		if err := s.cache.Delete(codeKey(medium, credential)); err != nil && !errors.Is(err, cache.ErrKeyNotFound) {
+			return err
+		}
+		return ErrTooManyAttempts
+	}
+	return ErrInvalidCode
+}
@@ -0,0 +1,116 @@
+package otp
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"be20250107/internal/modules/cache"
+)
+
+func TestGenerateAndVerify(t *testing.T) {
+	store := New(cache.NewInMemoryCache(nil), Options{Cooldown: -1})
+
+	code, err := store.Generate("Email", "user@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(code) != DefaultOptions.Length {
+		t.Errorf("expected code of length %d, got %q", DefaultOptions.Length, code)
+	}
+
+	if err := store.Verify("Email", "other@example.com", code); !errors.Is(err, ErrInvalidCode) {
+		t.Errorf("expected ErrInvalidCode for other credential, got %v", err)
+	}
+	if err := store.Verify("Email", "user@example.com", code); err != nil {
+		t.Fatalf("expected code to be valid, got %v", err)
+	}
+	if err := store.Verify("Email", "user@example.com", code); !errors.Is(err, ErrInvalidCode) {
+		t.Errorf("expected code to be usable only once, got %v", err)
+	}
+}
+
+func TestVerifyAttemptLimit(t *testing.T) {
+	store := New(cache.NewInMemoryCache(nil), Options{MaxAttempts: 3, Cooldown: -1})
+
+	code, err := store.Generate("Catalogue", "+886900000000")
+	if err != nil {
+		t.Fatal(err)
+	}
+	wrong := "x" + code[1:]
+
+	for i := 0; i < 2; i++ {
+		if err := store.Verify("Catalogue", "+886900000000", wrong); !errors.Is(err, ErrInvalidCode) {
+			t.Fatalf("attempt %d: expected ErrInvalidCode, got %v", i+1, err)
+		}
+	}
+	if err := store.Verify("Catalogue", "+886900000000", wrong); !errors.Is(err, ErrTooManyAttempts) {
+		t.Fatalf("expected ErrTooManyAttempts, got %v", err)
+	}
+	if err := store.Verify("Catalogue", "+886900000000", code); err == nil {
+		t.Error("expected code to be discarded after too many attempts")
+	}
+
+	code, err = store.Generate("Catalogue", "+886900000000")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Verify("Catalogue", "+886900000000", code); err != nil {
+		t.Errorf("expected new code to reset the attempts, got %v", err)
+	}
+}
+
+func TestGenerateCooldown(t *testing.T) {
+	store := New(cache.NewInMemoryCache(nil), Options{Cooldown: time.Minute})
+
+	if _, err := store.Generate("Email", "user@example.com"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.Generate("Email", "user@example.com"); !errors.Is(err, ErrCooldown) {
+		t.Errorf("expected ErrCooldown, got %v", err)
+	}
+}
+
+func TestVerifyConcurrently(t *testing.T) {
+	store := New(cache.NewInMemoryCache(nil), Options{MaxAttempts: 3, Cooldown: -1})
+
+	code, err := store.Generate("Email", "user@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var wg sync.WaitGroup
+	var redeemed atomic.Int32
+	for i := 0; i < 3; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if store.Verify("Email", "user@example.com", code) == nil {
+				redeemed.Add(1)
+			}
+		}()
+	}
+	wg.Wait()
+	if n := redeemed.Load(); n != 1 {
+		t.Errorf("expected the code to be redeemed once, got %d", n)
+	}
+
+	code, err = store.Generate("Email", "user@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	wrong := "x" + code[1:]
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_ = store.Verify("Email", "user@example.com", wrong)
+		}()
+	}
+	wg.Wait()
+	if err := store.Verify("Email", "user@example.com", code); !errors.Is(err, ErrTooManyAttempts) {
+		t.Errorf("expected parallel guesses to exhaust the attempts, got %v", err)
+	}
+}
@@ -0,0 +1,31 @@
+package otp
+
+import (
+	"fmt"
+	"log"
+)
+
+const SenderLog = "log"
+
+// Sender delivers a code to a destination over the given contact medium.
+type Sender interface {
+	Send(medium string, destination string, code string) error
+}
+
+// LogSender writes codes to the application log instead of delivering them.
+// It is meant for development only.
+type LogSender struct{}
+
+func (LogSender) Send(medium string, destination string, code string) error {
+	log.Printf("[otp.LogSender] %s code for %s: %s", medium, destination, code)
+	return nil
+}
+
+// NewSender returns the sender registered under the name.
+func NewSender(name string) (Sender, error) {
+	switch name {
+	case "", SenderLog:
+		return LogSender{}, nil
+	}
+	return nil, fmt.Errorf("unknown otp sender %q", name)
+}
@@ -1,80 +1,330 @@
+// Package ratelimiter limits how often a key may do something, using atomic
+// cache operations so that concurrent requests on any instance are counted
+// exactly once.
 package ratelimiter
 
 import (
 	"errors"
 	"fmt"
+	"math"
+	"strconv"
+	"strings"
 	"time"
 
 	"be20250107/internal/modules/cache"
 )
 
-var ErrRateLimited = errors.New("request failed due to rate-limit")
+var (
+	ErrRateLimited = errors.New("request failed due to rate-limit")
+	ErrContention  = errors.New("rate limit state is updated too often concurrently")
+)
+
+// maxSwapAttempts is how many times a token bucket update is retried when
+// another request updated the bucket in the meantime.
+const maxSwapAttempts = 16
+
+type Algorithm string
+
+const (
+	// FixedWindow counts requests in consecutive windows of Period.
+	FixedWindow Algorithm = "fixed_window"
+	// SlidingWindow weights the count of the previous window by how much of
+	// it still overlaps the last Period, which smooths the bursts a fixed
+	// window allows at window boundaries.
+	SlidingWindow Algorithm = "sliding_window"
+	// TokenBucket refills Limit tokens per Period into a bucket holding up to
+	// Burst tokens.
+	TokenBucket Algorithm = "token_bucket"
+)
+
+// Policy allows Limit requests per Period. A policy without a positive Limit
+// and Period does not limit anything.
+type Policy struct {
+	Algorithm Algorithm
+	Limit     int
+	Period    time.Duration
+	// Burst is the capacity of a token bucket. It defaults to Limit.
+	Burst int
+}
+
+func (p Policy) Validate() error {
+	switch p.Algorithm {
+	case "", FixedWindow, SlidingWindow, TokenBucket:
+		return nil
+	default:
+		return fmt.Errorf("unknown rate limit algorithm %q", p.Algorithm)
+	}
+}
+
+func (p Policy) unlimited() bool {
+	return p.Limit <= 0 || p.Period <= 0
+}
+
+// Result describes the allowance of a key after a request.
+type Result struct {
+	Allowed   bool
+	Limit     int
+	Remaining int
+	// ResetAt is when the full allowance is available again.
+	ResetAt time.Time
+	// RetryAfter is how long to wait before the request would be allowed. It
+	// is zero for allowed requests.
+	RetryAfter time.Duration
+}
+
+// LimitedError is returned by Guard for a request over the limit. It wraps
+// ErrRateLimited so that it is answered with 429.
+type LimitedError struct {
+	Key    string
+	Result Result
+}
+
+func (e *LimitedError) Error() string {
+	return fmt.Sprintf("%s: %s is over the limit of %d", ErrRateLimited.Error(), e.Key, e.Result.Limit)
+}
+
+func (e *LimitedError) Unwrap() error {
+	return ErrRateLimited
+}
+
+// RetryAfter returns how long until the request would be allowed.
+func (e *LimitedError) RetryAfter() time.Duration {
+	return e.Result.RetryAfter
+}
 
 type RateLimiter struct {
-	cache      cache.Cache
-	Expiration time.Duration
-	Namespace  string
-	IDs        []string
-	KeyPrefix  string
-}
-
-func New(cache cache.Cache, namespace string, ids []string, expiration time.Duration) RateLimiter {
-	joinedID := ""
-	for _, i := range ids {
-		if joinedID == "" {
-			joinedID = i
-		} else {
-			joinedID = joinedID + "_" + i
-		}
+	cache     cache.Cache
+	namespace string
+	policy    Policy
+	now       func() time.Time
+}
+
+// New returns a RateLimiter storing its state under the namespace.
+func New(c cache.Cache, namespace string, policy Policy) *RateLimiter {
+	if policy.Algorithm == "" {
+		policy.Algorithm = FixedWindow
+	}
+	if policy.Burst <= 0 {
+		policy.Burst = policy.Limit
 	}
-	prefix := fmt.Sprintf("rl:%s:%s", namespace, joinedID)
-	return RateLimiter{
-		cache:      cache,
-		Namespace:  namespace,
-		IDs:        ids,
-		Expiration: expiration,
-		KeyPrefix:  prefix,
+	return &RateLimiter{
+		cache:     c,
+		namespace: namespace,
+		policy:    policy,
+		now:       time.Now,
 	}
 }
 
-func (l *RateLimiter) Count() (int, error) {
-	attempts, err := l.cache.GetKeysWithPrefix(l.KeyPrefix)
-	if err != nil {
-		return -1, nil
+// Key joins the IDs identifying what is limited, such as an IP and a route.
+func Key(ids ...string) string {
+	return strings.Join(ids, "_")
+}
+
+func (l *RateLimiter) Policy() Policy {
+	return l.policy
+}
+
+func (l *RateLimiter) cacheKey(key string, suffix string) string {
+	return fmt.Sprintf("rl:%s:%s:%s", l.namespace, key, suffix)
+}
+
+// Allow records a request of the key and reports whether it is within the
+// limit. Requests over the limit are not counted.
+func (l *RateLimiter) Allow(key string) (Result, error) {
+	return l.AllowN(key, 1)
+}
+
+// AllowN is Allow for a request costing n.
+func (l *RateLimiter) AllowN(key string, n int) (Result, error) {
+	if l.policy.unlimited() {
+		return Result{Allowed: true, Limit: l.policy.Limit}, nil
+	}
+
+	switch l.policy.Algorithm {
+	case FixedWindow:
+		return l.fixedWindow(key, n)
+	case SlidingWindow:
+		return l.slidingWindow(key, n)
+	case TokenBucket:
+		return l.tokenBucket(key, n)
+	default:
+		return Result{}, fmt.Errorf("[RateLimiter.AllowN] unknown algorithm %q", l.policy.Algorithm)
 	}
-	return len(attempts), nil
 }
 
-func (l *RateLimiter) Guard(max int) error {
-	count, err := l.Count()
+// Guard records a request of the key and returns a *LimitedError if it is
+// over the limit.
+func (l *RateLimiter) Guard(key string) error {
+	res, err := l.Allow(key)
 	if err != nil {
 		return err
 	}
-	if count >= max {
-		return ErrRateLimited
+	if !res.Allowed {
+		return &LimitedError{Key: key, Result: res}
 	}
 	return nil
 }
 
-func (l *RateLimiter) RecordAttempt() error {
-	now := time.Now().Unix()
-	key := fmt.Sprintf("%s:%d", l.KeyPrefix, now)
-	opt := &cache.Options{Expiration: l.Expiration}
-	if l.Expiration <= 0 {
-		opt = nil
+// Reset clears the state of the key.
+func (l *RateLimiter) Reset(key string) error {
+	var keys []string
+	switch l.policy.Algorithm {
+	case TokenBucket:
+		keys = []string{l.cacheKey(key, "tat")}
+	default:
+		index := l.window(l.now())
+		keys = []string{l.windowKey(key, index), l.windowKey(key, index-1)}
 	}
-	return l.cache.PutValue(key, now, opt)
-}
 
-func (l *RateLimiter) Clear() error {
-	keys, err := l.cache.GetKeysWithPrefix(l.KeyPrefix)
-	if err != nil {
-		return err
-	}
 	for _, k := range keys {
-		if err = l.cache.Delete(k); err != nil {
+		if err := l.cache.Delete(k); err != nil && !errors.Is(err, cache.ErrKeyNotFound) {
 			return err
 		}
 	}
 	return nil
 }
+
+func (l *RateLimiter) window(t time.Time) int64 {
+	return t.UnixNano() / int64(l.policy.Period)
+}
+
+func (l *RateLimiter) windowKey(key string, index int64) string {
+	return l.cacheKey(key, strconv.FormatInt(index, 10))
+}
+
+func (l *RateLimiter) fixedWindow(key string, n int) (Result, error) {
+	now := l.now()
+	index := l.window(now)
+	resetAt := time.Unix(0, (index+1)*int64(l.policy.Period))
+
+	k := l.windowKey(key, index)
+	count, err := l.cache.IncrementBy(k, int64(n), &cache.Options{Expiration: l.policy.Period})
+	if err != nil {
+		return Result{}, fmt.Errorf("[RateLimiter.fixedWindow][IncrementBy]%w", err)
+	}
+
+	res := Result{Limit: l.policy.Limit, ResetAt: resetAt}
+	if count > int64(l.policy.Limit) {
+		if count, err = l.cache.IncrementBy(k, int64(-n), nil); err != nil {
+			return Result{}, fmt.Errorf("[RateLimiter.fixedWindow][IncrementBy]%w", err)
+		}
+		res.RetryAfter = resetAt.Sub(now)
+	} else {
+		res.Allowed = true
+	}
+	res.Remaining = max(l.policy.Limit-int(count), 0)
+	return res, nil
+}
+
+func (l *RateLimiter) slidingWindow(key string, n int) (Result, error) {
+	now := l.now()
+	period := l.policy.Period
+	index := l.window(now)
+	windowEnd := time.Unix(0, (index+1)*int64(period))
+	//	weight is the part of the previous window still within the last
+	//	period.
+	weight := float64(windowEnd.Sub(now)) / float64(period)
+
+	//	Counters live for two periods since they are still read as the
+	//	previous window.
+	k := l.windowKey(key, index)
+	current, err := l.cache.IncrementBy(k, int64(n), &cache.Options{Expiration: 2 * period})
+	if err != nil {
+		return Result{}, fmt.Errorf("[RateLimiter.slidingWindow][IncrementBy]%w", err)
+	}
+	previous, err := l.cache.GetInt(l.windowKey(key, index-1))
+	if err != nil && !errors.Is(err, cache.ErrKeyNotFound) {
+		return Result{}, fmt.Errorf("[RateLimiter.slidingWindow][GetInt]%w", err)
+	}
+
+	limit := float64(l.policy.Limit)
+	res := Result{Limit: l.policy.Limit, Allowed: true}
+	if float64(previous)*weight+float64(current) > limit {
+		if current, err = l.cache.IncrementBy(k, int64(-n), nil); err != nil {
+			return Result{}, fmt.Errorf("[RateLimiter.slidingWindow][IncrementBy]%w", err)
+		}
+		res.Allowed = false
+		res.RetryAfter = l.slidingRetryAfter(now, windowEnd, float64(previous), float64(current), float64(n))
+	}
+
+	used := int(math.Ceil(float64(previous)*weight + float64(current)))
+	res.Remaining = max(l.policy.Limit-used, 0)
+	res.ResetAt = windowEnd
+	if current > 0 {
+		res.ResetAt = windowEnd.Add(period)
+	}
+	return res, nil
+}
+
+// slidingRetryAfter returns how long until a request costing n fits in the
+// sliding window, given the counts of the previous and current windows.
+func (l *RateLimiter) slidingRetryAfter(now time.Time, windowEnd time.Time, previous, current, n float64) time.Duration {
+	period := float64(l.policy.Period)
+	limit := float64(l.policy.Limit)
+
+	if current+n <= limit && previous > 0 {
+		//	Wait in this window until the previous window weighs little
+		//	enough.
+		weight := (limit - current - n) / previous
+		return max(windowEnd.Add(-time.Duration(weight*period)).Sub(now), 0)
+	}
+	if n > limit || current == 0 {
+		return windowEnd.Sub(now)
+	}
+	//	Wait into the next window, where the current window is the previous.
+	weight := (limit - n) / current
+	return windowEnd.Sub(now) + time.Duration((1-weight)*period)
+}
+
+// tokenBucket implements the bucket as the generic cell rate algorithm, which
+// only stores the time at which the bucket would be full again.
+func (l *RateLimiter) tokenBucket(key string, n int) (Result, error) {
+	k := l.cacheKey(key, "tat")
+	interval := l.policy.Period / time.Duration(l.policy.Limit)
+	burst := time.Duration(l.policy.Burst) * interval
+
+	for i := 0; i < maxSwapAttempts; i++ {
+		now := l.now()
+		stored, err := l.cache.Get(k)
+		if err != nil && !errors.Is(err, cache.ErrKeyNotFound) {
+			return Result{}, fmt.Errorf("[RateLimiter.tokenBucket][Get]%w", err)
+		}
+
+		tat := now
+		if stored != nil {
+			nanos, err := strconv.ParseInt(string(stored), 10, 64)
+			if err != nil {
+				return Result{}, fmt.Errorf("[RateLimiter.tokenBucket][ParseInt]%w", err)
+			}
+			tat = time.Unix(0, max(nanos, now.UnixNano()))
+		}
+
+		newTat := tat.Add(time.Duration(n) * interval)
+		res := Result{Limit: l.policy.Burst}
+		if allowAt := newTat.Add(-burst); now.Before(allowAt) {
+			res.RetryAfter = allowAt.Sub(now)
+			res.Remaining = l.tokens(now, tat, interval)
+			res.ResetAt = tat
+			return res, nil
+		}
+
+		value := []byte(strconv.FormatInt(newTat.UnixNano(), 10))
+		swapped, err := l.cache.CompareAndSwap(k, stored, value, &cache.Options{Expiration: newTat.Sub(now)})
+		if err != nil {
+			return Result{}, fmt.Errorf("[RateLimiter.tokenBucket][CompareAndSwap]%w", err)
+		}
+		if swapped {
+			res.Allowed = true
+			res.Remaining = l.tokens(now, newTat, interval)
+			res.ResetAt = newTat
+			return res, nil
+		}
+	}
+	return Result{}, ErrContention
+}
+
+// tokens returns the tokens left in a bucket that is full at tat.
+func (l *RateLimiter) tokens(now time.Time, tat time.Time, interval time.Duration) int {
+	missing := int(math.Ceil(float64(tat.Sub(now)) / float64(interval)))
+	return max(l.policy.Burst-missing, 0)
+}
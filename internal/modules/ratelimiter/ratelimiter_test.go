@@ -0,0 +1,196 @@
+package ratelimiter
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"be20250107/internal/modules/cache"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/dgraph-io/badger/v3"
+	"github.com/go-redis/redis/v8"
+)
+
+func engines(t *testing.T) map[string]cache.Cache {
+	db, err := badger.Open(badger.DefaultOptions("").WithInMemory(true).WithLogger(nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	client := redis.NewClient(&redis.Options{Addr: miniredis.RunT(t).Addr()})
+	t.Cleanup(func() { client.Close() })
+
+	return map[string]cache.Cache{
+		"memory": cache.NewInMemoryCache(nil),
+		"badger": cache.NewBadgerCache(db, nil),
+		"redis":  cache.NewRedisCache(client, context.Background(), nil),
+	}
+}
+
+// clock returns a limiter whose time only moves when advanced.
+func clock(l *RateLimiter, start time.Time) func(time.Duration) {
+	now := start
+	l.now = func() time.Time { return now }
+	return func(d time.Duration) { now = now.Add(d) }
+}
+
+func TestFixedWindow(t *testing.T) {
+	for name, c := range engines(t) {
+		t.Run(name, func(t *testing.T) {
+			l := New(c, "test", Policy{Algorithm: FixedWindow, Limit: 3, Period: time.Minute})
+			advance := clock(l, time.Unix(600, 0))
+
+			for i := 0; i < 3; i++ {
+				res, err := l.Allow("ip")
+				if err != nil {
+					t.Fatal(err)
+				}
+				if !res.Allowed || res.Remaining != 2-i {
+					t.Fatalf("request %d: expected allowed with %d remaining, got %+v", i+1, 2-i, res)
+				}
+			}
+
+			advance(10 * time.Second)
+			res, err := l.Allow("ip")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if res.Allowed || res.Remaining != 0 || res.RetryAfter != 50*time.Second {
+				t.Fatalf("expected denied with retry after 50s, got %+v", res)
+			}
+
+			advance(50 * time.Second)
+			if res, _ := l.Allow("ip"); !res.Allowed {
+				t.Fatalf("expected allowed in the next window, got %+v", res)
+			}
+		})
+	}
+}
+
+func TestSlidingWindow(t *testing.T) {
+	for name, c := range engines(t) {
+		t.Run(name, func(t *testing.T) {
+			l := New(c, "test", Policy{Algorithm: SlidingWindow, Limit: 4, Period: time.Minute})
+			advance := clock(l, time.Unix(600, 0))
+
+			for i := 0; i < 4; i++ {
+				if res, err := l.Allow("ip"); err != nil || !res.Allowed {
+					t.Fatalf("request %d: expected allowed, got %+v %v", i+1, res, err)
+				}
+			}
+
+			//	A quarter into the next window, the previous window still
+			//	counts for 3 requests.
+			advance(75 * time.Second)
+			if res, _ := l.Allow("ip"); !res.Allowed {
+				t.Fatalf("expected allowed, got %+v", res)
+			}
+			res, err := l.Allow("ip")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if res.Allowed {
+				t.Fatalf("expected denied, got %+v", res)
+			}
+			if res.RetryAfter != 15*time.Second {
+				t.Errorf("expected retry after 15s, got %s", res.RetryAfter)
+			}
+
+			advance(res.RetryAfter)
+			if res, _ := l.Allow("ip"); !res.Allowed {
+				t.Fatalf("expected allowed after waiting, got %+v", res)
+			}
+		})
+	}
+}
+
+func TestTokenBucket(t *testing.T) {
+	for name, c := range engines(t) {
+		t.Run(name, func(t *testing.T) {
+			l := New(c, "test", Policy{Algorithm: TokenBucket, Limit: 2, Period: time.Second, Burst: 4})
+			advance := clock(l, time.Now())
+
+			for i := 0; i < 4; i++ {
+				res, err := l.Allow("ip")
+				if err != nil {
+					t.Fatal(err)
+				}
+				if !res.Allowed || res.Remaining != 3-i {
+					t.Fatalf("request %d: expected allowed with %d remaining, got %+v", i+1, 3-i, res)
+				}
+			}
+
+			res, err := l.Allow("ip")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if res.Allowed || res.RetryAfter != 500*time.Millisecond {
+				t.Fatalf("expected denied with retry after 500ms, got %+v", res)
+			}
+
+			advance(500 * time.Millisecond)
+			if res, _ := l.Allow("ip"); !res.Allowed {
+				t.Fatalf("expected a refilled token, got %+v", res)
+			}
+		})
+	}
+}
+
+func TestConcurrentRequestsAreCountedOnce(t *testing.T) {
+	for name, c := range engines(t) {
+		for _, algorithm := range []Algorithm{FixedWindow, SlidingWindow, TokenBucket} {
+			t.Run(name+"/"+string(algorithm), func(t *testing.T) {
+				l := New(c, "concurrent", Policy{Algorithm: algorithm, Limit: 10, Period: time.Hour})
+
+				var allowed atomic.Int32
+				var wg sync.WaitGroup
+				for i := 0; i < 40; i++ {
+					wg.Add(1)
+					go func() {
+						defer wg.Done()
+						res, err := l.Allow(string(algorithm))
+						if err != nil && !errors.Is(err, ErrContention) {
+							t.Error(err)
+						}
+						if res.Allowed {
+							allowed.Add(1)
+						}
+					}()
+				}
+				wg.Wait()
+
+				if n := allowed.Load(); n > 10 {
+					t.Errorf("expected at most 10 allowed requests, got %d", n)
+				}
+			})
+		}
+	}
+}
+
+func TestGuard(t *testing.T) {
+	l := New(cache.NewInMemoryCache(nil), "test", Policy{Limit: 1, Period: time.Minute})
+
+	if err := l.Guard("ip"); err != nil {
+		t.Fatal(err)
+	}
+	err := l.Guard("ip")
+	var limited *LimitedError
+	if !errors.As(err, &limited) || !errors.Is(err, ErrRateLimited) {
+		t.Fatalf("expected a LimitedError, got %v", err)
+	}
+	if limited.RetryAfter() <= 0 {
+		t.Errorf("expected a positive retry after, got %s", limited.RetryAfter())
+	}
+
+	if err := l.Reset("ip"); err != nil {
+		t.Fatal(err)
+	}
+	if err := l.Guard("ip"); err != nil {
+		t.Fatalf("expected the reset key to be allowed, got %v", err)
+	}
+}
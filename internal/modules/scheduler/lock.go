@@ -0,0 +1,76 @@
+package scheduler
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sync"
+)
+
+// Locker grants a named lock to a single holder at a time.
+type Locker interface {
+	// Acquire returns whether the lock was acquired and, if it was, the
+	// function that releases it. It does not wait for the lock.
+	Acquire(name string) (release func(), acquired bool, err error)
+}
+
+// MySQLLocker uses MySQL user-level locks, which are held by a connection and
+// released when it closes, so a crashed instance cannot keep a lock.
+type MySQLLocker struct {
+	db *sql.DB
+}
+
+func NewMySQLLocker(db *sql.DB) *MySQLLocker {
+	return &MySQLLocker{db: db}
+}
+
+func (l *MySQLLocker) Acquire(name string) (func(), bool, error) {
+	ctx := context.Background()
+	conn, err := l.db.Conn(ctx)
+	if err != nil {
+		return nil, false, fmt.Errorf("[MySQLLocker.Acquire][Conn]%w", err)
+	}
+
+	var acquired sql.NullInt64
+	err = conn.QueryRowContext(ctx, "SELECT GET_LOCK(?, 0)", name).Scan(&acquired)
+	if err != nil {
+		conn.Close()
+		return nil, false, fmt.Errorf("[MySQLLocker.Acquire][GET_LOCK]%w", err)
+	}
+	if acquired.Int64 != 1 {
+		conn.Close()
+		return nil, false, nil
+	}
+
+	release := func() {
+		_, _ = conn.ExecContext(ctx, "SELECT RELEASE_LOCK(?)", name)
+		conn.Close()
+	}
+	return release, true, nil
+}
+
+// LocalLocker only excludes holders within the process. It is meant for tests
+// and single instance deployments.
+type LocalLocker struct {
+	mu    sync.Mutex
+	names map[string]bool
+}
+
+func NewLocalLocker() *LocalLocker {
+	return &LocalLocker{names: map[string]bool{}}
+}
+
+func (l *LocalLocker) Acquire(name string) (func(), bool, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.names[name] {
+		return nil, false, nil
+	}
+	l.names[name] = true
+	return func() {
+		l.mu.Lock()
+		defer l.mu.Unlock()
+		delete(l.names, name)
+	}, true, nil
+}
@@ -0,0 +1,198 @@
+// Package scheduler runs maintenance jobs on cron expressions. Jobs that touch
+// shared state take a distributed lock so that only one instance runs them,
+// and are skipped if another instance already ran them for the current
+// schedule. The status of the last run of every job is kept in a StatusStore.
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/robfig/cron/v3"
+)
+
+var ErrDuplicateJob = errors.New("job has already been added")
+
+// Job is a maintenance task. Local jobs maintain state of the instance itself,
+// such as its cache, and run on every instance without taking the lock.
+type Job struct {
+	Name    string
+	Spec    string
+	Local   bool
+	Timeout time.Duration
+	Run     func(ctx context.Context) (string, error)
+}
+
+// Status describes the last run of a job. Result is the summary returned by
+// the job and Error is set if it failed.
+type Status struct {
+	Name       string     `json:"name"`
+	Spec       string     `json:"spec"`
+	Local      bool       `json:"local"`
+	Running    bool       `json:"running"`
+	StartedAt  *time.Time `json:"started_at"`
+	FinishedAt *time.Time `json:"finished_at"`
+	Result     string     `json:"result,omitempty"`
+	Error      string     `json:"error,omitempty"`
+	NextRunAt  *time.Time `json:"next_run_at"`
+}
+
+type Scheduler struct {
+	cron   *cron.Cron
+	store  StatusStore
+	local  *MemoryStatusStore
+	locker Locker
+
+	mu      sync.Mutex
+	jobs    map[string]Job
+	entries map[string]cron.EntryID
+}
+
+// New returns a Scheduler keeping the statuses of jobs that are not local in
+// the store. Those jobs are only run when the locker grants the lock. The
+// statuses of local jobs are kept in the process.
+func New(store StatusStore, locker Locker) *Scheduler {
+	return &Scheduler{
+		cron:    cron.New(),
+		store:   store,
+		local:   NewMemoryStatusStore(),
+		locker:  locker,
+		jobs:    map[string]Job{},
+		entries: map[string]cron.EntryID{},
+	}
+}
+
+// Add schedules the job. The spec is a standard five field cron expression or
+// a descriptor such as "@every 1h".
+func (s *Scheduler) Add(job Job) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, exist := s.jobs[job.Name]; exist {
+		return fmt.Errorf("%w: %s", ErrDuplicateJob, job.Name)
+	}
+
+	id, err := s.cron.AddFunc(job.Spec, func() { s.run(job) })
+	if err != nil {
+		return fmt.Errorf("[Scheduler.Add][AddFunc] %s: %w", job.Name, err)
+	}
+	s.jobs[job.Name] = job
+	s.entries[job.Name] = id
+	return nil
+}
+
+func (s *Scheduler) Start() {
+	s.cron.Start()
+}
+
+// Stop stops scheduling jobs and waits for running ones to finish.
+func (s *Scheduler) Stop() {
+	<-s.cron.Stop().Done()
+}
+
+func (s *Scheduler) statusStore(job Job) StatusStore {
+	if job.Local {
+		return s.local
+	}
+	return s.store
+}
+
+func (s *Scheduler) run(job Job) {
+	if !job.Local && s.locker != nil {
+		release, acquired, err := s.locker.Acquire("scheduler_" + job.Name)
+		if err != nil {
+			s.putStatus(job, Status{Name: job.Name, Error: err.Error()})
+			return
+		}
+		if !acquired {
+			//	Another instance is running the job.
+			return
+		}
+		defer release()
+	}
+	if !job.Local && s.ranRecently(job) {
+		//	Another instance ran the job for the current schedule.
+		return
+	}
+
+	ctx := context.Background()
+	if job.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, job.Timeout)
+		defer cancel()
+	}
+
+	startedAt := time.Now()
+	s.putStatus(job, Status{Name: job.Name, Running: true, StartedAt: &startedAt})
+
+	result, err := s.safeRun(ctx, job)
+	finishedAt := time.Now()
+	status := Status{Name: job.Name, StartedAt: &startedAt, FinishedAt: &finishedAt, Result: result}
+	if err != nil {
+		status.Error = err.Error()
+	}
+	s.putStatus(job, status)
+}
+
+// ranRecently reports whether the job has been started since it was last due,
+// which means another instance already ran it for the current schedule. The
+// lock only keeps runs from overlapping. The job runs if its status cannot be
+// read.
+func (s *Scheduler) ranRecently(job Job) bool {
+	last, exist, err := s.store.Get(job.Name)
+	if err != nil || !exist || last.StartedAt == nil {
+		return false
+	}
+	s.mu.Lock()
+	schedule := s.cron.Entry(s.entries[job.Name]).Schedule
+	s.mu.Unlock()
+	if schedule == nil {
+		return false
+	}
+	return schedule.Next(*last.StartedAt).After(time.Now())
+}
+
+func (s *Scheduler) safeRun(ctx context.Context, job Job) (result string, err error) {
+	defer func() {
+		if rec := recover(); rec != nil {
+			err = fmt.Errorf("panic: %v", rec)
+		}
+	}()
+	return job.Run(ctx)
+}
+
+func (s *Scheduler) putStatus(job Job, status Status) {
+	_ = s.statusStore(job).Put(status)
+}
+
+// Statuses returns the status of every job, sorted by name. Jobs that have not
+// run yet only carry their schedule.
+func (s *Scheduler) Statuses() ([]Status, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	statuses := make([]Status, 0, len(s.jobs))
+	for name, job := range s.jobs {
+		status, _, err := s.statusStore(job).Get(name)
+		if err != nil {
+			return nil, fmt.Errorf("[Scheduler.Statuses]%w", err)
+		}
+
+		status.Name = name
+		status.Spec = job.Spec
+		status.Local = job.Local
+		if next := s.cron.Entry(s.entries[name]).Next; !next.IsZero() {
+			status.NextRunAt = &next
+		}
+		statuses = append(statuses, status)
+	}
+
+	sort.Slice(statuses, func(i, j int) bool {
+		return statuses[i].Name < statuses[j].Name
+	})
+	return statuses, nil
+}
@@ -0,0 +1,140 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func statusOf(t *testing.T, s *Scheduler, name string) Status {
+	t.Helper()
+	statuses, err := s.Statuses()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, status := range statuses {
+		if status.Name == name {
+			return status
+		}
+	}
+	t.Fatalf("status of %s not found", name)
+	return Status{}
+}
+
+func TestAdd(t *testing.T) {
+	s := New(NewMemoryStatusStore(), NewLocalLocker())
+	job := Job{Name: "job", Spec: "@every 1h", Run: func(context.Context) (string, error) { return "", nil }}
+
+	if err := s.Add(job); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Add(job); !errors.Is(err, ErrDuplicateJob) {
+		t.Errorf("expected ErrDuplicateJob, got %v", err)
+	}
+	if err := s.Add(Job{Name: "invalid", Spec: "every hour"}); err == nil {
+		t.Error("expected invalid spec to be rejected")
+	}
+}
+
+func TestRunRecordsStatus(t *testing.T) {
+	s := New(NewMemoryStatusStore(), NewLocalLocker())
+	ok := Job{Name: "ok", Spec: "@every 1h", Run: func(context.Context) (string, error) { return "done", nil }}
+	failing := Job{Name: "failing", Spec: "@every 1h", Run: func(context.Context) (string, error) { panic("boom") }}
+	for _, job := range []Job{ok, failing} {
+		if err := s.Add(job); err != nil {
+			t.Fatal(err)
+		}
+		s.run(job)
+	}
+
+	status := statusOf(t, s, "ok")
+	if status.Result != "done" || status.Error != "" || status.FinishedAt == nil || status.Running {
+		t.Errorf("unexpected status of successful job: %+v", status)
+	}
+	status = statusOf(t, s, "failing")
+	if status.Error != "panic: boom" {
+		t.Errorf("expected panic to be recorded, got %+v", status)
+	}
+}
+
+func TestRunSkipsLockedJob(t *testing.T) {
+	locker := NewLocalLocker()
+	s := New(NewMemoryStatusStore(), locker)
+
+	ran := false
+	job := Job{Name: "shared", Spec: "@every 1h", Run: func(context.Context) (string, error) {
+		ran = true
+		return "", nil
+	}}
+	if err := s.Add(job); err != nil {
+		t.Fatal(err)
+	}
+
+	release, acquired, err := locker.Acquire("scheduler_shared")
+	if err != nil || !acquired {
+		t.Fatalf("expected to acquire lock, got %v %v", acquired, err)
+	}
+	s.run(job)
+	if ran {
+		t.Error("expected job to be skipped while another instance holds the lock")
+	}
+
+	release()
+	s.run(job)
+	if !ran {
+		t.Error("expected job to run once the lock is released")
+	}
+}
+
+func TestRunSkipsJobRunByAnotherInstance(t *testing.T) {
+	store := NewMemoryStatusStore()
+	locker := NewLocalLocker()
+	runs := 0
+	job := Job{Name: "shared", Spec: "@every 1h", Run: func(context.Context) (string, error) {
+		runs++
+		return "", nil
+	}}
+
+	instances := []*Scheduler{New(store, locker), New(store, locker)}
+	for _, s := range instances {
+		if err := s.Add(job); err != nil {
+			t.Fatal(err)
+		}
+		s.run(job)
+	}
+	if runs != 1 {
+		t.Errorf("expected the job to run once per schedule, ran %d times", runs)
+	}
+
+	startedAt := time.Now().Add(-2 * time.Hour)
+	if err := store.Put(Status{Name: "shared", StartedAt: &startedAt}); err != nil {
+		t.Fatal(err)
+	}
+	instances[1].run(job)
+	if runs != 2 {
+		t.Errorf("expected the job to run once it is due again, ran %d times", runs)
+	}
+}
+
+func TestRunLocalJobOnEveryInstance(t *testing.T) {
+	store := NewMemoryStatusStore()
+	runs := 0
+	job := Job{Name: "local", Spec: "@every 1h", Local: true, Run: func(context.Context) (string, error) {
+		runs++
+		return "", nil
+	}}
+
+	for _, s := range []*Scheduler{New(store, NewLocalLocker()), New(store, NewLocalLocker())} {
+		if err := s.Add(job); err != nil {
+			t.Fatal(err)
+		}
+		s.run(job)
+	}
+	if runs != 2 {
+		t.Errorf("expected the local job to run on both instances, ran %d times", runs)
+	}
+	if _, exist, _ := store.Get("local"); exist {
+		t.Error("expected the status of the local job to be kept in the process")
+	}
+}
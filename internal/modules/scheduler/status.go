@@ -0,0 +1,114 @@
+package scheduler
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// StatusStore keeps the status of the last run of every job. The store of
+// jobs that are not local must be shared by the instances, since the last run
+// decides whether a job is due.
+type StatusStore interface {
+	// Get returns the status of the job and whether it has one.
+	Get(name string) (Status, bool, error)
+	Put(status Status) error
+}
+
+// MySQLStatusStore keeps statuses in the scheduler_jobs table.
+type MySQLStatusStore struct {
+	db *sql.DB
+}
+
+func NewMySQLStatusStore(db *sql.DB) *MySQLStatusStore {
+	return &MySQLStatusStore{db: db}
+}
+
+func (s *MySQLStatusStore) Get(name string) (Status, bool, error) {
+	var (
+		status     = Status{Name: name}
+		startedAt  sql.NullInt64
+		finishedAt sql.NullInt64
+		result     sql.NullString
+		errMsg     sql.NullString
+	)
+	q := "SELECT running, started_at, finished_at, result, error FROM scheduler_jobs WHERE name = ?"
+	err := s.db.QueryRowContext(context.Background(), q, name).Scan(&status.Running, &startedAt, &finishedAt, &result, &errMsg)
+	if errors.Is(err, sql.ErrNoRows) {
+		return Status{}, false, nil
+	} else if err != nil {
+		return Status{}, false, fmt.Errorf("[MySQLStatusStore.Get][Scan]%w", err)
+	}
+	status.StartedAt = unixTime(startedAt)
+	status.FinishedAt = unixTime(finishedAt)
+	status.Result = result.String
+	status.Error = errMsg.String
+	return status, true, nil
+}
+
+func (s *MySQLStatusStore) Put(status Status) error {
+	q := `
+		INSERT INTO scheduler_jobs
+		(name, running, started_at, finished_at, result, error, updated_at)
+		VALUES
+		(?, ?, ?, ?, ?, ?, ?)
+		ON DUPLICATE KEY UPDATE
+			running = VALUES(running),
+			started_at = VALUES(started_at),
+			finished_at = VALUES(finished_at),
+			result = VALUES(result),
+			error = VALUES(error),
+			updated_at = VALUES(updated_at)
+	`
+	_, err := s.db.ExecContext(context.Background(), q,
+		status.Name, status.Running, nullUnix(status.StartedAt), nullUnix(status.FinishedAt),
+		status.Result, status.Error, time.Now().Unix(),
+	)
+	if err != nil {
+		return fmt.Errorf("[MySQLStatusStore.Put][Exec]%w", err)
+	}
+	return nil
+}
+
+func unixTime(t sql.NullInt64) *time.Time {
+	if !t.Valid {
+		return nil
+	}
+	tm := time.Unix(t.Int64, 0)
+	return &tm
+}
+
+func nullUnix(t *time.Time) sql.NullInt64 {
+	if t == nil {
+		return sql.NullInt64{}
+	}
+	return sql.NullInt64{Int64: t.Unix(), Valid: true}
+}
+
+// MemoryStatusStore keeps statuses in the process. It is used for local jobs,
+// and is meant for tests and single instance deployments otherwise.
+type MemoryStatusStore struct {
+	mu       sync.Mutex
+	statuses map[string]Status
+}
+
+func NewMemoryStatusStore() *MemoryStatusStore {
+	return &MemoryStatusStore{statuses: map[string]Status{}}
+}
+
+func (s *MemoryStatusStore) Get(name string) (Status, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	status, exist := s.statuses[name]
+	return status, exist, nil
+}
+
+func (s *MemoryStatusStore) Put(status Status) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.statuses[status.Name] = status
+	return nil
+}
@@ -0,0 +1,97 @@
+// Package secretbox encrypts secrets that the application must read back, such
+// as signing secrets and TOTP secrets, before they are stored. It uses
+// AES-256-GCM with a key kept in the config rather than in the database, so
+// that read access to the database does not reveal the secrets.
+package secretbox
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// KeySize is the size of the decoded key in bytes.
+const KeySize = 32
+
+// version prefixes sealed values so that the scheme can be changed later.
+const version = "v1:"
+
+var (
+	ErrInvalidKey = errors.New("secretbox: key must be 32 bytes encoded in base64")
+	ErrMalformed  = errors.New("secretbox: sealed value is malformed")
+	ErrNoKey      = errors.New("secretbox: no encryption key is configured")
+)
+
+type Box struct {
+	aead cipher.AEAD
+}
+
+// New returns a Box for a base64 encoded key of KeySize bytes.
+func New(encodedKey string) (*Box, error) {
+	key, err := base64.StdEncoding.DecodeString(encodedKey)
+	if err != nil || len(key) != KeySize {
+		return nil, ErrInvalidKey
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("[secretbox.New][NewCipher]%w", err)
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, fmt.Errorf("[secretbox.New][NewGCM]%w", err)
+	}
+	return &Box{aead: aead}, nil
+}
+
+// GenerateKey returns a new random key encoded for New.
+func GenerateKey() (string, error) {
+	key := make([]byte, KeySize)
+	if _, err := rand.Read(key); err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(key), nil
+}
+
+// Seal encrypts the plaintext with a random nonce. A nil Box returns ErrNoKey.
+func (b *Box) Seal(plaintext string) (string, error) {
+	if b == nil {
+		return "", ErrNoKey
+	}
+	nonce := make([]byte, b.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	sealed := b.aead.Seal(nonce, nonce, []byte(plaintext), nil)
+	return version + base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+// IsSealed reports whether the value was returned by Seal, as opposed to a
+// secret stored before it was encrypted.
+func IsSealed(value string) bool {
+	return strings.HasPrefix(value, version)
+}
+
+// Open decrypts a value returned by Seal. A nil Box returns ErrNoKey.
+func (b *Box) Open(sealed string) (string, error) {
+	if b == nil {
+		return "", ErrNoKey
+	}
+	encoded, ok := strings.CutPrefix(sealed, version)
+	if !ok {
+		return "", ErrMalformed
+	}
+	data, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil || len(data) < b.aead.NonceSize() {
+		return "", ErrMalformed
+	}
+	nonce, ciphertext := data[:b.aead.NonceSize()], data[b.aead.NonceSize():]
+	plaintext, err := b.aead.Open(nil, nonce, ciphertext, nil)
+	if err != nil {
+		return "", ErrMalformed
+	}
+	return string(plaintext), nil
+}
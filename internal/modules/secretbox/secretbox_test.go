@@ -0,0 +1,65 @@
+package secretbox
+
+import (
+	"errors"
+	"testing"
+)
+
+func newBox(t *testing.T) *Box {
+	t.Helper()
+	key, err := GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	box, err := New(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return box
+}
+
+func TestSealOpen(t *testing.T) {
+	box := newBox(t)
+
+	sealed, err := box.Seal("secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sealed == "secret" {
+		t.Fatal("expected the sealed value to differ from the plaintext")
+	}
+	again, _ := box.Seal("secret")
+	if again == sealed {
+		t.Error("expected every seal to use a new nonce")
+	}
+
+	plaintext, err := box.Open(sealed)
+	if err != nil || plaintext != "secret" {
+		t.Fatalf("expected secret, got %q, %v", plaintext, err)
+	}
+
+	if _, err := newBox(t).Open(sealed); !errors.Is(err, ErrMalformed) {
+		t.Errorf("expected ErrMalformed with another key, got %v", err)
+	}
+	if _, err := box.Open("secret"); !errors.Is(err, ErrMalformed) {
+		t.Errorf("expected ErrMalformed for an unsealed value, got %v", err)
+	}
+}
+
+func TestNew(t *testing.T) {
+	for _, key := range []string{"", "c2hvcnQ=", "not base64"} {
+		if _, err := New(key); !errors.Is(err, ErrInvalidKey) {
+			t.Errorf("expected ErrInvalidKey for %q, got %v", key, err)
+		}
+	}
+}
+
+func TestNilBox(t *testing.T) {
+	var box *Box
+	if _, err := box.Seal("secret"); !errors.Is(err, ErrNoKey) {
+		t.Errorf("expected ErrNoKey, got %v", err)
+	}
+	if _, err := box.Open("v1:"); !errors.Is(err, ErrNoKey) {
+		t.Errorf("expected ErrNoKey, got %v", err)
+	}
+}
@@ -0,0 +1,65 @@
+package totp
+
+import (
+	"encoding/base32"
+	"strings"
+	"testing"
+	"time"
+)
+
+// rfcSecret is the SHA-1 key of the RFC 6238 test vectors.
+var rfcSecret = []byte("12345678901234567890")
+
+func TestCodeMatchesRFC6238(t *testing.T) {
+	vectors := map[int64]string{
+		59:          "94287082",
+		1111111109:  "07081804",
+		1111111111:  "14050471",
+		1234567890:  "89005924",
+		2000000000:  "69279037",
+		20000000000: "65353130",
+	}
+	for unix, expected := range vectors {
+		got := code(rfcSecret, Step(time.Unix(unix, 0)), 8)
+		if got != expected {
+			t.Errorf("at %d expected %s, got %s", unix, expected, got)
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	secret := base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString(rfcSecret)
+	now := time.Unix(1111111109, 0)
+
+	current, err := Code(secret, Step(now))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if current != "081804" {
+		t.Fatalf("expected 6 digit code 081804, got %s", current)
+	}
+
+	if step, ok := Validate(secret, current, now, 1); !ok || step != Step(now) {
+		t.Errorf("expected current code to be valid at step %d, got %d %v", Step(now), step, ok)
+	}
+	if _, ok := Validate(secret, current, now.Add(Period), 1); !ok {
+		t.Error("expected code of the previous step to be valid within skew")
+	}
+	if _, ok := Validate(secret, current, now.Add(2*Period), 1); ok {
+		t.Error("expected code older than the skew to be invalid")
+	}
+	if _, ok := Validate(secret, "12345", now, 1); ok {
+		t.Error("expected code of wrong length to be invalid")
+	}
+}
+
+func TestProvisioningURI(t *testing.T) {
+	secret, err := GenerateSecret()
+	if err != nil {
+		t.Fatal(err)
+	}
+	uri := ProvisioningURI("HOKI", "admin", secret)
+	if !strings.HasPrefix(uri, "otpauth://totp/HOKI:admin?") || !strings.Contains(uri, "secret="+secret) {
+		t.Errorf("unexpected provisioning URI %s", uri)
+	}
+}
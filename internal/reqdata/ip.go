@@ -0,0 +1,92 @@
+package reqdata
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"sync/atomic"
+)
+
+var trustedProxies atomic.Pointer[[]*net.IPNet]
+
+// SetTrustedProxies sets the networks of the reverse proxies whose forwarding
+// headers are believed. Each entry is a CIDR or a single IP address. Without
+// trusted proxies the headers are ignored and the peer address is used.
+func SetTrustedProxies(proxies []string) error {
+	nets := make([]*net.IPNet, 0, len(proxies))
+	for _, p := range proxies {
+		if !strings.Contains(p, "/") {
+			ip := net.ParseIP(p)
+			if ip == nil {
+				return fmt.Errorf("invalid trusted proxy: %s", p)
+			}
+			bits := 8 * net.IPv6len
+			if ip.To4() != nil {
+				ip, bits = ip.To4(), 8*net.IPv4len
+			}
+			nets = append(nets, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+		_, n, err := net.ParseCIDR(p)
+		if err != nil {
+			return fmt.Errorf("invalid trusted proxy: %s", p)
+		}
+		nets = append(nets, n)
+	}
+	trustedProxies.Store(&nets)
+	return nil
+}
+
+func isTrustedProxy(addr string) bool {
+	nets := trustedProxies.Load()
+	if nets == nil {
+		return false
+	}
+	ip := net.ParseIP(addr)
+	if ip == nil {
+		return false
+	}
+	for _, n := range *nets {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// ResolveIP returns the client IP address. The forwarding headers are only
+// believed when the direct peer is a trusted proxy; then the rightmost
+// X-Forwarded-For hop that is not a trusted proxy is the client, since hops to
+// its left may have been sent by the client itself. X-Real-IP is used when the
+// proxy does not set X-Forwarded-For.
+func ResolveIP(realIP string, forwardedFor string, remoteAddr string) string {
+	peer := remoteAddr
+	if host, _, err := net.SplitHostPort(remoteAddr); err == nil {
+		peer = host
+	}
+	if !isTrustedProxy(peer) {
+		return peer
+	}
+
+	hops := strings.Split(forwardedFor, ",")
+	for i := len(hops) - 1; i >= 0; i-- {
+		hop := strings.TrimSpace(hops[i])
+		if hop == "" {
+			continue
+		}
+		if !isTrustedProxy(hop) {
+			return hop
+		}
+		peer = hop
+	}
+	if ip := strings.TrimSpace(realIP); ip != "" && forwardedFor == "" {
+		return ip
+	}
+	return peer
+}
+
+// ClientIP returns the client IP address of the request.
+func ClientIP(r *http.Request) string {
+	return ResolveIP(r.Header.Get("X-Real-IP"), r.Header.Get("X-Forwarded-For"), r.RemoteAddr)
+}
@@ -0,0 +1,44 @@
+package reqdata
+
+import "testing"
+
+func TestResolveIP(t *testing.T) {
+	if err := SetTrustedProxies([]string{"10.0.0.0/8", "127.0.0.1"}); err != nil {
+		t.Fatal(err)
+	}
+	defer SetTrustedProxies(nil)
+
+	tests := []struct {
+		name         string
+		realIP       string
+		forwardedFor string
+		remoteAddr   string
+		want         string
+	}{
+		{"untrusted peer ignores headers", "1.1.1.1", "2.2.2.2", "203.0.113.9:1234", "203.0.113.9"},
+		{"trusted peer without headers", "", "", "10.0.0.1:1234", "10.0.0.1"},
+		{"rightmost untrusted hop", "", "6.6.6.6, 198.51.100.7", "10.0.0.1:1234", "198.51.100.7"},
+		{"trusted hops are skipped", "", "6.6.6.6, 198.51.100.7, 10.0.0.2", "127.0.0.1:1234", "198.51.100.7"},
+		{"real ip without forwarded for", "198.51.100.7", "", "10.0.0.1:1234", "198.51.100.7"},
+		{"forwarded for wins over real ip", "6.6.6.6", "198.51.100.7", "10.0.0.1:1234", "198.51.100.7"},
+		{"only trusted hops", "", "10.0.0.3", "10.0.0.1:1234", "10.0.0.3"},
+	}
+	for _, tt := range tests {
+		if got := ResolveIP(tt.realIP, tt.forwardedFor, tt.remoteAddr); got != tt.want {
+			t.Errorf("%s: expected %s, got %s", tt.name, tt.want, got)
+		}
+	}
+}
+
+func TestSetTrustedProxies(t *testing.T) {
+	defer SetTrustedProxies(nil)
+	if err := SetTrustedProxies([]string{"not an ip"}); err == nil {
+		t.Error("expected an error for an invalid proxy")
+	}
+	if err := SetTrustedProxies([]string{"::1", "fd00::/8"}); err != nil {
+		t.Fatal(err)
+	}
+	if got := ResolveIP("", "198.51.100.7", "[::1]:1234"); got != "198.51.100.7" {
+		t.Errorf("expected the forwarded address behind an IPv6 proxy, got %s", got)
+	}
+}
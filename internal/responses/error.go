@@ -76,6 +76,19 @@ func Forbidden(w http.ResponseWriter) {
 	})
 }
 
+func TwoFactorRequired(w http.ResponseWriter) {
+	commonErrorHeader(w)
+	w.WriteHeader(http.StatusForbidden)
+	_ = json.NewEncoder(w).Encode(struct {
+		ErrorData
+	}{
+		ErrorData: ErrorData{
+			ErrorCode: "two_factor_required",
+			Message:   "pass the two-factor challenge before accessing this resource",
+		},
+	})
+}
+
 func NotFound(w http.ResponseWriter, u *url.URL) {
 	commonErrorHeader(w)
 	w.WriteHeader(http.StatusNotFound)
@@ -0,0 +1,14 @@
+package responses
+
+import "gopkg.in/guregu/null.v4"
+
+type Session struct {
+	ID           string      `json:"id"`
+	AuthProvider null.String `json:"auth_provider"`
+	DeviceID     null.String `json:"device_id"`
+	IPAddress    null.String `json:"ip_address"`
+	UserAgent    null.String `json:"user_agent"`
+	Current      bool        `json:"current"`
+	CreatedAt    int64       `json:"created_at"`
+	ExpiredAt    null.Time   `json:"expired_at"`
+}
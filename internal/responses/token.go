@@ -4,4 +4,10 @@ type AuthToken struct {
 	AccessToken string `json:"access_token"`
 	TokenType   string `json:"token_type"`
 	ExpiresIn   int    `json:"expires_in"`
+	Scope       string `json:"scope,omitempty"`
+	// NewDevice is set when an admin logs in from a device not seen before.
+	NewDevice bool `json:"new_device,omitempty"`
+	// TwoFactorPending is set when the token only grants access to the
+	// two-factor routes until the admin passes the challenge.
+	TwoFactorPending bool `json:"two_factor_pending,omitempty"`
 }
@@ -3,6 +3,7 @@ package routes
 import (
 	"be20250107/internal/app"
 	"be20250107/internal/controllers/public"
+	"be20250107/internal/middlewares"
 
 	"github.com/go-chi/chi/v5"
 )
@@ -11,6 +12,18 @@ func RegisterAccountRoutes(root chi.Router, app *app.Registry) {
 	root.Route("/accounts", func(r chi.Router) {
 		accountController := public.NewAccountController(app)
 
-		r.Get("/check-account-duplicate", accountController.CheckDuplicate)
+		//	Duplicate lookups are limited per client IP so that they cannot be
+		//	used to enumerate accounts and contacts.
+
+		r.With(middlewares.RateLimitMiddleware(app, "lookup")).Get("/check-account-duplicate", accountController.CheckDuplicate)
+		r.With(middlewares.AdminAuthMiddleware(app)).Get("/check-admin-duplicate", accountController.CheckAdminDuplicate)
+	})
+}
+
+func RegisterContactRoutes(root chi.Router, app *app.Registry) {
+	root.Route("/contacts", func(r chi.Router) {
+		contactController := public.NewContactController(app)
+
+		r.With(middlewares.RateLimitMiddleware(app, "lookup")).Get("/check-contact-duplicate", contactController.CheckDuplicate)
 	})
 }
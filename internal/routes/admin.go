@@ -0,0 +1,87 @@
+package routes
+
+import (
+	"be20250107/internal/app"
+	"be20250107/internal/controllers/admin"
+	"be20250107/internal/middlewares"
+
+	"github.com/go-chi/chi/v5"
+)
+
+func RegisterAdminRoutes(root chi.Router, app *app.Registry) {
+	root.Route("/admin", func(r chi.Router) {
+		r.Use(middlewares.AdminAuthMiddleware(app))
+		r.Use(middlewares.StrictRevocationMiddleware(app))
+		r.Use(middlewares.RateLimitMiddleware(app, "admin"))
+		r.Mount("/systems", SystemRoutes(app))
+		r.Mount("/api-keys", APIKeyRoutes(app))
+		r.Mount("/lockouts", LockoutRoutes(app))
+		r.Mount("/login-logs", LoginLogRoutes(app))
+		r.Mount("/jobs", JobRoutes(app))
+		r.Mount("/roles", RoleRoutes(app))
+	})
+}
+
+func SystemRoutes(app *app.Registry) chi.Router {
+	controller := admin.NewSystemController(app)
+	r := chi.NewRouter()
+
+	r.Get("/", controller.Index)
+	r.Post("/", controller.Create)
+	r.Get("/{SystemID}", controller.Show)
+	r.Post("/{SystemID}/secret", controller.RotateSecret)
+	r.Delete("/{SystemID}", controller.Revoke)
+
+	return r
+}
+
+func APIKeyRoutes(app *app.Registry) chi.Router {
+	controller := admin.NewAPIKeyController(app)
+	r := chi.NewRouter()
+
+	r.Get("/", controller.Index)
+	r.Post("/", controller.Create)
+	r.Get("/{APIKeyID}", controller.Show)
+	r.Delete("/{APIKeyID}", controller.Revoke)
+
+	return r
+}
+
+func LockoutRoutes(app *app.Registry) chi.Router {
+	controller := admin.NewLockoutController(app)
+	r := chi.NewRouter()
+
+	r.Get("/", controller.Show)
+	r.Delete("/", controller.Clear)
+
+	return r
+}
+
+func LoginLogRoutes(app *app.Registry) chi.Router {
+	controller := admin.NewLoginLogController(app)
+	r := chi.NewRouter()
+
+	r.Get("/", controller.Index)
+
+	return r
+}
+
+func JobRoutes(app *app.Registry) chi.Router {
+	controller := admin.NewJobController(app)
+	r := chi.NewRouter()
+
+	r.Get("/", controller.Index)
+
+	return r
+}
+
+func RoleRoutes(app *app.Registry) chi.Router {
+	controller := admin.NewRoleController(app)
+	r := chi.NewRouter()
+
+	r.Get("/", controller.Index)
+	r.Get("/{RoleID}", controller.Show)
+	r.Put("/{RoleID}/two-factor", controller.UpdateTwoFactor)
+
+	return r
+}
@@ -10,20 +10,60 @@ import (
 
 func RegisterAuthRoutes(root chi.Router, app *app.Registry) {
 	root.Route("/auth", func(r chi.Router) {
+		r.Use(middlewares.RateLimitMiddleware(app, "auth"))
 		r.Mount("/admin", AdminAuthRoutes(app))
+		r.Mount("/system", SystemAuthRoutes(app))
+		r.Mount("/user", UserAuthRoutes(app))
 	})
 }
 
+func UserAuthRoutes(app *app.Registry) chi.Router {
+	controller := auth.NewAuthUserController(app)
+	r := chi.NewRouter()
+
+	r.Post("/code", controller.RequestCode)
+	r.Post("/", controller.LoginByCode)
+
+	return r
+}
+
+func SystemAuthRoutes(app *app.Registry) chi.Router {
+	controller := auth.NewAuthSystemController(app)
+	r := chi.NewRouter()
+
+	r.Post("/token", controller.Token)
+
+	return r
+}
+
 func AdminAuthRoutes(app *app.Registry) chi.Router {
 	controller := auth.NewAuthAdminController(app)
 	r := chi.NewRouter()
 
-	r.Post("/", controller.LoginByXinchuanAuth)
+	r.Post("/", controller.Login)
+	r.Get("/providers", controller.Providers)
+	r.Get("/providers/{Provider}/authorize", controller.AuthorizeURL)
+	r.Post("/providers/{Provider}", controller.Login)
 
 	r.Group(func(r chi.Router) {
-		r.Use(middlewares.AdminAuthMiddleware(app))
+		r.Use(middlewares.AdminPartialAuthMiddleware(app))
 		r.Get("/", controller.Me)
 		r.Delete("/", controller.Logout)
+
+		r.Post("/2fa/enroll", controller.TwoFactorEnroll)
+		r.Post("/2fa/activate", controller.TwoFactorActivate)
+		r.Post("/2fa/challenge", controller.TwoFactorChallenge)
+	})
+
+	r.Group(func(r chi.Router) {
+		r.Use(middlewares.AdminAuthMiddleware(app))
+		r.Use(middlewares.StrictRevocationMiddleware(app))
+		r.Post("/2fa/recovery-codes", controller.TwoFactorRecoveryCodes)
+		r.Delete("/2fa", controller.TwoFactorDisable)
+
+		r.Get("/sessions", controller.Sessions)
+		r.Delete("/sessions", controller.RevokeAllSessions)
+		r.Delete("/sessions/{SessionID}", controller.RevokeSession)
 	})
 
 	return r
@@ -5,6 +5,7 @@ import (
 
 	"be20250107/internal/app"
 	"be20250107/internal/middlewares"
+	"be20250107/internal/models"
 
 	"github.com/go-chi/chi/v5"
 )
@@ -15,11 +16,19 @@ func RegisterCatalogueRoutes(root chi.Router, app *app.Registry) {
 	root.Route("/catalogues", func(r chi.Router) {
 		r.Group(func(r chi.Router) {
 			r.Use(middlewares.AuthMiddleware(app))
-			r.Post("/", CatalogueController.CreateCatalogue)
+			r.Use(middlewares.ScopeMiddleware(models.ScopeCatalogueRead))
+			r.Use(middlewares.RateLimitMiddleware(app, "catalogue_read"))
 			r.Get("/{CatalogueID}", CatalogueController.GetCatalogue)
+			r.Get("/", CatalogueController.GetCatalogues)
+		})
+
+		r.Group(func(r chi.Router) {
+			r.Use(middlewares.AuthMiddleware(app))
+			r.Use(middlewares.AccountTypeMiddleware(models.AccountTypeAdmin, models.AccountTypeUser))
+			r.Use(middlewares.RateLimitMiddleware(app, "catalogue_write"))
+			r.Post("/", CatalogueController.CreateCatalogue)
 			r.Patch("/{CatalogueID}", CatalogueController.UpdateCatalogue)
 			r.Delete("/{CatalogueID}", CatalogueController.DeleteCatalogue)
-			r.Get("/", CatalogueController.GetCatalogues)
 		})
 	})
 }
@@ -10,6 +10,7 @@ import (
 	"github.com/golang-migrate/migrate/v4"
 	"github.com/golang-migrate/migrate/v4/source/iofs"
 
+	"be20250107/internal/modules/cache"
 	"be20250107/internal/routes"
 
 	"github.com/golang-migrate/migrate/v4/database/mysql"
@@ -24,14 +25,28 @@ func (s *Server) BeforeStart() {
 }
 
 func (s *Server) AfterStart() {
-
+	if err := s.subscribeRevocations(); err != nil {
+		s.App.Log.Error(fmt.Sprintf("[Server.AfterStart] revocation broadcasts unavailable: %v", err))
+	}
+	if tiered, ok := s.App.Cache.(*cache.TieredCache); ok {
+		if err := s.subscribeCacheInvalidations(tiered); err != nil {
+			s.App.Log.Error(fmt.Sprintf("[Server.AfterStart] cache invalidations unavailable: %v", err))
+		}
+	}
+	if interval := s.App.Auth.RevocationResyncInterval; interval > 0 {
+		go s.resyncRevocations(interval)
+	}
+	s.startConsumers()
+	s.scheduleJobs()
 }
 
 func (s *Server) RegisterRoutes() []RouteRegister {
 	return []RouteRegister{
 		routes.RegisterAccountRoutes,
+		routes.RegisterAdminRoutes,
 		routes.RegisterAuthRoutes,
 		routes.RegisterCatalogueRoutes,
+		routes.RegisterContactRoutes,
 		routes.RegisterGeneralRoutes,
 	}
 }
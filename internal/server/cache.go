@@ -0,0 +1,19 @@
+package server
+
+import (
+	"be20250107/internal/modules/cache"
+	"be20250107/internal/modules/mq"
+)
+
+// subscribeCacheInvalidations drops the keys changed by other instances from
+// the local tier of a tiered cache.
+func (s *Server) subscribeCacheInvalidations(tiered *cache.TieredCache) error {
+	return mq.Handle(s.App.Consumers, mq.CacheInvalidatedTopic, broadcastChannel("cache"), func(msg mq.CacheInvalidatedMsg) error {
+		tiered.HandleInvalidation(cache.Invalidation{
+			Origin: msg.Origin,
+			Keys:   msg.Keys,
+			Flush:  msg.Flush,
+		})
+		return nil
+	})
+}
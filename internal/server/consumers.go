@@ -0,0 +1,23 @@
+package server
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/oklog/ulid/v2"
+)
+
+// broadcastChannel returns a channel of its own for this instance, so that
+// every instance receives every message of the topic. The channel is
+// ephemeral so that nsqd drops it once the instance is gone.
+func broadcastChannel(name string) string {
+	return fmt.Sprintf("%s_%s#ephemeral", name, strings.ToLower(ulid.Make().String()))
+}
+
+// startConsumers subscribes the handlers registered on App.Consumers. Their
+// messages are drained when the message bus is stopped on shutdown.
+func (s *Server) startConsumers() {
+	if err := s.App.Consumers.Start(); err != nil {
+		s.App.Log.Error(fmt.Sprintf("[Server.startConsumers] start: %v", err))
+	}
+}
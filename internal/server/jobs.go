@@ -0,0 +1,67 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"be20250107/internal/models"
+	"be20250107/internal/modules/cache"
+	"be20250107/internal/modules/scheduler"
+)
+
+const pruneBatchSize = 1000
+
+// scheduleJobs adds the maintenance jobs that have a cron expression in the
+// config and starts the scheduler.
+func (s *Server) scheduleJobs() {
+	cfg := s.App.Config.Scheduler
+	if !cfg.Enabled {
+		return
+	}
+
+	jobs := []scheduler.Job{
+		{Name: "prune_access_tokens", Timeout: 30 * time.Minute, Run: s.pruneAccessTokens},
+		{Name: "sync_admins", Timeout: 30 * time.Minute, Run: s.syncAdmins},
+	}
+	if cfg.LoginLogRetention > 0 {
+		jobs = append(jobs, scheduler.Job{Name: "prune_login_logs", Timeout: 30 * time.Minute, Run: s.pruneLoginLogs})
+	}
+	if pruner, ok := s.App.Cache.(cache.Pruner); ok {
+		jobs = append(jobs, scheduler.Job{Name: "prune_cache", Local: true, Run: func(context.Context) (string, error) {
+			return "", pruner.PruneExpiredKeys()
+		}})
+	}
+	if gc, ok := cache.Engine(s.App.Cache).(cache.GarbageCollector); ok {
+		jobs = append(jobs, scheduler.Job{Name: "badger_gc", Local: true, Run: func(context.Context) (string, error) {
+			return "", gc.RunGC()
+		}})
+	}
+
+	for _, job := range jobs {
+		job.Spec = cfg.Jobs[job.Name]
+		if interval := s.App.Auth.SyncInterval; job.Name == "sync_admins" && job.Spec == "" && interval > 0 {
+			job.Spec = "@every " + interval.String()
+		}
+		if job.Spec == "" {
+			continue
+		}
+		if err := s.App.Scheduler.Add(job); err != nil {
+			log.Println("[Server.scheduleJobs] add:", err)
+		}
+	}
+	s.App.Scheduler.Start()
+}
+
+func (s *Server) pruneAccessTokens(ctx context.Context) (string, error) {
+	before := time.Now().Add(-s.App.Config.Scheduler.TokenRetention)
+	n, err := models.DeleteExpiredAccessTokens(ctx, s.App.DB, before, pruneBatchSize)
+	return fmt.Sprintf("deleted %d access tokens", n), err
+}
+
+func (s *Server) pruneLoginLogs(ctx context.Context) (string, error) {
+	before := time.Now().Add(-s.App.Config.Scheduler.LoginLogRetention)
+	n, err := models.DeleteLoginLogs(ctx, s.App.DB, before, pruneBatchSize)
+	return fmt.Sprintf("deleted %d login logs", n), err
+}
@@ -0,0 +1,41 @@
+package server
+
+import (
+	"log"
+	"time"
+
+	"be20250107/internal/modules/mq"
+
+	"gopkg.in/guregu/null.v4"
+)
+
+// subscribeRevocations applies the revocations broadcast by other instances to
+// the cache of this one.
+func (s *Server) subscribeRevocations() error {
+	return mq.Handle(s.App.Consumers, mq.TokenRevokedTopic, broadcastChannel("revocation"), func(msg mq.TokenRevokedMsg) error {
+		expiredAt := null.Time{}
+		if msg.ExpiredAt != nil {
+			expiredAt = null.TimeFrom(time.Unix(*msg.ExpiredAt, 0))
+		}
+		return s.App.Auth.ApplyRevocation(msg.TokenID, expiredAt)
+	})
+}
+
+// resyncRevocations periodically reloads the tokens revoked since the last run
+// in case a broadcast was missed.
+func (s *Server) resyncRevocations(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	since := time.Now()
+	for range ticker.C {
+		//	Overlap the previous run to allow for clock skew between instances
+		//	and the database.
+		next := time.Now()
+		if err := s.App.Auth.LoadRevocationsSince(since.Add(-interval)); err != nil {
+			log.Println("[Server.resyncRevocations] load:", err)
+			continue
+		}
+		since = next
+	}
+}
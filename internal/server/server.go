@@ -12,6 +12,7 @@ import (
 	"be20250107/internal/app"
 	"be20250107/internal/config"
 	"be20250107/internal/middlewares"
+	"be20250107/internal/reqdata"
 
 	"github.com/go-chi/chi/v5/middleware"
 	"github.com/go-chi/cors"
@@ -34,6 +35,9 @@ func New() *Server {
 
 func NewWithConfig(cfg *config.Config) *Server {
 	registry := app.NewRegistry(cfg, "main")
+	if err := reqdata.SetTrustedProxies(cfg.Public.Listen.TrustedProxies); err != nil {
+		panic(err.Error())
+	}
 
 	router := chi.NewRouter()
 	router.Use(middlewares.MetricsMiddleware(cfg.Public.PrometheusAPIJobName))
@@ -41,7 +45,10 @@ func NewWithConfig(cfg *config.Config) *Server {
 	router.Use(cors.Handler(cors.Options{
 		AllowedOrigins: []string{"*"},
 		AllowedMethods: []string{"HEAD", "GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"},
-		AllowedHeaders: []string{"Accept", "Authorization", "Content-Type", "X-Geolocation"},
+		AllowedHeaders: []string{
+			"Accept", "Authorization", "Content-Type", "X-Geolocation", "X-API-Key", "X-Device-Fingerprint",
+			"X-Signature", "X-Signature-System", "X-Signature-Timestamp", "X-Signature-Nonce",
+		},
 	}))
 	router.Use(middleware.Logger)
 	router.Use(middleware.StripSlashes)
@@ -97,4 +104,7 @@ func (s *Server) Shutdown() {
 			log.Fatalf("Server shutdown failed: %+v", err)
 		}
 	}
+
+	s.App.Scheduler.Stop()
+	s.App.MessageBus.Stop()
 }
@@ -0,0 +1,24 @@
+package server
+
+import (
+	"context"
+	"fmt"
+
+	"be20250107/internal/modules/mq"
+)
+
+// syncAdmins syncs admins with their identity provider and publishes an update
+// for every admin that has changed.
+func (s *Server) syncAdmins(ctx context.Context) (string, error) {
+	changed, err := s.App.Auth.SyncProviderAdmins(ctx)
+
+	for _, id := range changed {
+		err := mq.PublishMessage(s.App.MessageBus, mq.AdminUpdatedTopic, mq.AdminUpdatedMsg{
+			AdminID: id,
+		})
+		if err != nil {
+			s.App.Log.Error(fmt.Sprintf("[Server.syncAdmins] publish updated: %v", err))
+		}
+	}
+	return fmt.Sprintf("changed %d admins", len(changed)), err
+}
@@ -0,0 +1,5 @@
+ALTER TABLE admin_access_tokens
+    DROP COLUMN auth_provider,
+    DROP COLUMN device_id,
+    DROP COLUMN ip_address,
+    DROP COLUMN user_agent;
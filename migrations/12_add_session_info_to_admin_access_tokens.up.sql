@@ -0,0 +1,5 @@
+ALTER TABLE admin_access_tokens
+    ADD COLUMN auth_provider VARCHAR(64) NULL AFTER admin_id,
+    ADD COLUMN device_id VARCHAR(255) NULL AFTER auth_provider,
+    ADD COLUMN ip_address VARCHAR(64) NULL AFTER device_id,
+    ADD COLUMN user_agent TEXT NULL AFTER ip_address;
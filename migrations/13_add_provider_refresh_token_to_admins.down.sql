@@ -0,0 +1,2 @@
+ALTER TABLE admins
+    DROP COLUMN provider_refresh_token;
@@ -0,0 +1,2 @@
+ALTER TABLE admins
+    ADD COLUMN provider_refresh_token TEXT NULL AFTER provider_id;
@@ -0,0 +1,3 @@
+ALTER TABLE systems
+    DROP COLUMN scopes,
+    DROP COLUMN revoked_at;
@@ -0,0 +1,3 @@
+ALTER TABLE systems
+    ADD COLUMN scopes VARCHAR(1024) NOT NULL DEFAULT '' AFTER secret_key,
+    ADD COLUMN revoked_at DATETIME NULL AFTER scopes;
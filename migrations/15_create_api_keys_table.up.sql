@@ -0,0 +1,26 @@
+CREATE TABLE IF NOT EXISTS api_keys (
+    id VARCHAR(191) PRIMARY KEY,
+    name VARCHAR(255) NOT NULL,
+    prefix VARCHAR(16) NOT NULL,
+    key_hash VARCHAR(64) NOT NULL,
+    scopes TEXT,
+    allowed_ips TEXT NULL,
+    daily_quota INT NULL,
+    created_by VARCHAR(191) NULL,
+    last_used_at DATETIME NULL,
+    expired_at DATETIME NULL,
+    revoked_at DATETIME NULL,
+    created_at BIGINT(19),
+    updated_at BIGINT(19),
+    UNIQUE INDEX api_keys_key_hash(key_hash),
+    FOREIGN KEY (created_by) REFERENCES admins(id)
+);
+
+CREATE TABLE IF NOT EXISTS api_key_usages (
+    api_key_id VARCHAR(191) NOT NULL,
+    date DATE NOT NULL,
+    request_count BIGINT NOT NULL DEFAULT 0,
+    rejected_count BIGINT NOT NULL DEFAULT 0,
+    PRIMARY KEY (api_key_id, date),
+    FOREIGN KEY (api_key_id) REFERENCES api_keys(id)
+);
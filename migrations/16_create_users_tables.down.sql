@@ -0,0 +1,4 @@
+DROP TABLE IF EXISTS user_access_tokens;
+DROP TABLE IF EXISTS user_contacts;
+DROP TABLE IF EXISTS users;
+DROP TABLE IF EXISTS user_clients;
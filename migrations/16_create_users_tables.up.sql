@@ -0,0 +1,42 @@
+CREATE TABLE IF NOT EXISTS user_clients (
+    id VARCHAR(191) PRIMARY KEY,
+    name VARCHAR(255) NOT NULL,
+    secret VARCHAR(255) NOT NULL,
+    created_at BIGINT(19),
+    updated_at BIGINT(19),
+    deleted_at BIGINT(19) NULL
+);
+
+CREATE TABLE IF NOT EXISTS users (
+    id VARCHAR(191) PRIMARY KEY,
+    name VARCHAR(255) NOT NULL DEFAULT '',
+    deactivated_at DATETIME NULL,
+    created_at BIGINT(19),
+    updated_at BIGINT(19)
+);
+
+CREATE TABLE IF NOT EXISTS user_contacts (
+    id VARCHAR(191) PRIMARY KEY,
+    user_id VARCHAR(191) NOT NULL,
+    medium VARCHAR(32) NOT NULL,
+    value VARCHAR(255) NOT NULL,
+    verified_at DATETIME NULL,
+    created_at BIGINT(19),
+    updated_at BIGINT(19),
+    UNIQUE INDEX user_contacts_medium_value(medium, value),
+    FOREIGN KEY (user_id) REFERENCES users(id)
+);
+
+CREATE TABLE IF NOT EXISTS user_access_tokens (
+    id VARCHAR(191) PRIMARY KEY,
+    user_id VARCHAR(191) NOT NULL,
+    client_id VARCHAR(191) NULL,
+    device_id VARCHAR(255) NULL,
+    ip_address VARCHAR(64) NULL,
+    user_agent TEXT NULL,
+    revoked_at DATETIME NULL,
+    expired_at DATETIME NULL,
+    created_at BIGINT(19),
+    updated_at BIGINT(19),
+    FOREIGN KEY (user_id) REFERENCES users(id)
+);
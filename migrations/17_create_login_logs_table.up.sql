@@ -0,0 +1,20 @@
+CREATE TABLE IF NOT EXISTS login_logs (
+    id VARCHAR(191) PRIMARY KEY,
+    account_type VARCHAR(32) NOT NULL,
+    account_id VARCHAR(191) NULL,
+    credential VARCHAR(255) NULL,
+    auth_provider VARCHAR(64) NULL,
+    successful TINYINT(1) NOT NULL,
+    failure_reason VARCHAR(64) NULL,
+    ip_address VARCHAR(64) NULL,
+    user_agent TEXT NULL,
+    client_id VARCHAR(191) NULL,
+    device_id VARCHAR(255) NULL,
+    fingerprint VARCHAR(255) NULL,
+    access_token_id VARCHAR(191) NULL,
+    additional_info JSON NULL,
+    created_at BIGINT(19),
+    updated_at BIGINT(19),
+    INDEX login_logs_account(account_type, account_id, created_at),
+    INDEX login_logs_ip_address(ip_address, created_at)
+);
@@ -0,0 +1,8 @@
+DROP TABLE IF EXISTS admin_recovery_codes;
+
+ALTER TABLE roles
+    DROP COLUMN require_two_factor;
+
+ALTER TABLE admins
+    DROP COLUMN totp_enabled_at,
+    DROP COLUMN totp_secret;
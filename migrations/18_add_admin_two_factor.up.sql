@@ -0,0 +1,17 @@
+ALTER TABLE admins
+    ADD COLUMN totp_secret VARCHAR(255) NULL AFTER provider_refresh_token,
+    ADD COLUMN totp_enabled_at DATETIME NULL AFTER totp_secret;
+
+ALTER TABLE roles
+    ADD COLUMN require_two_factor TINYINT(1) NOT NULL DEFAULT 0 AFTER `name`;
+
+CREATE TABLE IF NOT EXISTS admin_recovery_codes (
+    id VARCHAR(191) PRIMARY KEY,
+    admin_id VARCHAR(191) NOT NULL,
+    code_hash CHAR(64) NOT NULL,
+    used_at DATETIME NULL,
+    created_at BIGINT(19),
+    updated_at BIGINT(19),
+    INDEX admin_recovery_codes_admin_id_code_hash(admin_id, code_hash),
+    FOREIGN KEY (admin_id) REFERENCES admins(id)
+);
@@ -0,0 +1,14 @@
+CREATE TABLE IF NOT EXISTS audit_logs (
+    id VARCHAR(191) PRIMARY KEY,
+    event VARCHAR(64) NOT NULL,
+    account_type VARCHAR(32) NULL,
+    account_id VARCHAR(191) NULL,
+    token_id VARCHAR(191) NULL,
+    ip_address VARCHAR(64) NULL,
+    user_agent TEXT NULL,
+    data JSON NULL,
+    created_at BIGINT(19),
+    updated_at BIGINT(19),
+    INDEX audit_logs_event(event, created_at),
+    INDEX audit_logs_account(account_type, account_id, created_at)
+);
@@ -0,0 +1,2 @@
+ALTER TABLE admins
+    DROP COLUMN deactivated_by_provider;
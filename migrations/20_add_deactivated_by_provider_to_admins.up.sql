@@ -0,0 +1,2 @@
+ALTER TABLE admins
+    ADD COLUMN deactivated_by_provider TINYINT(1) NOT NULL DEFAULT 0 AFTER deactivated_at;
@@ -0,0 +1,2 @@
+ALTER TABLE systems
+    DROP COLUMN signing_secret;
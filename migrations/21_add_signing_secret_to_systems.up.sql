@@ -0,0 +1,2 @@
+ALTER TABLE systems
+    ADD COLUMN signing_secret VARCHAR(255) NULL AFTER secret_key;
@@ -0,0 +1,9 @@
+CREATE TABLE IF NOT EXISTS scheduler_jobs (
+    name VARCHAR(191) PRIMARY KEY,
+    running TINYINT(1) NOT NULL DEFAULT 0,
+    started_at BIGINT(19) NULL,
+    finished_at BIGINT(19) NULL,
+    result TEXT NULL,
+    error TEXT NULL,
+    updated_at BIGINT(19)
+);
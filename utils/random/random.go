@@ -1,23 +1,27 @@
 package random
 
 import (
-	"math/rand"
+	"crypto/rand"
+	"math/big"
 	"strings"
-	"time"
 )
 
 const NumericCharset = "0123456789"
 const UppercaseAlphabeticCharset = "ABCDEFGHIJKLMNOPQRSTUVWXYZ"
 const LowercaseAlphabeticCharset = "abcdefghijklmnopqrstuvwxyz"
 
-var rnd = rand.New(rand.NewSource(time.Now().Unix()))
-
+// GenerateString returns a random string drawn from charset using a
+// cryptographically secure source, so it can be used for secrets and codes.
 func GenerateString(length int, charset string) string {
 	var str strings.Builder
 	set := []rune(charset)
+	max := big.NewInt(int64(len(set)))
 	for i := 0; i < length; i++ {
-		idx := rnd.Intn(len(set))
-		str.WriteRune(set[idx])
+		idx, err := rand.Int(rand.Reader, max)
+		if err != nil {
+			panic(err)
+		}
+		str.WriteRune(set[idx.Int64()])
 	}
 	return str.String()
 }
@@ -1,6 +1,9 @@
 package stringsutil
 
-import "regexp"
+import (
+	"regexp"
+	"strings"
+)
 
 // Validation functions
 func ValidateTaiwanCatalogue(Catalogue string) bool {
@@ -22,3 +25,31 @@ func ValidateLineID(lineID string) bool {
 	re := regexp.MustCompile(`^[a-zA-Z0-9_]{1,20}$`) // Basic Line ID format
 	return re.MatchString(lineID)
 }
+
+// NormalizeTaiwanMobile converts a Taiwan mobile number in local (09xxxxxxxx)
+// or international (+8869xxxxxxxx) format to E.164. Spaces and dashes are
+// ignored. It returns false if the number is not a Taiwan mobile number.
+func NormalizeTaiwanMobile(phone string) (string, bool) {
+	phone = strings.NewReplacer(" ", "", "-", "", "(", "", ")", "").Replace(phone)
+	if strings.HasPrefix(phone, "886") {
+		phone = "+" + phone
+	}
+	if !ValidateTaiwanCatalogue(phone) {
+		return "", false
+	}
+	if strings.HasPrefix(phone, "0") {
+		return "+886" + phone[1:], true
+	}
+	return phone, true
+}
+
+// NormalizeTaiwanLandline converts a Taiwan landline number in the
+// 0X-XXXXXXXX format to E.164. It returns false if the number is not a Taiwan
+// landline number.
+func NormalizeTaiwanLandline(landline string) (string, bool) {
+	landline = strings.TrimSpace(landline)
+	if !ValidateTaiwanLandline(landline) {
+		return "", false
+	}
+	return "+886" + strings.Replace(landline[1:], "-", "", 1), true
+}